@@ -1,3 +1,7 @@
 module github.com/mateuszmidor/GoStudy/gpt/chatcompletions
 
 go 1.21.6
+
+require github.com/mateuszmidor/AiStudy/llm v0.0.0
+
+replace github.com/mateuszmidor/AiStudy/llm => ../../llm
@@ -0,0 +1,244 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache stores the responses on disk, one file per request, so that repeated prompts are answered instantly.
+// The key covers the server, the model, the prompt or messages and all the generation options
+type Cache struct {
+	Dir      string
+	TTL      time.Duration // entries older than that are ignored and removed; 0 means no expiry
+	MaxBytes int64         // the oldest entries are evicted when the cache grows above; 0 means no limit
+	Bypass   bool          // skip the lookups but still store the fresh responses, eg. to refresh the cache
+
+	mu    sync.Mutex
+	stats CacheStats
+}
+
+// CacheStats counts the cache lookups and updates since the cache was opened
+type CacheStats struct {
+	Hits      int `json:"hits"`
+	Misses    int `json:"misses"`
+	Stores    int `json:"stores"`
+	Evictions int `json:"evictions"` // expired or over the size limit
+}
+
+// cacheEntry is the content of a cache file
+type cacheEntry struct {
+	CreatedAt time.Time `json:"created_at"`
+	Response  Response  `json:"response"`
+}
+
+// OpenCache opens the cache in dir, creating the dir if needed
+func OpenCache(dir string, ttl time.Duration, maxBytes int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Cache{Dir: dir, TTL: ttl, MaxBytes: maxBytes}, nil
+}
+
+// Get returns the cached response; expired entry is removed and reported as a miss
+func (c *Cache) Get(key string) (Response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		c.stats.Misses++
+		return Response{}, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		slog.Warn("removing broken cache entry", "path", path, "error", err)
+		os.Remove(path)
+		c.stats.Misses++
+		return Response{}, false
+	}
+	if c.TTL > 0 && time.Since(entry.CreatedAt) > c.TTL {
+		os.Remove(path)
+		c.stats.Evictions++
+		c.stats.Misses++
+		return Response{}, false
+	}
+
+	// the modification time tracks the last use, for eviction
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	c.stats.Hits++
+	entry.Response.Cached = true
+	return entry.Response, true
+}
+
+// Put stores the response, then evicts the least recently used entries if the cache is over its size limit
+func (c *Cache) Put(key string, rsp Response) error {
+	data, err := json.Marshal(cacheEntry{CreatedAt: time.Now(), Response: rsp})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.path(key)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	c.stats.Stores++
+	return c.evict()
+}
+
+// Stats returns the cache statistics
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Clear removes all the entries
+func (c *Cache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := os.Remove(filepath.Join(c.Dir, f.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// evict removes the least recently used entries until the cache fits in MaxBytes
+func (c *Cache) evict() error {
+	if c.MaxBytes <= 0 {
+		return nil
+	}
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, f := range files {
+		total += f.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	for _, f := range files {
+		if total <= c.MaxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.Dir, f.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		total -= f.Size()
+		c.stats.Evictions++
+	}
+	return nil
+}
+
+// files lists the cache entries
+func (c *Cache) files() ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []os.FileInfo
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // removed meanwhile
+		}
+		files = append(files, info)
+	}
+	return files, nil
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
+
+// CacheOf returns the cache used by the model, nil if none
+func CacheOf(model Generator) *Cache {
+	if m, ok := model.(interface{ Cache() *Cache }); ok {
+		return m.Cache()
+	}
+	return nil
+}
+
+// Cache returns the cache, nil if none; promoted to the model clients
+func (o *options) Cache() *Cache {
+	return o.cache
+}
+
+// cacheKey hashes the request URL and payload; the payload must not include the stream flag, so that streamed and
+// non-streamed requests share the entries
+func cacheKey(url string, payload interface{}) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(url+"\n"), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// bypassCacheKey marks the context of requests that skip the cache lookup
+type bypassCacheKey struct{}
+
+// BypassCache makes the requests made with the returned context skip the cache lookup; the fresh responses are still stored
+func BypassCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCacheKey{}, true)
+}
+
+// cached returns the cached response for the request if there is one, delivering it to onToken at once;
+// otherwise it generates the response and stores it
+func (o *options) cached(ctx context.Context, path string, keyPayload interface{}, onToken func(token string), generate func() (Response, error)) (Response, error) {
+	if o.cache == nil {
+		return generate()
+	}
+	key, err := cacheKey(o.baseURL+path, keyPayload)
+	if err != nil {
+		return Response{}, err
+	}
+
+	if !o.cache.Bypass && ctx.Value(bypassCacheKey{}) == nil {
+		if rsp, ok := o.cache.Get(key); ok {
+			slog.Debug("llm response served from cache", "model", rsp.Model)
+			if onToken != nil && rsp.Text != "" {
+				onToken(rsp.Text)
+			}
+			return rsp, nil
+		}
+	}
+
+	rsp, err := generate()
+	if err != nil {
+		return Response{}, err
+	}
+	if err := o.cache.Put(key, rsp); err != nil {
+		slog.Warn("llm response not cached", "error", err)
+	}
+	return rsp, nil
+}
@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheTTL(t *testing.T) {
+	cache, err := OpenCache(t.TempDir(), time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Put("key", Response{Text: "Go is fast."}); err != nil {
+		t.Fatal(err)
+	}
+
+	rsp, ok := cache.Get("key")
+	if !ok || rsp.Text != "Go is fast." || !rsp.Cached {
+		t.Fatalf("fresh entry: %+v, found %v", rsp, ok)
+	}
+	cache.TTL = time.Nanosecond
+	if _, ok := cache.Get("key"); ok {
+		t.Fatal("expired entry found")
+	}
+	if _, err := os.Stat(cache.path("key")); !os.IsNotExist(err) {
+		t.Fatalf("expired entry not removed: %v", err)
+	}
+	expected := CacheStats{Hits: 1, Misses: 1, Stores: 1, Evictions: 1}
+	if cache.Stats() != expected {
+		t.Fatalf("stats %+v, expected %+v", cache.Stats(), expected)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache, err := OpenCache(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i, key := range []string{"a", "b"} {
+		if err := cache.Put(key, Response{Text: "text of " + key}); err != nil {
+			t.Fatal(err)
+		}
+		used := now.Add(time.Duration(i-3) * time.Hour) // a used before b
+		os.Chtimes(cache.path(key), used, used)
+	}
+	info, err := os.Stat(cache.path("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache.Get("a") // a is now the most recently used
+	cache.MaxBytes = 2 * info.Size()
+	if err := cache.Put("c", Response{Text: "text of c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, kept := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, err := os.Stat(cache.path(key)); (err == nil) != kept {
+			t.Errorf("entry %s: %v, expected kept %v", key, err, kept)
+		}
+	}
+	if cache.Stats().Evictions != 1 {
+		t.Fatalf("stats %+v, expected 1 eviction", cache.Stats())
+	}
+}
+
+func TestCachedGenerate(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"model":"llama3","response":"Go is fast.","done":true}`))
+	}))
+	defer server.Close()
+	cache, err := OpenCache(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewOllama(WithBaseURL(server.URL), WithCache(cache))
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		generate func() (Response, error)
+		requests int32
+		cached   bool
+	}{
+		{name: "miss", generate: func() (Response, error) { return client.Generate(ctx, "Is Go fast?") }, requests: 1},
+		{name: "hit", generate: func() (Response, error) { return client.Generate(ctx, "Is Go fast?") }, requests: 1, cached: true},
+		{
+			name: "streamed hit",
+			generate: func() (Response, error) {
+				var text string
+				rsp, err := client.GenerateStream(ctx, "Is Go fast?", func(token string) { text += token })
+				if text != rsp.Text {
+					t.Errorf("streamed %q, expected %q", text, rsp.Text)
+				}
+				return rsp, err
+			},
+			requests: 1,
+			cached:   true,
+		},
+		{name: "other prompt", generate: func() (Response, error) { return client.Generate(ctx, "Is Rust fast?") }, requests: 2},
+		{name: "bypassed", generate: func() (Response, error) { return client.Generate(BypassCache(ctx), "Is Go fast?") }, requests: 3},
+		{name: "hit after bypass", generate: func() (Response, error) { return client.Generate(ctx, "Is Go fast?") }, requests: 3, cached: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsp, err := tt.generate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rsp.Text != "Go is fast." || rsp.Cached != tt.cached {
+				t.Fatalf("response %+v, expected cached %v", rsp, tt.cached)
+			}
+			if requests.Load() != tt.requests {
+				t.Fatalf("%d requests, expected %d", requests.Load(), tt.requests)
+			}
+		})
+	}
+	if CacheOf(client) != cache {
+		t.Fatal("client doesn't report its cache")
+	}
+}
@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Providers
+const (
+	ProviderOllama = "ollama"
+	ProviderOpenAI = "openai" // also any OpenAI-compatible server
+)
+
+// Config selects the provider and the model, eg. read from the environment with ConfigFromEnv
+type Config struct {
+	Provider string // [ProviderOllama, ProviderOpenAI]; empty means ollama
+	Model    string // empty means provider default
+	URL      string // empty means provider default
+	APIKey   string // required by openai
+
+	CacheDir      string        // directory of the response cache; empty means no caching
+	CacheTTL      time.Duration // 0 means no expiry
+	CacheMaxBytes int64         // 0 means no limit
+	CacheBypass   bool          // skip the cache lookups, still storing the fresh responses
+}
+
+// Cache defaults, used by ConfigFromEnv
+const (
+	defaultCacheTTL      = 7 * 24 * time.Hour
+	defaultCacheMaxBytes = 100 << 20
+)
+
+// ConfigFromEnv reads the config from environment variables <prefix>_PROVIDER, <prefix>_MODEL, <prefix>_URL and <prefix>_APIKEY;
+// the api key defaults to GPT_APIKEY.
+// The cache is enabled with <prefix>_CACHE=<dir> and configured with <prefix>_CACHE_TTL (eg. "24h", default 7 days),
+// <prefix>_CACHE_MAX_MB (default 100) and <prefix>_CACHE_BYPASS=1
+func ConfigFromEnv(prefix string) Config {
+	cfg := Config{
+		Provider: os.Getenv(prefix + "_PROVIDER"),
+		Model:    os.Getenv(prefix + "_MODEL"),
+		URL:      os.Getenv(prefix + "_URL"),
+		APIKey:   os.Getenv(prefix + "_APIKEY"),
+	}
+	if cfg.APIKey == "" {
+		cfg.APIKey = os.Getenv("GPT_APIKEY")
+	}
+
+	cfg.CacheDir = os.Getenv(prefix + "_CACHE")
+	cfg.CacheTTL = defaultCacheTTL
+	if ttl, err := time.ParseDuration(os.Getenv(prefix + "_CACHE_TTL")); err == nil {
+		cfg.CacheTTL = ttl
+	}
+	cfg.CacheMaxBytes = defaultCacheMaxBytes
+	if mb, err := strconv.ParseInt(os.Getenv(prefix+"_CACHE_MAX_MB"), 10, 64); err == nil {
+		cfg.CacheMaxBytes = mb << 20
+	}
+	cfg.CacheBypass, _ = strconv.ParseBool(os.Getenv(prefix + "_CACHE_BYPASS"))
+	return cfg
+}
+
+// New creates ChatModel of the configured provider; opts are applied after the config
+func New(cfg Config, opts ...Option) (ChatModel, error) {
+	cfgOpts := []Option{WithModel(cfg.Model), WithBaseURL(cfg.URL), WithAPIKey(cfg.APIKey)}
+	if cfg.CacheDir != "" {
+		cache, err := OpenCache(cfg.CacheDir, cfg.CacheTTL, cfg.CacheMaxBytes)
+		if err != nil {
+			return nil, err
+		}
+		cache.Bypass = cfg.CacheBypass
+		cfgOpts = append(cfgOpts, WithCache(cache))
+	}
+	opts = append(cfgOpts, opts...)
+	switch cfg.Provider {
+	case "", ProviderOllama:
+		return NewOllama(opts...), nil
+	case ProviderOpenAI:
+		if cfg.APIKey == "" && cfg.URL == "" {
+			return nil, fmt.Errorf("OpenAI API key is not set")
+		}
+		return NewOpenAI(opts...), nil
+	default:
+		return nil, fmt.Errorf("unknown llm provider %q", cfg.Provider)
+	}
+}
@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// summaryPrompt asks the model to fold the turns that fall out of the window into the running summary
+const summaryPrompt = `Instruction: Update the summary of the conversation with the new messages. Keep the facts, names and topics that later questions may refer to. Reply with the summary only, in a few sentences.
+Summary so far: %s
+New messages:
+%s
+Summary:`
+
+// Conversation keeps the recent messages of the chat. Older messages are dropped (sliding window),
+// or folded into a running summary if the conversation has a summarizer
+type Conversation struct {
+	MaxMessages int       // how many recent messages are kept verbatim
+	Summarizer  Generator // summarizes the dropped messages; nil means they are forgotten
+
+	summary  string
+	messages []Message
+}
+
+// NewConversation creates conversation that keeps maxMessages recent messages; nil summarizer means sliding window only
+func NewConversation(maxMessages int, summarizer Generator) *Conversation {
+	return &Conversation{MaxMessages: maxMessages, Summarizer: summarizer}
+}
+
+// Add appends the message to the conversation
+func (c *Conversation) Add(role, content string) {
+	c.messages = append(c.messages, Message{Role: role, Content: content})
+}
+
+// Messages returns the history to be sent to the chat: the summary, if any, as system message followed by the recent messages
+func (c *Conversation) Messages() []Message {
+	var messages []Message
+	if c.summary != "" {
+		messages = append(messages, Message{Role: RoleSystem, Content: "Summary of the earlier conversation: " + c.summary})
+	}
+	return append(messages, c.messages...)
+}
+
+// Empty tells if nothing was said yet
+func (c *Conversation) Empty() bool {
+	return c.summary == "" && len(c.messages) == 0
+}
+
+// Transcript returns the history as text, one "role: content" line per message
+func (c *Conversation) Transcript() string {
+	return transcript(c.Messages())
+}
+
+// Compact drops the messages that don't fit in the window, summarizing them first if the conversation has a summarizer.
+// If the summarization fails, the messages are kept and the error returned
+func (c *Conversation) Compact(ctx context.Context) error {
+	excess := len(c.messages) - c.MaxMessages
+	if excess <= 0 {
+		return nil
+	}
+	dropped := c.messages[:excess]
+
+	if c.Summarizer != nil {
+		prompt := fmt.Sprintf(summaryPrompt, c.summary, transcript(dropped))
+		rsp, err := c.Summarizer.Generate(ctx, prompt)
+		if err != nil {
+			return fmt.Errorf("summarizing conversation: %w", err)
+		}
+		c.summary = strings.TrimSpace(rsp.Text)
+	}
+	c.messages = append([]Message(nil), c.messages[excess:]...)
+	return nil
+}
+
+// transcript renders the messages as text, one "role: content" line per message
+func transcript(messages []Message) string {
+	var lines []string
+	for _, m := range messages {
+		lines = append(lines, m.Role+": "+strings.TrimSpace(m.Content))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConversationCompact(t *testing.T) {
+	var prompts []string
+	summarizer := fakeGenerator(func(prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		return " summary " + string(rune('0'+len(prompts))) + "\n", nil
+	})
+
+	tests := []struct {
+		name       string
+		summarizer Generator
+		messages   []Message
+		prompts    int
+	}{
+		{
+			name:     "sliding window",
+			messages: []Message{{Role: RoleUser, Content: "Why?"}, {Role: RoleAssistant, Content: "Because."}},
+		},
+		{
+			name:       "summarized",
+			summarizer: summarizer,
+			messages: []Message{
+				{Role: RoleSystem, Content: "Summary of the earlier conversation: summary 2"},
+				{Role: RoleUser, Content: "Why?"},
+				{Role: RoleAssistant, Content: "Because."},
+			},
+			prompts: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompts = nil
+			c := NewConversation(2, tt.summarizer)
+			c.Add(RoleUser, "Who wrote Go?")
+			c.Add(RoleAssistant, "Rob Pike, Ken Thompson and Robert Griesemer.")
+			c.Add(RoleUser, "Why?")
+			if err := c.Compact(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			c.Add(RoleAssistant, "Because.")
+			if err := c.Compact(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Compact(context.Background()); err != nil {
+				t.Fatal(err) // nothing to drop, the summarizer isn't called
+			}
+
+			if len(prompts) != tt.prompts {
+				t.Fatalf("summarized %d times, expected %d", len(prompts), tt.prompts)
+			}
+			if !reflect.DeepEqual(c.Messages(), tt.messages) {
+				t.Fatalf("messages %+v, expected %+v", c.Messages(), tt.messages)
+			}
+			if tt.prompts == 0 {
+				return
+			}
+			if !strings.Contains(prompts[0], "New messages:\nuser: Who wrote Go?\nSummary:") {
+				t.Errorf("first prompt lacks the dropped messages:\n%s", prompts[0])
+			}
+			if !strings.Contains(prompts[1], "Summary so far: summary 1\n") || !strings.Contains(prompts[1], "New messages:\nassistant: Rob Pike, Ken Thompson and Robert Griesemer.\nSummary:") {
+				t.Errorf("second prompt lacks the summary or the dropped messages:\n%s", prompts[1])
+			}
+		})
+	}
+}
+
+func TestConversationCompactFails(t *testing.T) {
+	c := NewConversation(1, fakeGenerator(func(prompt string) (string, error) {
+		return "", errors.New("model unreachable")
+	}))
+	c.Add(RoleUser, "Who wrote Go?")
+	c.Add(RoleAssistant, "Rob Pike.")
+
+	if err := c.Compact(context.Background()); err == nil {
+		t.Fatal("expected the summarizer error")
+	}
+	if len(c.Messages()) != 2 || c.Empty() {
+		t.Fatalf("messages lost: %+v", c.Messages())
+	}
+}
+
+// fakeGenerator replies to the prompt with the func result
+type fakeGenerator func(prompt string) (string, error)
+
+func (g fakeGenerator) Generate(ctx context.Context, prompt string) (Response, error) {
+	text, err := g(prompt)
+	return Response{Text: text}, err
+}
+
+func (g fakeGenerator) GenerateStream(ctx context.Context, prompt string, onToken func(token string)) (Response, error) {
+	rsp, err := g.Generate(ctx, prompt)
+	onToken(rsp.Text)
+	return rsp, err
+}
+
+func (g fakeGenerator) Model() string {
+	return "fake"
+}
@@ -0,0 +1,3 @@
+module github.com/mateuszmidor/AiStudy/llm
+
+go 1.21
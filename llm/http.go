@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// StatusError is returned when the server responds with status other than 200 OK
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s responded with %s: %s", e.URL, e.Status, e.Body)
+}
+
+// retryable tells if the request failed for a reason that may go away, eg. model still loading or server overloaded
+func retryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	return true // connection error
+}
+
+// post sends the payload as JSON and returns the response body, to be closed by the caller
+func (o *options) post(ctx context.Context, path string, payload interface{}) (io.ReadCloser, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	url := o.baseURL + path
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &StatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status, Body: string(body)}
+	}
+	return resp.Body, nil
+}
+
+// get sends GET request and discards the response body; used for health checks
+func (o *options) get(ctx context.Context, path string) error {
+	url := o.baseURL + path
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status, Body: string(body)}
+	}
+	return nil
+}
+
+// request posts the payload and reads the response with read, applying the timeout and retries.
+// Once read has been called, the request is not repeated: the tokens may have already been delivered
+func (o *options) request(ctx context.Context, path string, payload interface{}, read func(ctx context.Context, body io.Reader) error) error {
+	backoff := o.backoff
+	for i := 0; ; i++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if o.timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, o.timeout)
+		}
+		body, err := o.post(attemptCtx, path, payload)
+		if err == nil {
+			err = read(attemptCtx, body)
+			body.Close()
+			cancel()
+			return err
+		}
+		cancel()
+		if ctx.Err() != nil || !retryable(err) || i >= o.retries {
+			return err
+		}
+
+		slog.Warn("llm request failed, retrying", "error", err, "attempt", i+1, "backoff", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// decodeError reports the stream decoding error, or the cancellation that caused it
+func decodeError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("reading llm response: %w", err)
+}
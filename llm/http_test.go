@@ -0,0 +1,147 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int // of the consecutive attempts, the last one repeats
+		retries  int
+		attempts int32
+		status   int // of the returned StatusError, 0 means success
+	}{
+		{name: "too many requests", statuses: []int{429, 200}, retries: 2, attempts: 2},
+		{name: "server errors", statuses: []int{503, 502, 200}, retries: 2, attempts: 3},
+		{name: "retries exhausted", statuses: []int{500}, retries: 1, attempts: 2, status: 500},
+		{name: "bad request not retried", statuses: []int{400}, retries: 2, attempts: 1, status: 400},
+		{name: "no retries", statuses: []int{429, 200}, attempts: 1, status: 429},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := int(attempts.Add(1)) - 1
+				status := tt.statuses[min(i, len(tt.statuses)-1)]
+				if status != http.StatusOK {
+					http.Error(w, "try later", status)
+					return
+				}
+				w.Write([]byte(`{"model":"llama3","response":"ok","done":true}`))
+			}))
+			defer server.Close()
+
+			client := NewOllama(WithBaseURL(server.URL), WithRetry(tt.retries, time.Millisecond))
+			response, err := client.Generate(context.Background(), "hello")
+			if attempts.Load() != tt.attempts {
+				t.Fatalf("%d attempts, expected %d", attempts.Load(), tt.attempts)
+			}
+			if tt.status == 0 {
+				if err != nil || response.Text != "ok" {
+					t.Fatalf("response %+v, error %v", response, err)
+				}
+				return
+			}
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.status {
+				t.Fatalf("error %v, expected status %d", err, tt.status)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	var attempts atomic.Int32
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		<-done // the model is too slow
+	}))
+	defer server.Close()
+	defer close(done)
+
+	client := NewOllama(WithBaseURL(server.URL), WithTimeout(20*time.Millisecond), WithRetry(1, time.Millisecond))
+	_, err := client.Generate(context.Background(), "hello")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error %v, expected deadline exceeded", err)
+	}
+	if attempts.Load() != 2 {
+		t.Fatalf("%d attempts, expected the timed out attempt retried once", attempts.Load())
+	}
+}
+
+func TestRequestOptions(t *testing.T) {
+	opts := []Option{
+		WithModel("tiny"),
+		WithTemperature(0),
+		WithTopP(0.9),
+		WithMaxTokens(32),
+		WithNumCtx(4096),
+		WithSeed(7),
+		WithStop("\n\n"),
+		WithKeepAlive(-1),
+	}
+	zero, topP, seed := 0.0, 0.9, 7
+
+	tests := []struct {
+		name     string
+		client   func(opts ...Option) Generator
+		expected interface{}
+	}{
+		{
+			name:   "ollama",
+			client: func(opts ...Option) Generator { return NewOllama(opts...) },
+			expected: &OllamaRequest{
+				Model:     "tiny",
+				Prompt:    "hello",
+				Options:   &OllamaOptions{Temperature: &zero, TopP: &topP, NumCtx: 4096, NumPredict: 32, Seed: &seed, Stop: []string{"\n\n"}},
+				KeepAlive: "-1",
+			},
+		},
+		{
+			name:   "openai",
+			client: func(opts ...Option) Generator { return NewOpenAI(opts...) },
+			expected: &OpenAIRequest{
+				Model:       "tiny",
+				Messages:    []Message{{Role: RoleUser, Content: "hello"}},
+				MaxTokens:   32,
+				Temperature: &zero,
+				TopP:        &topP,
+				Seed:        &seed,
+				Stop:        []string{"\n\n"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := reflect.New(reflect.TypeOf(tt.expected).Elem()).Interface()
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				decodeJSON(t, r, request)
+				if _, ok := request.(*OllamaRequest); ok {
+					w.Write([]byte(`{"response":"ok","done":true}`))
+				} else {
+					w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+				}
+			}))
+			defer server.Close()
+
+			client := tt.client(append(opts, WithBaseURL(server.URL))...)
+			if _, err := client.Generate(context.Background(), "hello"); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(request, tt.expected) {
+				got, _ := json.Marshal(request)
+				t.Fatalf("request %s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Package llm is a provider-agnostic LLM client: the same Generator/ChatModel interface over Ollama and OpenAI-compatible servers
+package llm
+
+import (
+	"context"
+	"time"
+)
+
+// Chat message roles
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is a single message of the conversation
+type Message struct {
+	Role    string `json:"role"` // [RoleSystem, RoleUser, RoleAssistant]
+	Content string `json:"content"`
+}
+
+// Usage is the number of tokens processed by the model, the same for all providers
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`     // input, including the chat history
+	CompletionTokens int `json:"completion_tokens"` // output
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Response is the generated text with the statistics
+type Response struct {
+	Text         string
+	Model        string // the model that generated the text, as reported by the server
+	FinishReason string // eg. "stop", or "length" if the max tokens limit was hit
+	Usage        Usage
+	Duration     time.Duration // as reported by the server if available, measured by the client otherwise; of the original request if Cached
+	Cached       bool          `json:"-"` // served from the Cache
+}
+
+// Generator completes the prompt
+type Generator interface {
+	Generate(ctx context.Context, prompt string) (Response, error)
+	// GenerateStream calls onToken with every chunk of the text as it arrives; the returned Response holds the whole text. Cancelling ctx stops the generation
+	GenerateStream(ctx context.Context, prompt string, onToken func(token string)) (Response, error)
+	Model() string
+}
+
+// ChatModel generates the next assistant message of the conversation
+type ChatModel interface {
+	Generator
+	Chat(ctx context.Context, messages []Message) (Response, error)
+	// ChatStream calls onToken with every chunk of the message as it arrives; the returned Response holds the whole message. Cancelling ctx stops the generation
+	ChatStream(ctx context.Context, messages []Message, onToken func(token string)) (Response, error)
+}
+
+// Pinger is implemented by the models that can check their server is reachable
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Ping checks that the model server is reachable, if the model supports it
+func Ping(ctx context.Context, model Generator) error {
+	if p, ok := model.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// makeUsage fills in the total
+func makeUsage(promptTokens, completionTokens int) Usage {
+	return Usage{PromptTokens: promptTokens, CompletionTokens: completionTokens, TotalTokens: promptTokens + completionTokens}
+}
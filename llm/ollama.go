@@ -0,0 +1,168 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	defaultOllamaURL   = "http://localhost:11434"
+	defaultOllamaModel = "llama3"
+)
+
+// OllamaOptions are the Ollama model parameters, see: https://github.com/ollama/ollama/blob/main/docs/modelfile.md#valid-parameters-and-values
+// Nil or zero fields are left to the model defaults
+type OllamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	NumCtx      int      `json:"num_ctx,omitempty"`     // context window size, in tokens
+	NumPredict  int      `json:"num_predict,omitempty"` // maximum number of tokens to generate
+	Seed        *int     `json:"seed,omitempty"`        // fixed seed makes the output reproducible
+	Stop        []string `json:"stop,omitempty"`        // generation stops at any of these sequences
+}
+
+type OllamaRequest struct {
+	Model     string         `json:"model"`
+	Stream    bool           `json:"stream"`
+	Prompt    string         `json:"prompt,omitempty"`   // /api/generate
+	Messages  []Message      `json:"messages,omitempty"` // /api/chat
+	Options   *OllamaOptions `json:"options,omitempty"`
+	KeepAlive string         `json:"keep_alive,omitempty"`
+}
+
+type OllamaResponse struct {
+	Model              string    `json:"model"`
+	CreatedAt          time.Time `json:"created_at"`
+	Response           string    `json:"response"` // /api/generate
+	Message            *Message  `json:"message"`  // /api/chat
+	Done               bool      `json:"done"`
+	DoneReason         string    `json:"done_reason"`
+	Context            []int     `json:"context"`
+	TotalDuration      int64     `json:"total_duration"`
+	LoadDuration       int64     `json:"load_duration"`
+	PromptEvalCount    int       `json:"prompt_eval_count"` // prompt tokens
+	PromptEvalDuration int64     `json:"prompt_eval_duration"`
+	EvalCount          int       `json:"eval_count"` // response tokens
+	EvalDuration       int64     `json:"eval_duration"`
+}
+
+// Ollama talks to the Ollama server, see: https://github.com/ollama/ollama/blob/main/docs/api.md
+type Ollama struct {
+	options
+}
+
+// NewOllama creates Ollama client; by default it uses llama3 at http://localhost:11434
+func NewOllama(opts ...Option) *Ollama {
+	return &Ollama{options: makeOptions(defaultOllamaURL, defaultOllamaModel, opts)}
+}
+
+// Model implements Generator
+func (c *Ollama) Model() string {
+	return c.model
+}
+
+// Ping implements Pinger
+func (c *Ollama) Ping(ctx context.Context) error {
+	return c.get(ctx, "/api/tags")
+}
+
+// Generate implements Generator
+func (c *Ollama) Generate(ctx context.Context, prompt string) (Response, error) {
+	return c.GenerateStream(ctx, prompt, nil)
+}
+
+// GenerateStream implements Generator; nil onToken disables streaming
+func (c *Ollama) GenerateStream(ctx context.Context, prompt string, onToken func(token string)) (Response, error) {
+	return c.send(ctx, "/api/generate", c.makeRequest(onToken != nil, prompt, nil), onToken)
+}
+
+// Chat implements ChatModel
+func (c *Ollama) Chat(ctx context.Context, messages []Message) (Response, error) {
+	return c.ChatStream(ctx, messages, nil)
+}
+
+// ChatStream implements ChatModel; nil onToken disables streaming
+func (c *Ollama) ChatStream(ctx context.Context, messages []Message, onToken func(token string)) (Response, error) {
+	return c.send(ctx, "/api/chat", c.makeRequest(onToken != nil, "", messages), onToken)
+}
+
+func (c *Ollama) makeRequest(stream bool, prompt string, messages []Message) OllamaRequest {
+	return OllamaRequest{
+		Model:    c.model,
+		Stream:   stream,
+		Prompt:   prompt,
+		Messages: messages,
+		Options: &OllamaOptions{
+			Temperature: c.temperature,
+			TopP:        c.topP,
+			NumCtx:      c.numCtx,
+			NumPredict:  c.maxTokens,
+			Seed:        c.seed,
+			Stop:        c.stop,
+		},
+		KeepAlive: c.keepAlive,
+	}
+}
+
+func (c *Ollama) send(ctx context.Context, path string, payload OllamaRequest, onToken func(token string)) (Response, error) {
+	keyPayload := payload
+	keyPayload.Stream = false
+	keyPayload.KeepAlive = "" // doesn't affect the response
+	return c.cached(ctx, path, keyPayload, onToken, func() (Response, error) {
+		return c.generate(ctx, path, payload, onToken)
+	})
+}
+
+func (c *Ollama) generate(ctx context.Context, path string, payload OllamaRequest, onToken func(token string)) (Response, error) {
+	start := time.Now()
+	var result Response
+	err := c.request(ctx, path, payload, func(ctx context.Context, body io.Reader) (err error) {
+		result, err = readOllamaResponse(ctx, body, onToken)
+		return err
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	if result.Duration == 0 {
+		result.Duration = time.Since(start)
+	}
+
+	slog.Debug("received response from ollama", "model", result.Model, "input_tokens", result.Usage.PromptTokens, "output_tokens", result.Usage.CompletionTokens)
+	return result, nil
+}
+
+// readOllamaResponse reads the response: a single JSON object, or a sequence of JSON objects, one per line, when streaming.
+// The last object has Done set and carries the statistics
+func readOllamaResponse(ctx context.Context, body io.Reader, onToken func(token string)) (Response, error) {
+	var text strings.Builder
+	decoder := json.NewDecoder(body)
+	for {
+		var chunk OllamaResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			return Response{}, decodeError(ctx, err)
+		}
+		token := chunk.Response
+		if chunk.Message != nil {
+			token = chunk.Message.Content
+		}
+		if token != "" {
+			text.WriteString(token)
+			if onToken != nil {
+				onToken(token)
+			}
+		}
+		if chunk.Done {
+			return Response{
+				Text:         text.String(),
+				Model:        chunk.Model,
+				FinishReason: chunk.DoneReason,
+				Usage:        makeUsage(chunk.PromptEvalCount, chunk.EvalCount),
+				Duration:     time.Duration(chunk.TotalDuration),
+			}, nil
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadOllamaResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		tokens   []string
+		response Response
+		valid    bool
+	}{
+		{
+			name:   "single object",
+			body:   `{"model":"llama3","response":"Go is fast.","done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":4,"total_duration":2000000}`,
+			tokens: []string{"Go is fast."},
+			response: Response{Text: "Go is fast.", Model: "llama3", FinishReason: "stop",
+				Usage: Usage{PromptTokens: 12, CompletionTokens: 4, TotalTokens: 16}, Duration: 2 * time.Millisecond},
+			valid: true,
+		},
+		{
+			name: "generate stream",
+			body: `{"model":"llama3","response":"Go","done":false}
+{"model":"llama3","response":" is","done":false}
+{"model":"llama3","response":"","done":false}
+{"model":"llama3","response":" fast.","done":false}
+{"model":"llama3","response":"","done":true,"done_reason":"length","prompt_eval_count":12,"eval_count":3}
+`,
+			tokens:   []string{"Go", " is", " fast."},
+			response: Response{Text: "Go is fast.", Model: "llama3", FinishReason: "length", Usage: Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15}},
+			valid:    true,
+		},
+		{
+			name: "chat stream",
+			body: `{"model":"llama3","message":{"role":"assistant","content":"Hi"},"done":false}
+{"model":"llama3","message":{"role":"assistant","content":"!"},"done":false}
+{"model":"llama3","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}`,
+			tokens:   []string{"Hi", "!"},
+			response: Response{Text: "Hi!", Model: "llama3", FinishReason: "stop"},
+			valid:    true,
+		},
+		{
+			name:   "stream cut before done",
+			body:   `{"model":"llama3","response":"Go","done":false}`,
+			tokens: []string{"Go"},
+		},
+		{
+			name: "broken json",
+			body: `{"model":"llama3","response":`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tokens []string
+			response, err := readOllamaResponse(context.Background(), strings.NewReader(tt.body), func(token string) { tokens = append(tokens, token) })
+			if (err == nil) != tt.valid {
+				t.Fatalf("error %v, expected valid %v", err, tt.valid)
+			}
+			if !reflect.DeepEqual(tokens, tt.tokens) {
+				t.Fatalf("tokens %q, expected %q", tokens, tt.tokens)
+			}
+			if response != tt.response {
+				t.Fatalf("response %+v, expected %+v", response, tt.response)
+			}
+		})
+	}
+}
+
+func TestOllamaGenerateStream(t *testing.T) {
+	var request OllamaRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/generate" {
+			t.Errorf("request to %s, expected /api/generate", r.URL.Path)
+		}
+		decodeJSON(t, r, &request)
+		for _, token := range []string{"Go", " is", " fast."} {
+			fmt.Fprintf(w, `{"model":"llama3","response":%q,"done":false}`+"\n", token)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, `{"model":"llama3","response":"","done":true,"done_reason":"stop"}`)
+	}))
+	defer server.Close()
+
+	client := NewOllama(WithBaseURL(server.URL))
+	var tokens []string
+	response, err := client.GenerateStream(context.Background(), "Is Go fast?", func(token string) { tokens = append(tokens, token) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Text != "Go is fast." || len(tokens) != 3 || response.Duration == 0 {
+		t.Fatalf("response %+v from tokens %q", response, tokens)
+	}
+	if !request.Stream || request.Prompt != "Is Go fast?" || request.Model != defaultOllamaModel {
+		t.Fatalf("request %+v", request)
+	}
+}
+
+func TestOllamaStreamCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"model":"llama3","response":"Go","done":false}`+"\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done() // the model keeps generating until the client goes away
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := NewOllama(WithBaseURL(server.URL)).GenerateStream(ctx, "Is Go fast?", func(token string) { cancel() })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error %v, expected context.Canceled", err)
+	}
+}
+
+// decodeJSON decodes the request body into v
+func decodeJSON(t *testing.T, r *http.Request, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		t.Errorf("decoding request: %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	defaultOpenAIURL   = "https://api.openai.com/v1"
+	defaultOpenAIModel = "gpt-4o-mini"
+)
+
+type OpenAIRequest struct {
+	Model         string         `json:"model"`
+	Messages      []Message      `json:"messages"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+	MaxTokens     int            `json:"max_tokens,omitempty"`
+	Temperature   *float64       `json:"temperature,omitempty"`
+	TopP          *float64       `json:"top_p,omitempty"`
+	Seed          *int           `json:"seed,omitempty"`
+	Stop          []string       `json:"stop,omitempty"`
+}
+
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"` // the last chunk carries the usage
+}
+
+type OpenAIResponse struct {
+	ID      string         `json:"id"`
+	Model   string         `json:"model"`
+	Choices []OpenAIChoice `json:"choices"`
+	Usage   *Usage         `json:"usage"`
+	Error   *OpenAIError   `json:"error"`
+}
+
+type OpenAIChoice struct {
+	Index        int      `json:"index"`
+	Message      *Message `json:"message"` // complete response
+	Delta        *Message `json:"delta"`   // streamed chunk
+	FinishReason string   `json:"finish_reason"`
+}
+
+type OpenAIError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    string `json:"code"`
+}
+
+func (e *OpenAIError) Error() string {
+	return e.Message
+}
+
+// OpenAI talks to the OpenAI chat completions API, or any server compatible with it (eg. vLLM, LM Studio, llama.cpp server)
+type OpenAI struct {
+	options
+}
+
+// NewOpenAI creates OpenAI client; by default it uses gpt-4o-mini at https://api.openai.com/v1
+func NewOpenAI(opts ...Option) *OpenAI {
+	return &OpenAI{options: makeOptions(defaultOpenAIURL, defaultOpenAIModel, opts)}
+}
+
+// Model implements Generator
+func (c *OpenAI) Model() string {
+	return c.model
+}
+
+// Ping implements Pinger
+func (c *OpenAI) Ping(ctx context.Context) error {
+	return c.get(ctx, "/models")
+}
+
+// Generate implements Generator, the prompt is sent as a single user message
+func (c *OpenAI) Generate(ctx context.Context, prompt string) (Response, error) {
+	return c.ChatStream(ctx, []Message{{Role: RoleUser, Content: prompt}}, nil)
+}
+
+// GenerateStream implements Generator, the prompt is sent as a single user message
+func (c *OpenAI) GenerateStream(ctx context.Context, prompt string, onToken func(token string)) (Response, error) {
+	return c.ChatStream(ctx, []Message{{Role: RoleUser, Content: prompt}}, onToken)
+}
+
+// Chat implements ChatModel
+func (c *OpenAI) Chat(ctx context.Context, messages []Message) (Response, error) {
+	return c.ChatStream(ctx, messages, nil)
+}
+
+// ChatStream implements ChatModel; nil onToken disables streaming
+func (c *OpenAI) ChatStream(ctx context.Context, messages []Message, onToken func(token string)) (Response, error) {
+	payload := OpenAIRequest{
+		Model:       c.model,
+		Messages:    messages,
+		MaxTokens:   c.maxTokens,
+		Temperature: c.temperature,
+		TopP:        c.topP,
+		Seed:        c.seed,
+		Stop:        c.stop,
+	}
+	keyPayload := payload
+	if onToken != nil {
+		payload.Stream = true
+		payload.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
+	return c.cached(ctx, "/chat/completions", keyPayload, onToken, func() (Response, error) {
+		return c.generate(ctx, payload, onToken)
+	})
+}
+
+func (c *OpenAI) generate(ctx context.Context, payload OpenAIRequest, onToken func(token string)) (Response, error) {
+	start := time.Now()
+	var result Response
+	err := c.request(ctx, "/chat/completions", payload, func(ctx context.Context, body io.Reader) (err error) {
+		if onToken == nil {
+			result, err = readOpenAIResponse(body)
+		} else {
+			result, err = readOpenAIStream(ctx, body, onToken)
+		}
+		return err
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	result.Duration = time.Since(start)
+
+	slog.Debug("received response from openai", "model", result.Model, "input_tokens", result.Usage.PromptTokens, "output_tokens", result.Usage.CompletionTokens)
+	return result, nil
+}
+
+// readOpenAIResponse reads the complete, non-streamed response
+func readOpenAIResponse(body io.Reader) (Response, error) {
+	var rsp OpenAIResponse
+	if err := json.NewDecoder(body).Decode(&rsp); err != nil {
+		return Response{}, err
+	}
+	if rsp.Error != nil {
+		return Response{}, rsp.Error
+	}
+	if len(rsp.Choices) == 0 || rsp.Choices[0].Message == nil {
+		return Response{}, errors.New("no choices in the response")
+	}
+
+	result := Response{Text: rsp.Choices[0].Message.Content, Model: rsp.Model, FinishReason: rsp.Choices[0].FinishReason}
+	if rsp.Usage != nil {
+		result.Usage = *rsp.Usage
+	}
+	return result, nil
+}
+
+// readOpenAIStream reads the server-sent events: "data: {chunk}" lines, terminated with "data: [DONE]"
+func readOpenAIStream(ctx context.Context, body io.Reader, onToken func(token string)) (Response, error) {
+	var result Response
+	var text strings.Builder
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue // empty lines between events, comments
+		}
+		if data == "[DONE]" {
+			result.Text = text.String()
+			return result, nil
+		}
+
+		var chunk OpenAIResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return Response{}, fmt.Errorf("reading llm response: %w", err)
+		}
+		if chunk.Error != nil {
+			return Response{}, chunk.Error
+		}
+		result.Model = chunk.Model
+		if chunk.Usage != nil {
+			result.Usage = *chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta != nil && choice.Delta.Content != "" {
+				text.WriteString(choice.Delta.Content)
+				onToken(choice.Delta.Content)
+			}
+			if choice.FinishReason != "" {
+				result.FinishReason = choice.FinishReason
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Response{}, decodeError(ctx, err)
+	}
+	return Response{}, decodeError(ctx, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,158 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadOpenAIStream(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		tokens   []string
+		response Response
+		valid    bool
+	}{
+		{
+			name: "usage in the last chunk",
+			body: `data: {"model":"gpt-4o-mini","choices":[{"delta":{"role":"assistant","content":""}}]}
+
+data: {"model":"gpt-4o-mini","choices":[{"delta":{"content":"Go"}}]}
+
+: keep-alive comment
+data: {"model":"gpt-4o-mini","choices":[{"delta":{"content":" is fast."}}]}
+
+data: {"model":"gpt-4o-mini","choices":[{"delta":{},"finish_reason":"stop"}]}
+
+data: {"model":"gpt-4o-mini","choices":[],"usage":{"prompt_tokens":9,"completion_tokens":3,"total_tokens":12}}
+
+data: [DONE]
+
+`,
+			tokens:   []string{"Go", " is fast."},
+			response: Response{Text: "Go is fast.", Model: "gpt-4o-mini", FinishReason: "stop", Usage: Usage{PromptTokens: 9, CompletionTokens: 3, TotalTokens: 12}},
+			valid:    true,
+		},
+		{
+			name:     "no usage",
+			body:     "data: {\"model\":\"local\",\"choices\":[{\"delta\":{\"content\":\"Hi\"},\"finish_reason\":\"length\"}]}\ndata: [DONE]\n",
+			tokens:   []string{"Hi"},
+			response: Response{Text: "Hi", Model: "local", FinishReason: "length"},
+			valid:    true,
+		},
+		{
+			name:   "error chunk",
+			body:   "data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\ndata: {\"error\":{\"message\":\"overloaded\",\"type\":\"server_error\"}}\n",
+			tokens: []string{"Hi"},
+		},
+		{
+			name:   "stream cut before done",
+			body:   "data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n",
+			tokens: []string{"Hi"},
+		},
+		{
+			name: "broken json",
+			body: "data: {\"choices\":[\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tokens []string
+			response, err := readOpenAIStream(context.Background(), strings.NewReader(tt.body), func(token string) { tokens = append(tokens, token) })
+			if (err == nil) != tt.valid {
+				t.Fatalf("error %v, expected valid %v", err, tt.valid)
+			}
+			if !reflect.DeepEqual(tokens, tt.tokens) {
+				t.Fatalf("tokens %q, expected %q", tokens, tt.tokens)
+			}
+			if response != tt.response {
+				t.Fatalf("response %+v, expected %+v", response, tt.response)
+			}
+		})
+	}
+}
+
+func TestReadOpenAIResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		response Response
+		err      string
+	}{
+		{
+			name:     "complete",
+			body:     `{"model":"gpt-4o-mini","choices":[{"message":{"role":"assistant","content":"Go is fast."},"finish_reason":"stop"}],"usage":{"prompt_tokens":9,"completion_tokens":3,"total_tokens":12}}`,
+			response: Response{Text: "Go is fast.", Model: "gpt-4o-mini", FinishReason: "stop", Usage: Usage{PromptTokens: 9, CompletionTokens: 3, TotalTokens: 12}},
+		},
+		{
+			name: "error",
+			body: `{"error":{"message":"invalid model","type":"invalid_request_error"}}`,
+			err:  "invalid model",
+		},
+		{
+			name: "no choices",
+			body: `{"model":"gpt-4o-mini","choices":[]}`,
+			err:  "no choices",
+		},
+		{
+			name: "broken json",
+			body: `{"model":`,
+			err:  "EOF",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := readOpenAIResponse(strings.NewReader(tt.body))
+			if tt.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("error %v, expected %q", err, tt.err)
+			}
+			if response != tt.response {
+				t.Fatalf("response %+v, expected %+v", response, tt.response)
+			}
+		})
+	}
+}
+
+func TestOpenAIChatStream(t *testing.T) {
+	var request OpenAIRequest
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			t.Errorf("request to %s, expected /chat/completions", r.URL.Path)
+		}
+		authorization = r.Header.Get("Authorization")
+		decodeJSON(t, r, &request)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, token := range []string{"Go", " is fast."} {
+			fmt.Fprintf(w, "data: {\"model\":\"gpt-4o-mini\",\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", token)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client := NewOpenAI(WithBaseURL(server.URL+"/"), WithAPIKey("secret"))
+	var tokens []string
+	messages := []Message{{Role: RoleSystem, Content: "Be brief."}, {Role: RoleUser, Content: "Is Go fast?"}}
+	response, err := client.ChatStream(context.Background(), messages, func(token string) { tokens = append(tokens, token) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Text != "Go is fast." || len(tokens) != 2 || response.Duration == 0 {
+		t.Fatalf("response %+v from tokens %q", response, tokens)
+	}
+	if !request.Stream || request.StreamOptions == nil || !request.StreamOptions.IncludeUsage || !reflect.DeepEqual(request.Messages, messages) {
+		t.Fatalf("request %+v", request)
+	}
+	if authorization != "Bearer secret" {
+		t.Fatalf("authorization %q", authorization)
+	}
+}
@@ -0,0 +1,123 @@
+package llm
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultTimeout = 5 * time.Minute
+
+// options are shared by all the providers; the ones a provider doesn't support are ignored
+type options struct {
+	baseURL     string
+	model       string
+	apiKey      string
+	temperature *float64
+	topP        *float64
+	maxTokens   int
+	numCtx      int
+	seed        *int
+	stop        []string
+	keepAlive   string        // how long the model stays loaded after the request, eg. "10m"; empty means server default
+	timeout     time.Duration // limit for a single attempt, including reading the whole response
+	retries     int           // how many times a failed request is retried
+	backoff     time.Duration // delay before the first retry, doubled with every next one
+	httpClient  *http.Client
+	cache       *Cache // nil means no caching
+}
+
+// Option configures the model client
+type Option func(*options)
+
+// WithModel selects the model; empty means provider default
+func WithModel(model string) Option {
+	return func(o *options) { o.model = model }
+}
+
+// WithBaseURL selects the server; empty means provider default
+func WithBaseURL(url string) Option {
+	return func(o *options) { o.baseURL = strings.TrimSuffix(url, "/") }
+}
+
+// WithAPIKey sets the key sent as bearer token; required by OpenAI
+func WithAPIKey(key string) Option {
+	return func(o *options) { o.apiKey = key }
+}
+
+// WithTemperature sets the sampling temperature; 0 makes the output (almost) deterministic
+func WithTemperature(temperature float64) Option {
+	return func(o *options) { o.temperature = &temperature }
+}
+
+// WithTopP sets the nucleus sampling probability mass
+func WithTopP(topP float64) Option {
+	return func(o *options) { o.topP = &topP }
+}
+
+// WithMaxTokens limits the number of generated tokens
+func WithMaxTokens(maxTokens int) Option {
+	return func(o *options) { o.maxTokens = maxTokens }
+}
+
+// WithNumCtx sets the context window size, in tokens; Ollama only
+func WithNumCtx(numCtx int) Option {
+	return func(o *options) { o.numCtx = numCtx }
+}
+
+// WithSeed fixes the random seed, for reproducible output
+func WithSeed(seed int) Option {
+	return func(o *options) { o.seed = &seed }
+}
+
+// WithStop sets the sequences the generation stops at
+func WithStop(sequences ...string) Option {
+	return func(o *options) { o.stop = sequences }
+}
+
+// WithKeepAlive sets how long the model stays loaded in memory after the request, negative keeps it loaded forever; Ollama only
+func WithKeepAlive(d time.Duration) Option {
+	return func(o *options) {
+		if d < 0 {
+			o.keepAlive = "-1"
+		} else {
+			o.keepAlive = d.String()
+		}
+	}
+}
+
+// WithTimeout limits the duration of a single request attempt; 0 means no limit
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) { o.timeout = timeout }
+}
+
+// WithRetry retries the failed requests up to retries times, waiting backoff before the first retry and doubling it every next time.
+// Only connection errors, 429 and 5xx responses are retried
+func WithRetry(retries int, backoff time.Duration) Option {
+	return func(o *options) { o.retries, o.backoff = retries, backoff }
+}
+
+// WithHTTPClient replaces the default http.Client, eg. to use a proxy
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(o *options) { o.httpClient = httpClient }
+}
+
+// WithCache answers the repeated requests from the cache
+func WithCache(cache *Cache) Option {
+	return func(o *options) { o.cache = cache }
+}
+
+// makeOptions applies opts over the defaults: 5 minutes timeout and no retries
+func makeOptions(defaultURL, defaultModel string, opts []Option) options {
+	o := options{timeout: defaultTimeout, httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.baseURL == "" {
+		o.baseURL = defaultURL
+	}
+	if o.model == "" {
+		o.model = defaultModel
+	}
+	return o
+}
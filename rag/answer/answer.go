@@ -0,0 +1,61 @@
+package answer
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+// citation matches references like [1], [2, 3] or [1][4]
+var citation = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// Answer is the model response traced back to the information pieces it came from
+type Answer struct {
+	Text    string   `json:"text"`
+	Sources []Source `json:"sources"` // cited information pieces, in the order of their numbers
+	Uncited bool     `json:"uncited"` // the model cited nothing, the answer can't be traced back
+}
+
+// Source is a cited information piece
+type Source struct {
+	Ref     int                    `json:"ref"` // the number the piece had in the prompt, starting from 1
+	ID      string                 `json:"id"`
+	Score   float64                `json:"score"`
+	Text    string                 `json:"text"`
+	Payload map[string]interface{} `json:"payload,omitempty"`
+}
+
+// MakePrompt creates a prompt for the LLM with all the information pieces numbered from 1, so that the answer can cite them;
+// see BuildContext for fitting the pieces into a token budget
+func MakePrompt(question string, informationPieces []vecdb.SearchResult) string {
+	prompt, err := render(defaultTemplate, question, informationPieces)
+	if err != nil {
+		panic(err) // DefaultTemplate only uses the fields of PromptData
+	}
+	return prompt
+}
+
+// Parse finds the citations in the model response and maps them to the information pieces used in MakePrompt or Context.Pieces;
+// references to non-existent pieces are ignored
+func Parse(response string, informationPieces []vecdb.SearchResult) Answer {
+	cited := map[int]bool{}
+	for _, match := range citation.FindAllStringSubmatch(response, -1) {
+		for _, ref := range strings.Split(match[1], ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(ref))
+			if err == nil && n >= 1 && n <= len(informationPieces) {
+				cited[n] = true
+			}
+		}
+	}
+
+	answer := Answer{Text: strings.TrimSpace(response), Uncited: len(cited) == 0}
+	for n := range cited {
+		r := informationPieces[n-1]
+		answer.Sources = append(answer.Sources, Source{Ref: n, ID: r.ID, Score: r.Score, Text: r.Text, Payload: r.Payload})
+	}
+	sort.Slice(answer.Sources, func(i, j int) bool { return answer.Sources[i].Ref < answer.Sources[j].Ref })
+	return answer
+}
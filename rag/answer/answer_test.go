@@ -0,0 +1,72 @@
+package answer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+func TestParse(t *testing.T) {
+	pieces := []vecdb.SearchResult{
+		{ID: "a", Text: "Go has goroutines.", Score: 0.9, Payload: map[string]interface{}{"source": "go.md"}},
+		{ID: "b", Text: "Rust guarantees memory safety.", Score: 0.8},
+		{ID: "c", Text: "Python is dynamically typed.", Score: 0.7},
+	}
+
+	tests := []struct {
+		name     string
+		response string
+		refs     []int
+		uncited  bool
+	}{
+		{"single", "Go has goroutines [1].", []int{1}, false},
+		{"list", "Both are compiled [1, 2].", []int{1, 2}, false},
+		{"adjacent", "Both are compiled [2][1].", []int{1, 2}, false},
+		{"repeated", "Go [1] and again Go [1].", []int{1}, false},
+		{"out of range ignored", "Nothing here [4] [0].", nil, true},
+		{"partly out of range", "Rust [2, 7].", []int{2}, false},
+		{"not a citation", "Use slice[i] or [a, b].", nil, true},
+		{"no citation", "I don't know.", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer := Parse(" "+tt.response+"\n", pieces)
+			var refs []int
+			for _, s := range answer.Sources {
+				refs = append(refs, s.Ref)
+				if p := pieces[s.Ref-1]; s.ID != p.ID || s.Text != p.Text || s.Score != p.Score {
+					t.Errorf("source %d doesn't describe the piece %+v: %+v", s.Ref, p, s)
+				}
+			}
+			if !reflect.DeepEqual(refs, tt.refs) {
+				t.Fatalf("cited %v, expected %v", refs, tt.refs)
+			}
+			if answer.Uncited != tt.uncited {
+				t.Fatalf("uncited %v, expected %v", answer.Uncited, tt.uncited)
+			}
+			if answer.Text != tt.response {
+				t.Fatalf("text %q, expected trimmed %q", answer.Text, tt.response)
+			}
+		})
+	}
+}
+
+func TestMakePromptNumbersPieces(t *testing.T) {
+	pieces := []vecdb.SearchResult{
+		{Text: "Go has goroutines.", Payload: map[string]interface{}{"source": "go.md"}},
+		{Text: "Rust guarantees memory safety."},
+	}
+	prompt := MakePrompt("Which is safe?", pieces)
+
+	for _, expected := range []string{
+		"Information [1] (source: go.md): Go has goroutines.\n",
+		"Information [2]: Rust guarantees memory safety.\n",
+		"Question: Which is safe?",
+	} {
+		if !strings.Contains(prompt, expected) {
+			t.Errorf("prompt lacks %q:\n%s", expected, prompt)
+		}
+	}
+}
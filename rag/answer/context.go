@@ -0,0 +1,269 @@
+package answer
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+// DefaultTemplate numbers the information pieces and asks the model to answer from them only and to cite them
+const DefaultTemplate = `Instruction: Based only on the provided information, answer the question in one short sentence. ` +
+	`Cite the information you used by its number in square brackets, eg. [1] or [1][3]. ` +
+	`If the information doesn't answer the question, say that you don't know.
+{{range .Pieces}}Information [{{.Ref}}]{{if .Source}} (source: {{.Source}}){{end}}: {{.Text}}
+{{end}}Question: {{.Question}}`
+
+// defaultTemplate is DefaultTemplate parsed
+var defaultTemplate = template.Must(template.New("prompt").Parse(DefaultTemplate))
+
+// wordPattern matches the words, for comparing the pieces
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}_]+`)
+
+// ContextOptions controls how the information pieces are fitted into the prompt
+type ContextOptions struct {
+	MaxTokens        int     // budget of the whole prompt, counted with CountTokens; 0 means no limit
+	DedupeSimilarity float64 // word overlap (Jaccard) from which the lower scored piece is dropped as near-duplicate; 0 means 0.9, above 1 disables
+	MergeAdjacent    bool    // join the neighbouring chunks of the same document into one piece
+	Template         string  // text/template of the prompt, executed with PromptData; empty means DefaultTemplate
+}
+
+// DefaultContextOptions returns the options that leave room for the answer in a 4k tokens context window
+func DefaultContextOptions() ContextOptions {
+	return ContextOptions{MaxTokens: 3000, DedupeSimilarity: 0.9, MergeAdjacent: true}
+}
+
+// PromptData is what the prompt template gets
+type PromptData struct {
+	Question string
+	Pieces   []PromptPiece
+}
+
+// PromptPiece is an information piece as presented in the prompt
+type PromptPiece struct {
+	Ref    int    // number to cite the piece by, starting from 1
+	Source string // document the piece comes from, empty if unknown
+	Text   string
+	Score  float64
+}
+
+// Context is the prompt with the information pieces that made it into the prompt
+type Context struct {
+	Prompt  string
+	Pieces  []vecdb.SearchResult // numbered from 1 in the prompt; pass them to Parse
+	Tokens  int                  // approximate size of the prompt
+	Dropped int                  // near-duplicates and the pieces that didn't fit into the budget
+}
+
+// BuildContext creates the prompt from the best scored information pieces that fit into opts.MaxTokens.
+// Near-duplicate pieces are left out, and with opts.MergeAdjacent the neighbouring chunks of a document become one piece.
+// The pieces are presented best scored first
+func BuildContext(question string, informationPieces []vecdb.SearchResult, opts ContextOptions) (Context, error) {
+	tmpl := defaultTemplate
+	if opts.Template != "" {
+		var err error
+		tmpl, err = template.New("prompt").Parse(opts.Template)
+		if err != nil {
+			return Context{}, err
+		}
+	}
+
+	candidates := dedupe(byScore(informationPieces), opts.DedupeSimilarity)
+	result := Context{Dropped: len(informationPieces) - len(candidates)}
+
+	// take the pieces best scored first, as long as the prompt fits into the budget
+	var selected []vecdb.SearchResult
+	for _, candidate := range candidates {
+		pieces := append(append([]vecdb.SearchResult{}, selected...), candidate)
+		if opts.MergeAdjacent {
+			pieces = mergeAdjacent(pieces)
+		}
+		prompt, err := render(tmpl, question, pieces)
+		if err != nil {
+			return Context{}, err
+		}
+		tokens := CountTokens(prompt)
+		if opts.MaxTokens > 0 && tokens > opts.MaxTokens {
+			result.Dropped++
+			continue
+		}
+		selected = append(selected, candidate)
+		result.Prompt, result.Pieces, result.Tokens = prompt, pieces, tokens
+	}
+
+	// no pieces at all
+	if result.Prompt == "" {
+		prompt, err := render(tmpl, question, nil)
+		if err != nil {
+			return Context{}, err
+		}
+		result.Prompt, result.Tokens = prompt, CountTokens(prompt)
+	}
+	return result, nil
+}
+
+// render executes the prompt template with the pieces numbered from 1
+func render(tmpl *template.Template, question string, pieces []vecdb.SearchResult) (string, error) {
+	data := PromptData{Question: question}
+	for i, r := range pieces {
+		data.Pieces = append(data.Pieces, PromptPiece{Ref: i + 1, Source: r.PayloadString("source"), Text: r.Text, Score: r.Score})
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// byScore returns copy of the pieces sorted best first; ties keep the retrieval order
+func byScore(pieces []vecdb.SearchResult) []vecdb.SearchResult {
+	sorted := append([]vecdb.SearchResult{}, pieces...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Score > sorted[j].Score })
+	return sorted
+}
+
+// dedupe drops the pieces whose words overlap with a better scored piece at least by similarity; pieces must be sorted best first
+func dedupe(pieces []vecdb.SearchResult, similarity float64) []vecdb.SearchResult {
+	if similarity == 0 {
+		similarity = DefaultContextOptions().DedupeSimilarity
+	}
+
+	var kept []vecdb.SearchResult
+	var keptWords []map[string]bool
+	for _, piece := range pieces {
+		words := wordSet(piece.Text)
+		duplicate := false
+		for i, k := range kept {
+			if piece.ID != "" && piece.ID == k.ID || jaccard(words, keptWords[i]) >= similarity {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			kept = append(kept, piece)
+			keptWords = append(keptWords, words)
+		}
+	}
+	return kept
+}
+
+// wordSet returns the lowercase words of the text, without punctuation
+func wordSet(text string) map[string]bool {
+	words := map[string]bool{}
+	for _, w := range wordPattern.FindAllString(strings.ToLower(text), -1) {
+		words[w] = true
+	}
+	return words
+}
+
+// jaccard is the size of the intersection divided by the size of the union, 1 for two empty sets
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	common := 0
+	for w := range a {
+		if b[w] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// chunkPosition locates the chunk in its source document
+type chunkPosition struct {
+	source     string
+	index      int // chunk_index
+	start, end int // character offsets
+}
+
+// positionOf reads the chunk position from the payload; false if the piece isn't a chunk of a known document
+func positionOf(r vecdb.SearchResult) (chunkPosition, bool) {
+	var p chunkPosition
+	var err1, err2, err3 error
+	p.source = r.PayloadString("source")
+	p.index, err1 = strconv.Atoi(r.PayloadString("chunk_index"))
+	p.start, err2 = strconv.Atoi(r.PayloadString("start"))
+	p.end, err3 = strconv.Atoi(r.PayloadString("end"))
+	return p, p.source != "" && err1 == nil && err2 == nil && err3 == nil
+}
+
+// mergeAdjacent joins the chunks that follow one another in the same document, removing their overlap.
+// The merged piece takes the place and the score of its best scored chunk, and the ID and payload of its first chunk
+func mergeAdjacent(pieces []vecdb.SearchResult) []vecdb.SearchResult {
+	// group the chunk positions by document
+	positions := map[int]chunkPosition{}
+	bySource := map[string][]int{}
+	for i, piece := range pieces {
+		if p, ok := positionOf(piece); ok {
+			positions[i] = p
+			bySource[p.source] = append(bySource[p.source], i)
+		}
+	}
+
+	// merge the runs of consecutive chunks into the best scored one of the run
+	merged := append([]vecdb.SearchResult{}, pieces...)
+	removed := map[int]bool{}
+	for _, indexes := range bySource {
+		sort.Slice(indexes, func(a, b int) bool { return positions[indexes[a]].index < positions[indexes[b]].index })
+		for runStart := 0; runStart < len(indexes); {
+			runEnd := runStart + 1
+			for runEnd < len(indexes) && positions[indexes[runEnd]].index == positions[indexes[runEnd-1]].index+1 {
+				runEnd++
+			}
+			if runEnd-runStart > 1 {
+				run := indexes[runStart:runEnd]
+				best := run[0]
+				for _, i := range run {
+					if pieces[i].Score > pieces[best].Score {
+						best = i
+					}
+				}
+				for _, i := range run {
+					removed[i] = i != best
+				}
+				merged[best] = joinChunks(pieces, positions, run, pieces[best].Score)
+			}
+			runStart = runEnd
+		}
+	}
+
+	var result []vecdb.SearchResult
+	for i, piece := range merged {
+		if !removed[i] {
+			result = append(result, piece)
+		}
+	}
+	return result
+}
+
+// joinChunks concatenates the texts of consecutive chunks, skipping the characters each chunk shares with the previous one
+func joinChunks(pieces []vecdb.SearchResult, positions map[int]chunkPosition, run []int, score float64) vecdb.SearchResult {
+	first := pieces[run[0]]
+	text := []rune(first.Text)
+	end := positions[run[0]].end
+	for _, i := range run[1:] {
+		next, p := []rune(pieces[i].Text), positions[i]
+		switch {
+		case p.start > end:
+			text = append(text, ' ')
+		case end-p.start < len(next):
+			next = next[end-p.start:]
+		default:
+			next = nil // contained in the previous chunk
+		}
+		text = append(text, next...)
+		end = max(end, p.end)
+	}
+
+	payload := map[string]interface{}{}
+	for k, v := range first.Payload {
+		payload[k] = v
+	}
+	payload["end"] = end
+	payload["text"] = string(text)
+	return vecdb.SearchResult{ID: first.ID, Score: score, Text: string(text), Payload: payload}
+}
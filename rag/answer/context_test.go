@@ -0,0 +1,203 @@
+package answer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+// textsTemplate renders the piece texts only, one per line, so that the prompt tokens are the sum of the piece tokens
+const textsTemplate = "{{range .Pieces}}{{.Text}}\n{{end}}"
+
+func TestCountTokens(t *testing.T) {
+	tests := []struct {
+		text   string
+		tokens int
+	}{
+		{"", 0},
+		{"Go", 1},
+		{"goroutines", 3},
+		{"Go has goroutines.", 6},
+		{"zażółć gęślą", 4},
+		{"[1][2]", 6},
+	}
+	for _, tt := range tests {
+		if tokens := CountTokens(tt.text); tokens != tt.tokens {
+			t.Errorf("CountTokens(%q) = %d, expected %d", tt.text, tokens, tt.tokens)
+		}
+	}
+}
+
+func TestBuildContextBudget(t *testing.T) {
+	pieces := []vecdb.SearchResult{
+		{ID: "c", Text: "Java", Score: 0.7},                         // 1 token
+		{ID: "a", Text: "Go is fast", Score: 0.9},                   // 3 tokens
+		{ID: "b", Text: "Rust has no data race at all", Score: 0.8}, // 7 tokens
+	}
+
+	tests := []struct {
+		name      string
+		maxTokens int
+		texts     []string
+		dropped   int
+	}{
+		{name: "no limit", texts: []string{"Go is fast", "Rust has no data race at all", "Java"}},
+		{name: "all fit", maxTokens: 11, texts: []string{"Go is fast", "Rust has no data race at all", "Java"}},
+		{name: "long piece skipped", maxTokens: 9, texts: []string{"Go is fast", "Java"}, dropped: 1},
+		{name: "best only", maxTokens: 3, texts: []string{"Go is fast"}, dropped: 2},
+		{name: "only the worst fits", maxTokens: 2, texts: []string{"Java"}, dropped: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := BuildContext("question", pieces, ContextOptions{MaxTokens: tt.maxTokens, Template: textsTemplate})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var texts []string
+			for _, p := range ctx.Pieces {
+				texts = append(texts, p.Text)
+			}
+			if !reflect.DeepEqual(texts, tt.texts) {
+				t.Fatalf("pieces %q, expected %q", texts, tt.texts)
+			}
+			if ctx.Dropped != tt.dropped {
+				t.Fatalf("dropped %d, expected %d", ctx.Dropped, tt.dropped)
+			}
+			if ctx.Prompt != strings.Join(tt.texts, "\n")+"\n" || ctx.Tokens != CountTokens(ctx.Prompt) {
+				t.Fatalf("prompt %q of %d tokens", ctx.Prompt, ctx.Tokens)
+			}
+			if tt.maxTokens > 0 && ctx.Tokens > tt.maxTokens {
+				t.Fatalf("%d tokens over the budget %d", ctx.Tokens, tt.maxTokens)
+			}
+		})
+	}
+}
+
+func TestBuildContextNoPieces(t *testing.T) {
+	ctx, err := BuildContext("Why?", []vecdb.SearchResult{{Text: "a long piece that won't fit"}}, ContextOptions{MaxTokens: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ctx.Pieces) != 0 || ctx.Dropped != 1 || !strings.HasSuffix(ctx.Prompt, "Question: Why?") {
+		t.Fatalf("context %+v", ctx)
+	}
+
+	if _, err := BuildContext("Why?", nil, ContextOptions{Template: "{{.Missing"}); err == nil {
+		t.Fatal("expected error for broken template")
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	tests := []struct {
+		name       string
+		pieces     []vecdb.SearchResult
+		similarity float64
+		ids        []string
+	}{
+		{
+			name:   "punctuation and case ignored",
+			pieces: []vecdb.SearchResult{{ID: "a", Text: "Go has goroutines."}, {ID: "b", Text: "go has GOROUTINES!"}},
+			ids:    []string{"a"},
+		},
+		{
+			name:   "different texts kept",
+			pieces: []vecdb.SearchResult{{ID: "a", Text: "Go has goroutines."}, {ID: "b", Text: "Go has channels."}},
+			ids:    []string{"a", "b"},
+		},
+		{
+			name:       "lower similarity",
+			pieces:     []vecdb.SearchResult{{ID: "a", Text: "Go has goroutines."}, {ID: "b", Text: "Go has channels."}},
+			similarity: 0.5,
+			ids:        []string{"a"},
+		},
+		{
+			name:   "same point found twice",
+			pieces: []vecdb.SearchResult{{ID: "a", Text: "Go has goroutines."}, {ID: "a", Text: "Go has goroutines and channels."}},
+			ids:    []string{"a"},
+		},
+		{
+			name:       "disabled",
+			pieces:     []vecdb.SearchResult{{ID: "a", Text: "Go has goroutines."}, {ID: "b", Text: "Go has goroutines."}},
+			similarity: 1.1,
+			ids:        []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			for _, p := range dedupe(tt.pieces, tt.similarity) {
+				ids = append(ids, p.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Fatalf("kept %v, expected %v", ids, tt.ids)
+			}
+		})
+	}
+}
+
+func TestMergeAdjacent(t *testing.T) {
+	// "Go has goroutines. Go has channels. Go compiles fast." split into overlapping chunks
+	chunk := func(id string, index, start, end int, text string, score float64) vecdb.SearchResult {
+		payload := map[string]interface{}{"source": "go.md", "chunk_index": index, "start": start, "end": end}
+		return vecdb.SearchResult{ID: id, Text: text, Score: score, Payload: payload}
+	}
+	first := chunk("0", 0, 0, 19, "Go has goroutines. ", 0.5)
+	second := chunk("1", 1, 15, 36, "es. Go has channels. ", 0.9)
+	third := chunk("2", 2, 36, 53, "Go compiles fast.", 0.7)
+	other := vecdb.SearchResult{ID: "x", Text: "Rust guarantees memory safety.", Score: 0.8, Payload: map[string]interface{}{"source": "rust.md"}}
+
+	tests := []struct {
+		name   string
+		pieces []vecdb.SearchResult
+		texts  []string
+		scores []float64
+	}{
+		{
+			name:   "overlap removed",
+			pieces: []vecdb.SearchResult{second, other, first},
+			texts:  []string{"Go has goroutines. Go has channels. ", other.Text},
+			scores: []float64{0.9, 0.8},
+		},
+		{
+			name:   "run of three",
+			pieces: []vecdb.SearchResult{third, first, second},
+			texts:  []string{"Go has goroutines. Go has channels. Go compiles fast."},
+			scores: []float64{0.9},
+		},
+		{
+			name:   "gap kept",
+			pieces: []vecdb.SearchResult{first, third},
+			texts:  []string{first.Text, third.Text},
+			scores: []float64{0.5, 0.7},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var texts []string
+			var scores []float64
+			for _, p := range mergeAdjacent(tt.pieces) {
+				texts = append(texts, p.Text)
+				scores = append(scores, p.Score)
+			}
+			if !reflect.DeepEqual(texts, tt.texts) || !reflect.DeepEqual(scores, tt.scores) {
+				t.Fatalf("merged %q scored %v, expected %q scored %v", texts, scores, tt.texts, tt.scores)
+			}
+		})
+	}
+}
+
+func TestBuildContextMergesAdjacent(t *testing.T) {
+	pieces := []vecdb.SearchResult{
+		{ID: "1", Text: "Go has channels.", Score: 0.9, Payload: map[string]interface{}{"source": "go.md", "chunk_index": 1, "start": 19, "end": 35}},
+		{ID: "0", Text: "Go has goroutines.", Score: 0.5, Payload: map[string]interface{}{"source": "go.md", "chunk_index": 0, "start": 0, "end": 18}},
+	}
+	ctx, err := BuildContext("Why?", pieces, ContextOptions{MergeAdjacent: true, Template: textsTemplate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ctx.Pieces) != 1 || ctx.Pieces[0].Text != "Go has goroutines. Go has channels." || ctx.Pieces[0].ID != "0" {
+		t.Fatalf("pieces %+v", ctx.Pieces)
+	}
+}
@@ -0,0 +1,35 @@
+package answer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mateuszmidor/AiStudy/llm"
+)
+
+// rewritePrompt asks the model to turn the follow-up question into one that can be understood without the conversation
+const rewritePrompt = `Instruction: Given the conversation and the follow-up question, rewrite the follow-up question into a standalone question that can be understood without the conversation. Replace pronouns and references like "it", "that one" or "the other" with what they refer to. If the question is already standalone, repeat it unchanged. Reply with the question only.
+Conversation:
+%s
+Follow-up question: %s
+Standalone question:`
+
+// StandaloneQuestion rewrites the follow-up question, eg. "and which one is robust?", into a standalone query suitable for the vector db search.
+// The question is returned unchanged if the conversation is empty
+func StandaloneQuestion(ctx context.Context, client llm.Generator, conversation *llm.Conversation, question string) (string, error) {
+	question = strings.TrimSpace(question)
+	if conversation.Empty() {
+		return question, nil
+	}
+
+	rsp, err := client.Generate(ctx, fmt.Sprintf(rewritePrompt, conversation.Transcript(), question))
+	if err != nil {
+		return "", fmt.Errorf("rewriting question: %w", err)
+	}
+	rewritten := strings.Trim(strings.TrimSpace(rsp.Text), `"`)
+	if rewritten == "" {
+		return question, nil
+	}
+	return rewritten, nil
+}
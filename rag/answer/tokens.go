@@ -0,0 +1,23 @@
+package answer
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// charsPerToken is how many characters of a word an average BPE token covers
+const charsPerToken = 4
+
+// tokenPattern splits the text into words and single punctuation marks
+var tokenPattern = regexp.MustCompile(`[\p{L}\p{N}_]+|[^\p{L}\p{N}_\s]`)
+
+// CountTokens approximates the number of tokens the LLM splits the text into, without the model's vocabulary:
+// every punctuation mark is a token, every word is a token per started 4 characters.
+// It tends to overestimate for English, which is the safe side when fitting the text into a budget
+func CountTokens(text string) int {
+	count := 0
+	for _, word := range tokenPattern.FindAllString(text, -1) {
+		count += (utf8.RuneCountInString(word) + charsPerToken - 1) / charsPerToken
+	}
+	return count
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/mateuszmidor/AiStudy/rag/answer"
+	"github.com/mateuszmidor/AiStudy/rag/rerank"
+	"github.com/mateuszmidor/AiStudy/rag/server"
+)
+
+// askCommand answers the question provided in args with the information found in vector db.
+// The text output streams the answer as it is generated, followed by the cited sources;
+// the JSON output is the same as the response of the HTTP /ask endpoint
+func askCommand(args []string) {
+	flags := flag.NewFlagSet("ask", flag.ExitOnError)
+	search := addRetrievalFlags(flags)
+	prompting := addContextFlags(flags)
+	model := addModelFlag(flags)
+	output := addOutputFlag(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), `usage: rag ask [flags] "<question>"`)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	checkOutput(flags, *output)
+	setupGenerator(*model)
+	contextOpts := prompting.options()
+	question := strings.Join(flags.Args(), " ")
+
+	// Ctrl+C stops the retrieval or the generation
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	start := time.Now()
+
+	results, err := rerank.Retrieve(ctx, search.query(question), reranker, search.options())
+	if err != nil {
+		slog.Error("retrieval failed", "error", err)
+		os.Exit(1)
+	}
+	prompt, err := answer.BuildContext(question, results, contextOpts)
+	if err != nil {
+		slog.Error("prompt not created", "error", err)
+		os.Exit(1)
+	}
+	slog.Debug("prompt context", "tokens", prompt.Tokens, "pieces", len(prompt.Pieces), "dropped", prompt.Dropped)
+
+	if *output == outputJSON {
+		rsp, err := generator.Generate(ctx, prompt.Prompt)
+		if err != nil {
+			slog.Error("generation failed", "error", err)
+			os.Exit(1)
+		}
+		printJSON(server.NewAskResponse(rsp, prompt.Pieces, start))
+		return
+	}
+
+	rsp, err := generator.GenerateStream(ctx, prompt.Prompt, func(token string) { fmt.Print(token) })
+	fmt.Println()
+	if err != nil {
+		slog.Error("generation failed", "error", err)
+		os.Exit(1)
+	}
+	printSources(answer.Parse(rsp.Text, prompt.Pieces))
+}
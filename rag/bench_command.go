@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+// benchCollection is created for the benchmark and deleted afterwards
+const benchCollection = "rag_bench"
+
+// benchResult is the benchmark report of one of the stores
+type benchResult struct {
+	Store string `json:"store"`
+	vecdb.BenchReport
+}
+
+// benchCommand compares upsert and search throughput of the vector stores, eg. qdrant REST against qdrant gRPC
+func benchCommand(args []string) {
+	defaults := vecdb.DefaultBenchOptions()
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	stores := flags.String("stores", "rest,grpc", "comma separated stores to compare: rest, grpc (qdrant transports) or embedded")
+	restURL := flags.String("rest-url", vecdb.DefaultQdrantURL, "qdrant REST API URL")
+	grpcAddr := flags.String("grpc-addr", vecdb.DefaultQdrantGRPCAddr, "qdrant gRPC API address")
+	points := flags.Int("points", defaults.Points, "how many random points are upserted")
+	dimensions := flags.Int("dims", defaults.Dimensions, "vector size")
+	batch := flags.Int("batch", defaults.BatchSize, "points per upsert call")
+	queries := flags.Int("queries", defaults.Queries, "how many searches are run")
+	concurrency := flags.Int("concurrency", defaults.Concurrency, "how many upserts and searches run in parallel")
+	storage := addStorageFlags(flags)
+	ef := flags.Int("ef", 0, "HNSW candidates considered by the searches (default the store's)")
+	output := addOutputFlag(flags)
+	flags.Parse(args)
+	checkOutput(flags, *output)
+
+	opts := defaults
+	opts.Points, opts.Dimensions, opts.BatchSize, opts.Queries, opts.Concurrency = *points, *dimensions, *batch, *queries, *concurrency
+	opts.Storage = storage.storage()
+	if *ef > 0 {
+		opts.Search = &vecdb.SearchParams{HNSWEf: *ef}
+	}
+
+	var results []benchResult
+	for _, name := range strings.Split(*stores, ",") {
+		name = strings.TrimSpace(name)
+		var cfg vecdb.StoreConfig
+		switch name {
+		case "rest", "grpc":
+			cfg = vecdb.StoreConfig{Provider: "qdrant", Transport: name, URL: *restURL}
+			if name == "grpc" {
+				cfg.URL = *grpcAddr
+			}
+		case "embedded":
+			dir, err := os.MkdirTemp("", "rag-bench-")
+			if err != nil {
+				slog.Error(err.Error())
+				os.Exit(1)
+			}
+			defer os.RemoveAll(dir)
+			cfg = vecdb.StoreConfig{Provider: "embedded", Dir: dir}
+		default:
+			fmt.Fprintf(flags.Output(), "unknown store %q\n", name)
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		store, err := vecdb.NewStore(cfg)
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+		slog.Info("benchmarking", "store", name, "points", opts.Points, "dimensions", opts.Dimensions)
+		report, err := vecdb.Benchmark(context.Background(), store, benchCollection, opts)
+		if closer, ok := store.(io.Closer); ok {
+			closer.Close()
+		}
+		if err != nil {
+			slog.Error("benchmark failed", "store", name, "error", err)
+			os.Exit(1)
+		}
+		results = append(results, benchResult{Store: name, BenchReport: report})
+	}
+
+	if *output == outputJSON {
+		printJSON(results)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "store\tupsert\tpoints/s\tsearch\tqueries/s\tp50\tp95")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%v\t%.0f\t%v\t%.0f\t%v\t%v\n", r.Store,
+			r.UpsertDuration.Round(time.Millisecond), r.PointsPerSecond,
+			r.SearchDuration.Round(time.Millisecond), r.QueriesPerSecond,
+			r.SearchP50.Round(time.Microsecond), r.SearchP95.Round(time.Microsecond))
+	}
+	w.Flush()
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// chatCommand answers the questions typed in, following the conversation, until the input ends
+func chatCommand(args []string) {
+	flags := flag.NewFlagSet("chat", flag.ExitOnError)
+	search := addRetrievalFlags(flags)
+	prompting := addContextFlags(flags)
+	model := addModelFlag(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: rag chat [flags]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	setupGenerator(*model)
+
+	interactiveMode(search.query(""), search.options(), prompting.options())
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mateuszmidor/AiStudy/llm"
+	"github.com/mateuszmidor/AiStudy/rag/answer"
+	"github.com/mateuszmidor/AiStudy/rag/ingest"
+	"github.com/mateuszmidor/AiStudy/rag/rerank"
+	"github.com/mateuszmidor/AiStudy/rag/transform"
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+// output formats of the commands
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// retrievalFlags are the flags of the commands that search the vector db
+type retrievalFlags struct {
+	collection *string
+	topK       *int
+	candidates *int
+	threshold  *float64
+	mode       *string
+	transform  *string
+	ef         *int
+	exact      *bool
+	rescore    *optionalBool
+	oversample *float64
+	vectors    *string
+}
+
+// addRetrievalFlags defines the retrieval flags, defaulting to the retrieval options
+func addRetrievalFlags(flags *flag.FlagSet) retrievalFlags {
+	return retrievalFlags{
+		collection: flags.String("collection", vecdb.CollectionName(), "collection or alias to search"),
+		topK:       flags.Int("k", retrieval.TopK, "how many chunks are put into the prompt"),
+		candidates: flags.Int("candidates", retrieval.Candidates, "how many chunks are fetched for reranking"),
+		threshold:  flags.Float64("threshold", retrieval.Threshold, "chunks scored at or below are dropped"),
+		mode:       flags.String("mode", vecdb.SearchVector, "search mode: vector, keyword or hybrid"),
+		transform:  flags.String("transform", transform.StrategyNone, "query transformation: none, multi-query or hyde"),
+		ef:         flags.Int("ef", 0, "HNSW candidates considered by the vector search, more means better recall but slower search (default the store's)"),
+		exact:      flags.Bool("exact", false, "vector search without the HNSW index, comparing the question with every chunk"),
+		rescore:    newOptionalBoolFlag(flags, "rescore", "score the chunks found in quantized vectors again with the original ones (default qdrant decides)"),
+		oversample: flags.Float64("oversampling", 0, "with -rescore, fetch this many times more chunks from quantized vectors, eg. 2.0"),
+		vectors:    flags.String("vectors", "", "comma separated named vectors to search, eg. title or text,title fused (default text, in collection with named vectors)"),
+	}
+}
+
+// options returns the retrieval options set with the flags; the query transformation needs the generator to be set up first
+func (f retrievalFlags) options() rerank.Options {
+	return f.optionsWith(*f.transform)
+}
+
+// optionsWith returns the retrieval options set with the flags, but with the given query transformation
+func (f retrievalFlags) optionsWith(transformation string) rerank.Options {
+	opts := retrieval
+	opts.TopK = *f.topK
+	opts.Candidates = *f.candidates
+	opts.Threshold = *f.threshold
+
+	var err error
+	opts.Transformer, err = transform.NewTransformer(transformation, generator)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(2)
+	}
+	return opts
+}
+
+// query returns the vector db query for the question, searching the selected collection in the selected mode
+func (f retrievalFlags) query(question string) vecdb.Query {
+	return vecdb.Query{Text: question, Collection: *f.collection, Mode: *f.mode, Params: f.searchParams(), Using: splitList(*f.vectors)}
+}
+
+// searchParams returns the vector search params set with the flags, nil if none is set
+func (f retrievalFlags) searchParams() *vecdb.SearchParams {
+	if *f.ef == 0 && !*f.exact && f.rescore.value == nil && *f.oversample == 0 {
+		return nil
+	}
+
+	params := &vecdb.SearchParams{HNSWEf: *f.ef, Exact: *f.exact}
+	if f.rescore.value != nil || *f.oversample > 0 {
+		params.Quantization = &vecdb.QuantizationSearchParams{Rescore: f.rescore.value, Oversampling: *f.oversample}
+	}
+	return params
+}
+
+// storageFlags are the flags of the commands that create collections, tuning how the vectors are stored
+type storageFlags struct {
+	datatype     *string
+	onDisk       *bool
+	m            *int
+	efConstruct  *int
+	quantization *string
+	compression  *string
+	alwaysRAM    *bool
+}
+
+// addStorageFlags defines the vector storage flags, defaulting to the store defaults
+func addStorageFlags(flags *flag.FlagSet) storageFlags {
+	return storageFlags{
+		datatype:     flags.String("datatype", vecdb.DatatypeFloat32, "vector datatype of a new collection: float32, float16 or uint8"),
+		onDisk:       flags.Bool("on-disk", false, "keep the vectors of a new collection on disk instead of RAM, best with -quantization"),
+		m:            flags.Int("hnsw-m", 0, fmt.Sprintf("HNSW links per node of a new collection (default %d)", vecdb.DefaultHNSWM)),
+		efConstruct:  flags.Int("hnsw-ef-construct", 0, fmt.Sprintf("HNSW candidates considered when linking new node of a new collection (default %d)", vecdb.DefaultHNSWEfConstruct)),
+		quantization: flags.String("quantization", vecdb.QuantizationNone, "quantization of a new collection: none, scalar, product or binary"),
+		compression:  flags.String("compression", "x16", "product quantization compression: x4, x8, x16, x32 or x64"),
+		alwaysRAM:    flags.Bool("always-ram", false, "keep the quantized vectors in RAM, even with -on-disk"),
+	}
+}
+
+// storage returns the vector storage set with the flags; exits if the quantization is invalid
+func (f storageFlags) storage() vecdb.VectorStorage {
+	var storage vecdb.VectorStorage
+	if *f.datatype != vecdb.DatatypeFloat32 {
+		storage.Datatype = *f.datatype
+	}
+	storage.OnDisk = *f.onDisk
+	if *f.m > 0 || *f.efConstruct > 0 {
+		storage.HNSW = &vecdb.HNSWConfig{M: *f.m, EfConstruct: *f.efConstruct}
+	}
+
+	var err error
+	storage.Quantization, err = vecdb.NewQuantization(*f.quantization, *f.compression, *f.alwaysRAM)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(2)
+	}
+	return storage
+}
+
+// optionalBool is boolean flag that tells apart unset from false; value is nil until the flag is set
+type optionalBool struct {
+	value *bool
+}
+
+// newOptionalBoolFlag defines optional boolean flag
+func newOptionalBoolFlag(flags *flag.FlagSet, name, usage string) *optionalBool {
+	b := &optionalBool{}
+	flags.Var(b, name, usage)
+	return b
+}
+
+func (b *optionalBool) String() string {
+	if b == nil || b.value == nil {
+		return ""
+	}
+	return strconv.FormatBool(*b.value)
+}
+
+func (b *optionalBool) Set(value string) error {
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	b.value = &v
+	return nil
+}
+
+// IsBoolFlag allows setting the flag without value, eg. -rescore instead of -rescore=true
+func (b *optionalBool) IsBoolFlag() bool {
+	return true
+}
+
+// contextFlags are the flags of the commands that put the retrieved chunks into the prompt
+type contextFlags struct {
+	maxTokens *int
+	template  *string
+}
+
+// addContextFlags defines the prompt context flags, defaulting to the context options
+func addContextFlags(flags *flag.FlagSet) contextFlags {
+	return contextFlags{
+		maxTokens: flags.Int("context-tokens", contextOptions.MaxTokens, "token budget of the prompt, the best scored chunks that fit get in; 0 means no limit"),
+		template:  flags.String("template", "", "file with text/template of the prompt (default answer.DefaultTemplate)"),
+	}
+}
+
+// options returns the prompt context options set with the flags; exits if the template can't be read or parsed
+func (f contextFlags) options() answer.ContextOptions {
+	opts := contextOptions
+	opts.MaxTokens = *f.maxTokens
+	if *f.template == "" {
+		return opts
+	}
+
+	content, err := os.ReadFile(*f.template)
+	if err == nil {
+		opts.Template = string(content)
+		_, err = answer.BuildContext("", nil, opts)
+	}
+	if err != nil {
+		slog.Error("invalid prompt template", "error", err)
+		os.Exit(2)
+	}
+	return opts
+}
+
+// addModelFlag defines the flag selecting the LLM answering the questions
+func addModelFlag(flags *flag.FlagSet) *string {
+	return flags.String("model", "", "LLM answering the questions (default RAG_LLM_MODEL or the provider's default)")
+}
+
+// addOutputFlag defines the flag selecting the output format
+func addOutputFlag(flags *flag.FlagSet) *string {
+	return flags.String("output", outputText, "output format: text or json")
+}
+
+// checkOutput exits with usage if the output format is unknown
+func checkOutput(flags *flag.FlagSet, output string) {
+	if output != outputText && output != outputJSON {
+		fmt.Fprintf(flags.Output(), "unknown output format %q\n", output)
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+// manifestPathOr returns path, or the default manifest file of the collection if path is empty; exits if the collection name is invalid
+func manifestPathOr(path, collection string) string {
+	if path != "" {
+		return path
+	}
+	path, err := ingest.DefaultManifestPath(collection)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	return path
+}
+
+// setupGenerator configures the LLM from RAG_LLM_* environment variables; non-empty model overrides RAG_LLM_MODEL.
+// Retries give ollama time to load the model
+func setupGenerator(model string) {
+	cfg := llm.ConfigFromEnv("RAG_LLM")
+	if model != "" {
+		cfg.Model = model
+	}
+
+	var err error
+	generator, err = llm.New(cfg, llm.WithTimeout(5*time.Minute), llm.WithRetry(3, time.Second))
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+// splitList splits comma separated list, skipping the empty items; nil for empty list
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		slog.Error("failed to encode output", "error", err)
+		os.Exit(1)
+	}
+}
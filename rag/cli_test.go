@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+func TestRetrievalFlags(t *testing.T) {
+	yes := true
+	tests := []struct {
+		name      string
+		args      []string
+		query     vecdb.Query
+		topK      int
+		threshold float64
+	}{
+		{
+			name:      "defaults",
+			query:     vecdb.Query{Text: "why?", Collection: vecdb.CollectionName(), Mode: vecdb.SearchVector},
+			topK:      retrieval.TopK,
+			threshold: retrieval.Threshold,
+		},
+		{
+			name:      "search",
+			args:      []string{"-collection", "docs", "-k", "5", "-threshold", "0.3", "-mode", "hybrid", "-vectors", "text, title,"},
+			query:     vecdb.Query{Text: "why?", Collection: "docs", Mode: vecdb.SearchHybrid, Using: []string{"text", "title"}},
+			topK:      5,
+			threshold: 0.3,
+		},
+		{
+			name: "search params",
+			args: []string{"-ef", "128", "-exact", "-rescore", "-oversampling", "2"},
+			query: vecdb.Query{Text: "why?", Collection: vecdb.CollectionName(), Mode: vecdb.SearchVector, Params: &vecdb.SearchParams{
+				HNSWEf: 128, Exact: true, Quantization: &vecdb.QuantizationSearchParams{Rescore: &yes, Oversampling: 2},
+			}},
+			topK:      retrieval.TopK,
+			threshold: retrieval.Threshold,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := flag.NewFlagSet("test", flag.ContinueOnError)
+			search := addRetrievalFlags(flags)
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if query := search.query("why?"); !reflect.DeepEqual(query, tt.query) {
+				t.Fatalf("query %+v, expected %+v", query, tt.query)
+			}
+			if opts := search.options(); opts.TopK != tt.topK || opts.Threshold != tt.threshold || opts.Transformer != nil {
+				t.Fatalf("options %+v", opts)
+			}
+		})
+	}
+}
+
+func TestStorageFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		storage vecdb.VectorStorage
+	}{
+		{name: "defaults"},
+		{
+			name:    "datatype on disk",
+			args:    []string{"-datatype", "float16", "-on-disk"},
+			storage: vecdb.VectorStorage{Datatype: vecdb.DatatypeFloat16, OnDisk: true},
+		},
+		{
+			name:    "hnsw",
+			args:    []string{"-hnsw-m", "32"},
+			storage: vecdb.VectorStorage{HNSW: &vecdb.HNSWConfig{M: 32}},
+		},
+		{
+			name:    "scalar quantization",
+			args:    []string{"-quantization", "scalar", "-always-ram"},
+			storage: vecdb.VectorStorage{Quantization: &vecdb.QuantizationConfig{Scalar: &vecdb.ScalarQuantization{Type: "int8", AlwaysRAM: true}}},
+		},
+		{
+			name:    "product quantization",
+			args:    []string{"-quantization", "product", "-compression", "x8", "-hnsw-ef-construct", "200"},
+			storage: vecdb.VectorStorage{HNSW: &vecdb.HNSWConfig{EfConstruct: 200}, Quantization: &vecdb.QuantizationConfig{Product: &vecdb.ProductQuantization{Compression: "x8"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := flag.NewFlagSet("test", flag.ContinueOnError)
+			storage := addStorageFlags(flags)
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if s := storage.storage(); !reflect.DeepEqual(s, tt.storage) {
+				t.Fatalf("storage %+v, expected %+v", s, tt.storage)
+			}
+		})
+	}
+}
+
+func TestOptionalBool(t *testing.T) {
+	tests := []struct {
+		args  []string
+		value string
+	}{
+		{nil, ""},
+		{[]string{"-rescore"}, "true"},
+		{[]string{"-rescore=false"}, "false"},
+	}
+	for _, tt := range tests {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		rescore := newOptionalBoolFlag(flags, "rescore", "")
+		if err := flags.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		if rescore.String() != tt.value {
+			t.Errorf("%v: rescore %q, expected %q", tt.args, rescore.String(), tt.value)
+		}
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		list  string
+		items []string
+	}{
+		{"", nil},
+		{" , ", nil},
+		{"text", []string{"text"}},
+		{"text, title,,image ", []string{"text", "title", "image"}},
+	}
+	for _, tt := range tests {
+		if items := splitList(tt.list); !reflect.DeepEqual(items, tt.items) {
+			t.Errorf("splitList(%q) = %q, expected %q", tt.list, items, tt.items)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mateuszmidor/AiStudy/rag/ingest"
+	"github.com/mateuszmidor/AiStudy/rag/server"
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+// collectionsCommand manages the collections: rag collections list|drop|export|import
+func collectionsCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: rag collections list|drop|export|import [flags]")
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "list":
+		listCollectionsCommand(args[1:])
+	case "drop":
+		dropCollectionCommand(args[1:])
+	case "export":
+		exportCollectionCommand(args[1:])
+	case "import":
+		importCollectionCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown collections command %q, use list, drop, export or import\n", args[0])
+		os.Exit(2)
+	}
+}
+
+// listCollectionsCommand prints the collections with their aliases, size and embedding model
+func listCollectionsCommand(args []string) {
+	flags := flag.NewFlagSet("collections list", flag.ExitOnError)
+	output := addOutputFlag(flags)
+	flags.Parse(args)
+	checkOutput(flags, *output)
+
+	collections, err := server.ListCollections(context.Background())
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	if *output == outputJSON {
+		printJSON(collections)
+		return
+	}
+	for _, c := range collections {
+		aliases := ""
+		if len(c.Aliases) > 0 {
+			aliases = " (" + strings.Join(c.Aliases, ", ") + ")"
+		}
+		vectors := fmt.Sprintf("%d dimensions, %s", c.Dimensions, c.Distance)
+		if storage := c.Storage.Describe(); storage != "" {
+			vectors += ", " + storage
+		}
+		if len(c.NamedVectors) > 0 {
+			vectors = describeNamedVectors(c.NamedVectors)
+		}
+		fmt.Printf("%s%s: %d points, %s, model %q, %s\n", c.Name, aliases, c.PointsCount, vectors, c.Model, c.Status)
+	}
+}
+
+// describeNamedVectors lists the named vectors with their size and distance, eg. "vectors text (384, Cosine), title (384, Cosine)"
+func describeNamedVectors(vectors map[string]vecdb.VectorConfig) string {
+	names := make([]string, 0, len(vectors))
+	for name := range vectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	descriptions := make([]string, 0, len(names))
+	for _, name := range names {
+		v := vectors[name]
+		description := fmt.Sprintf("%s (%d, %s", name, v.Size, v.Distance)
+		if storage := v.Describe(); storage != "" {
+			description += ", " + storage
+		}
+		descriptions = append(descriptions, description+")")
+	}
+	return "vectors " + strings.Join(descriptions, ", ")
+}
+
+// dropCollectionCommand deletes the collection provided in args, along with its keyword index and ingestion manifest
+func dropCollectionCommand(args []string) {
+	flags := flag.NewFlagSet("collections drop", flag.ExitOnError)
+	yes := flags.Bool("yes", false, "don't ask for confirmation")
+	manifestPath := flags.String("manifest", "", "manifest file tracking the ingested chunks (default .manifest-<collection>.json)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: rag collections drop [flags] <name>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	name := flags.Arg(0)
+	*manifestPath = manifestPathOr(*manifestPath, name)
+
+	info, err := vecdb.DescribeCollection(context.Background(), name)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	if !*yes && !confirm(fmt.Sprintf("drop collection %s with %d points?", name, info.PointsCount)) {
+		return
+	}
+
+	if err := vecdb.DeleteCollection(context.Background(), name); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// the manifest would make the next ingest skip the chunks as already stored
+	if err := os.Remove(*manifestPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("failed to remove manifest", "path", *manifestPath, "error", err)
+	}
+	fmt.Println("dropped", name)
+}
+
+// snapshotManifest is the snapshot extra holding the ingestion manifest, so that ingest of the restored collection
+// only embeds the changed documents
+const snapshotManifest = "ingest_manifest"
+
+// exportCollectionCommand writes snapshot of the collection provided in args to file, along with its ingestion manifest
+func exportCollectionCommand(args []string) {
+	flags := flag.NewFlagSet("collections export", flag.ExitOnError)
+	manifestPath := flags.String("manifest", "", "manifest file tracking the ingested chunks (default .manifest-<collection>.json)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: rag collections export [flags] <name> <file>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	name, path := flags.Arg(0), flags.Arg(1)
+
+	// attach the manifest if the collection was ingested here
+	extra := map[string]json.RawMessage{}
+	*manifestPath = manifestPathOr(*manifestPath, name)
+	manifest, err := os.ReadFile(*manifestPath)
+	if err == nil {
+		extra[snapshotManifest] = manifest
+	} else if !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("failed to read manifest", "path", *manifestPath, "error", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	snapshot, err := vecdb.ExportCollection(context.Background(), file, name, extra)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		slog.Error("export failed", "collection", name, "error", err)
+		os.Exit(1)
+	}
+	fmt.Printf("exported %s: %d points, model %q, to %s\n", name, snapshot.PointsCount, snapshot.Model, path)
+}
+
+// importCollectionCommand restores collection from the snapshot file provided in args, along with its ingestion manifest
+func importCollectionCommand(args []string) {
+	flags := flag.NewFlagSet("collections import", flag.ExitOnError)
+	collection := flags.String("collection", "", "collection to restore into (default the snapshot's)")
+	replace := flags.Bool("replace", false, "delete the collection first if it exists")
+	manifestPath := flags.String("manifest", "", "manifest file tracking the ingested chunks (default .manifest-<collection>.json)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: rag collections import [flags] <file>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	path := flags.Arg(0)
+
+	file, err := os.Open(path)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	snapshot, err := vecdb.ImportCollection(context.Background(), file, vecdb.ImportOptions{Collection: *collection, Replace: *replace})
+	if err != nil {
+		slog.Error("import failed", "snapshot", path, "error", err)
+		os.Exit(1)
+	}
+
+	// restore the manifest, or remove the stale one that would make the next ingest skip the chunks as already stored
+	if *manifestPath == "" {
+		*manifestPath, err = ingest.DefaultManifestPath(snapshot.Collection)
+	}
+	if err == nil {
+		err = restoreManifest(snapshot, *manifestPath)
+	}
+	if err != nil {
+		slog.Warn("failed to restore manifest", "path", *manifestPath, "error", err)
+	}
+	fmt.Printf("imported %s: %d points, model %q, from %s\n", snapshot.Collection, snapshot.PointsCount, snapshot.Model, path)
+}
+
+// restoreManifest writes the manifest attached to the snapshot, renamed to the restored collection; without manifest
+// in the snapshot, the existing one is removed
+func restoreManifest(snapshot vecdb.Snapshot, path string) error {
+	data, ok := snapshot.Extra[snapshotManifest]
+	if !ok {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	var manifest ingest.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return err
+	}
+	manifest.Collection = snapshot.Collection
+	return manifest.Save(path)
+}
+
+// confirm asks the yes/no question on stdin; no is the default
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	reply, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	reply = strings.ToLower(strings.TrimSpace(reply))
+	return reply == "y" || reply == "yes"
+}
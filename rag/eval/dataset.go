@@ -0,0 +1,72 @@
+package eval
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Case is a single golden question
+type Case struct {
+	ID              string   `json:"id" yaml:"id"`                                                 // empty means the position in the dataset, from 1
+	Question        string   `json:"question" yaml:"question"`                                     // REQUIRED
+	ExpectedSources []string `json:"expected_sources,omitempty" yaml:"expected_sources,omitempty"` // document paths, "path#chunk_index", point ids or exact texts of the relevant chunks
+	ReferenceAnswer string   `json:"reference_answer,omitempty" yaml:"reference_answer,omitempty"`
+}
+
+// LoadDataset reads the cases from YAML (.yaml, .yml: list of cases) or JSONL (.jsonl: one case per line) file
+func LoadDataset(path string) ([]Case, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []Case
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &cases); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+	case ".jsonl":
+		cases, err = parseJSONL(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported dataset format %q, expected .yaml, .yml or .jsonl", filepath.Ext(path))
+	}
+
+	for i := range cases {
+		if strings.TrimSpace(cases[i].Question) == "" {
+			return nil, fmt.Errorf("case %d in %s has no question", i+1, path)
+		}
+		if cases[i].ID == "" {
+			cases[i].ID = fmt.Sprint(i + 1)
+		}
+	}
+	return cases, nil
+}
+
+func parseJSONL(data []byte) ([]Case, error) {
+	var cases []Case
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var c Case
+		if err := json.Unmarshal([]byte(text), &c); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		cases = append(cases, c)
+	}
+	return cases, scanner.Err()
+}
@@ -0,0 +1,337 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mateuszmidor/AiStudy/llm"
+	"github.com/mateuszmidor/AiStudy/rag/answer"
+	"github.com/mateuszmidor/AiStudy/rag/rerank"
+	"github.com/mateuszmidor/AiStudy/rag/transform"
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+const (
+	judgeMaxScore      = 10
+	faithfulnessPrompt = `Instruction: Rate how well the answer is supported by the context, on a scale from 0 (made up, or contradicts the context) to %d (every claim is stated in the context). Reply with the number only.
+Context:
+%s
+Answer: %s
+Rating:`
+	correctnessPrompt = `Instruction: Rate how well the answer agrees with the reference answer to the question, on a scale from 0 (wrong) to %d (same meaning). Reply with the number only.
+Question: %s
+Reference answer: %s
+Answer: %s
+Rating:`
+)
+
+// Options controls the evaluation run
+type Options struct {
+	Query     vecdb.Query           // search mode, filter and weights; the text is set per case
+	Retrieval rerank.Options        // TopK is the k of recall@k
+	Context   answer.ContextOptions // how the retrieved chunks are fitted into the prompt
+	Reranker  rerank.Reranker       // nil means no reranking
+	Generator llm.Generator         // answers the questions; nil skips the generation and the answer metrics
+	Judge     llm.Generator         // rates faithfulness and correctness; nil means Generator
+}
+
+// Report is the result of the evaluation; it is written as JSON so that the runs can be diffed
+type Report struct {
+	Dataset   string       `json:"dataset"`
+	StartedAt time.Time    `json:"started_at"`
+	Setup     Setup        `json:"setup"`
+	Summary   Summary      `json:"summary"`
+	Cases     []CaseResult `json:"cases"`
+}
+
+// Setup records what was evaluated
+type Setup struct {
+	Collection string   `json:"collection"`
+	Embedder   string   `json:"embedder"`
+	Mode       string   `json:"mode"`
+	Vectors    []string `json:"vectors,omitempty"` // named vectors searched
+	TopK       int      `json:"top_k"`
+	Candidates int      `json:"candidates,omitempty"`
+	Threshold  float64  `json:"threshold"`
+	Reranker   string   `json:"reranker,omitempty"`
+	Transform  string   `json:"transform"`
+	MaxTokens  int      `json:"max_tokens,omitempty"` // token budget of the prompt
+	Generator  string   `json:"generator,omitempty"`
+	Judge      string   `json:"judge,omitempty"`
+}
+
+// Summary aggregates the case metrics; each metric is averaged over the cases it could be measured for
+type Summary struct {
+	Cases             int          `json:"cases"`
+	Errors            int          `json:"errors"`
+	RecallAtK         Stats        `json:"recall_at_k"`
+	MRR               Stats        `json:"mrr"`
+	ContextPrecision  Stats        `json:"context_precision"`
+	Faithfulness      Stats        `json:"faithfulness"`
+	Correctness       Stats        `json:"correctness"`
+	RetrievalLatency  LatencyStats `json:"retrieval_latency"`
+	GenerationLatency LatencyStats `json:"generation_latency"`
+	TotalLatency      LatencyStats `json:"total_latency"`
+}
+
+// CaseResult holds the metrics of a single case; metrics that could not be measured are omitted,
+// eg. recall without expected sources or correctness without reference answer
+type CaseResult struct {
+	ID               string      `json:"id"`
+	Question         string      `json:"question"`
+	Retrieved        []Retrieved `json:"retrieved"`
+	RecallAtK        *float64    `json:"recall_at_k,omitempty"`
+	ReciprocalRank   *float64    `json:"reciprocal_rank,omitempty"`
+	ContextPrecision *float64    `json:"context_precision,omitempty"`
+	PromptTokens     int         `json:"prompt_tokens,omitempty"` // approximate, see answer.CountTokens
+	Answer           string      `json:"answer,omitempty"`
+	Faithfulness     *float64    `json:"faithfulness,omitempty"`
+	Correctness      *float64    `json:"correctness,omitempty"`
+	RetrievalMs      float64     `json:"retrieval_ms"`
+	GenerationMs     float64     `json:"generation_ms,omitempty"`
+	Error            string      `json:"error,omitempty"`
+}
+
+// Retrieved describes a retrieved chunk
+type Retrieved struct {
+	ID       string  `json:"id"`
+	Source   string  `json:"source,omitempty"` // "path#chunk_index", or the text if the chunk has no source
+	Score    float64 `json:"score"`
+	Relevant bool    `json:"relevant"`
+}
+
+// Run evaluates the retrieval, and the generation if opts.Generator is set, on every case; failed cases are recorded in the report
+func Run(ctx context.Context, dataset string, cases []Case, opts Options) Report {
+	if opts.Judge == nil {
+		opts.Judge = opts.Generator
+	}
+	report := Report{Dataset: dataset, StartedAt: time.Now().UTC().Truncate(time.Second), Setup: makeSetup(opts)}
+
+	var retrievalTimes, generationTimes, totalTimes []time.Duration
+	for i, c := range cases {
+		slog.Info("evaluating", "case", c.ID, "progress", fmt.Sprintf("%d/%d", i+1, len(cases)))
+		result, retrieval, generation := runCase(ctx, c, opts)
+		report.Cases = append(report.Cases, result)
+		if result.Error != "" {
+			report.Summary.Errors++
+			continue
+		}
+		retrievalTimes = append(retrievalTimes, retrieval)
+		totalTimes = append(totalTimes, retrieval+generation)
+		if opts.Generator != nil {
+			generationTimes = append(generationTimes, generation)
+		}
+	}
+
+	report.Summary.Cases = len(cases)
+	report.Summary.RetrievalLatency = latencyOf(retrievalTimes)
+	report.Summary.GenerationLatency = latencyOf(generationTimes)
+	report.Summary.TotalLatency = latencyOf(totalTimes)
+	var recall, mrr, precision, faithfulness, correctness []*float64
+	for _, r := range report.Cases {
+		recall = append(recall, r.RecallAtK)
+		mrr = append(mrr, r.ReciprocalRank)
+		precision = append(precision, r.ContextPrecision)
+		faithfulness = append(faithfulness, r.Faithfulness)
+		correctness = append(correctness, r.Correctness)
+	}
+	report.Summary.RecallAtK = meanOf(recall)
+	report.Summary.MRR = meanOf(mrr)
+	report.Summary.ContextPrecision = meanOf(precision)
+	report.Summary.Faithfulness = meanOf(faithfulness)
+	report.Summary.Correctness = meanOf(correctness)
+	return report
+}
+
+// runCase evaluates single case, returning also the retrieval and generation durations
+func runCase(ctx context.Context, c Case, opts Options) (CaseResult, time.Duration, time.Duration) {
+	result := CaseResult{ID: c.ID, Question: c.Question}
+
+	// retrieval
+	query := opts.Query
+	query.Text = c.Question
+	start := time.Now()
+	results, err := rerank.Retrieve(ctx, query, opts.Reranker, opts.Retrieval)
+	retrieval := time.Since(start)
+	result.RetrievalMs = milliseconds(retrieval)
+	if err != nil {
+		result.Error = err.Error()
+		return result, retrieval, 0
+	}
+
+	relevant, found := relevance(results, c.ExpectedSources)
+	for i, r := range results {
+		result.Retrieved = append(result.Retrieved, Retrieved{ID: r.ID, Source: describe(r), Score: round(r.Score), Relevant: relevant[i]})
+	}
+	if len(c.ExpectedSources) > 0 {
+		result.RecallAtK = ptr(recallAtK(found, c.ExpectedSources))
+		result.ReciprocalRank = ptr(reciprocalRank(relevant))
+		result.ContextPrecision = ptr(contextPrecision(relevant))
+	}
+	if opts.Generator == nil {
+		return result, retrieval, 0
+	}
+
+	// generation
+	start = time.Now()
+	prompt, err := answer.BuildContext(c.Question, results, opts.Context)
+	if err != nil {
+		result.Error = err.Error()
+		return result, retrieval, 0
+	}
+	result.PromptTokens = prompt.Tokens
+	rsp, err := opts.Generator.Generate(ctx, prompt.Prompt)
+	generation := time.Since(start)
+	result.GenerationMs = milliseconds(generation)
+	if err != nil {
+		result.Error = err.Error()
+		return result, retrieval, generation
+	}
+	result.Answer = answer.Parse(rsp.Text, prompt.Pieces).Text
+
+	// judgement
+	var context []string
+	for _, r := range prompt.Pieces {
+		context = append(context, "- "+r.Text)
+	}
+	faithfulness, err := judge(ctx, opts.Judge, fmt.Sprintf(faithfulnessPrompt, judgeMaxScore, strings.Join(context, "\n"), result.Answer))
+	if err != nil {
+		result.Error = err.Error()
+		return result, retrieval, generation
+	}
+	result.Faithfulness = ptr(faithfulness)
+	if c.ReferenceAnswer != "" {
+		correctness, err := judge(ctx, opts.Judge, fmt.Sprintf(correctnessPrompt, judgeMaxScore, c.Question, c.ReferenceAnswer, result.Answer))
+		if err != nil {
+			result.Error = err.Error()
+			return result, retrieval, generation
+		}
+		result.Correctness = ptr(correctness)
+	}
+	return result, retrieval, generation
+}
+
+// judge asks the judge model for rating, scaled to 0-1
+func judge(ctx context.Context, judge llm.Generator, prompt string) (float64, error) {
+	rsp, err := judge.Generate(ctx, prompt)
+	if err != nil {
+		return 0, fmt.Errorf("judging: %w", err)
+	}
+	score, err := rerank.ParseRating(rsp.Text, judgeMaxScore)
+	if err != nil {
+		return 0, err
+	}
+	return round(score), nil
+}
+
+// WriteJSON writes the report as indented JSON
+func (r Report) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// String renders the summary as text
+func (s Summary) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "cases: %d, errors: %d\n", s.Cases, s.Errors)
+	fmt.Fprintf(&b, "recall@k:          %.3f (%d cases)\n", s.RecallAtK.Mean, s.RecallAtK.Count)
+	fmt.Fprintf(&b, "MRR:               %.3f (%d cases)\n", s.MRR.Mean, s.MRR.Count)
+	fmt.Fprintf(&b, "context precision: %.3f (%d cases)\n", s.ContextPrecision.Mean, s.ContextPrecision.Count)
+	fmt.Fprintf(&b, "faithfulness:      %.3f (%d cases)\n", s.Faithfulness.Mean, s.Faithfulness.Count)
+	fmt.Fprintf(&b, "correctness:       %.3f (%d cases)\n", s.Correctness.Mean, s.Correctness.Count)
+	fmt.Fprintf(&b, "retrieval latency:  mean %.0fms, p50 %.0fms, p95 %.0fms\n", s.RetrievalLatency.Mean, s.RetrievalLatency.P50, s.RetrievalLatency.P95)
+	fmt.Fprintf(&b, "generation latency: mean %.0fms, p50 %.0fms, p95 %.0fms\n", s.GenerationLatency.Mean, s.GenerationLatency.P50, s.GenerationLatency.P95)
+	fmt.Fprintf(&b, "total latency:      mean %.0fms, p50 %.0fms, p95 %.0fms", s.TotalLatency.Mean, s.TotalLatency.P50, s.TotalLatency.P95)
+	return b.String()
+}
+
+// Compare renders the summaries of the reports side by side, one column per query transformation
+func Compare(reports []Report) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	row := func(name string, value func(s Summary) string) {
+		fmt.Fprint(w, name)
+		for _, r := range reports {
+			fmt.Fprintf(w, "\t%s", value(r.Summary))
+		}
+		fmt.Fprintln(w)
+	}
+	mean := func(stats func(s Summary) Stats) func(s Summary) string {
+		return func(s Summary) string {
+			if stats(s).Count == 0 {
+				return "-" // not measured
+			}
+			return fmt.Sprintf("%.3f", stats(s).Mean)
+		}
+	}
+	ms := func(v float64) string { return fmt.Sprintf("%.0fms", v) }
+
+	fmt.Fprint(w, "transform")
+	for _, r := range reports {
+		fmt.Fprintf(w, "\t%s", r.Setup.Transform)
+	}
+	fmt.Fprintln(w)
+	row("errors", func(s Summary) string { return fmt.Sprint(s.Errors) })
+	row("recall@k", mean(func(s Summary) Stats { return s.RecallAtK }))
+	row("MRR", mean(func(s Summary) Stats { return s.MRR }))
+	row("context precision", mean(func(s Summary) Stats { return s.ContextPrecision }))
+	row("faithfulness", mean(func(s Summary) Stats { return s.Faithfulness }))
+	row("correctness", mean(func(s Summary) Stats { return s.Correctness }))
+	row("retrieval p50", func(s Summary) string { return ms(s.RetrievalLatency.P50) })
+	row("retrieval p95", func(s Summary) string { return ms(s.RetrievalLatency.P95) })
+	row("total p50", func(s Summary) string { return ms(s.TotalLatency.P50) })
+	row("total p95", func(s Summary) string { return ms(s.TotalLatency.P95) })
+	w.Flush()
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func makeSetup(opts Options) Setup {
+	setup := Setup{
+		Collection: opts.Query.Collection,
+		Embedder:   vecdb.EmbedderModel(),
+		Mode:       opts.Query.Mode,
+		Vectors:    opts.Query.Using,
+		TopK:       opts.Retrieval.TopK,
+		Threshold:  opts.Retrieval.Threshold,
+		Transform:  transform.Name(opts.Retrieval.Transformer),
+	}
+	if opts.Generator != nil {
+		setup.MaxTokens = opts.Context.MaxTokens
+	}
+	if setup.Collection == "" {
+		setup.Collection = vecdb.CollectionName()
+	}
+	if setup.Mode == "" {
+		setup.Mode = vecdb.SearchVector
+	}
+	if opts.Reranker != nil {
+		setup.Reranker = opts.Reranker.Name()
+		setup.Candidates = opts.Retrieval.Candidates
+	}
+	if opts.Generator != nil {
+		setup.Generator = opts.Generator.Model()
+		setup.Judge = opts.Judge.Model()
+	}
+	return setup
+}
+
+// describe returns "path#chunk_index" of the chunk, or its text if the chunk has no source
+func describe(r vecdb.SearchResult) string {
+	if source := r.PayloadString("source"); source != "" {
+		return fmt.Sprintf("%s#%s", source, r.PayloadString("chunk_index"))
+	}
+	return r.Text
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
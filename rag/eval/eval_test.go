@@ -0,0 +1,172 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mateuszmidor/AiStudy/llm"
+	"github.com/mateuszmidor/AiStudy/rag/rerank"
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+func TestJudge(t *testing.T) {
+	tests := []struct {
+		reply string
+		err   error
+		score float64
+		valid bool
+	}{
+		{reply: "8", score: 0.8, valid: true},
+		{reply: "Rating: 7/10", score: 0.7, valid: true},
+		{reply: "The answer is fully supported. 10", score: 1, valid: true},
+		{reply: "2 out of 3", score: 0.6667, valid: true},
+		{reply: "supported"},
+		{err: errors.New("judge unreachable")},
+	}
+	for _, tt := range tests {
+		judgeModel := fakeGenerator(func(prompt string) (string, error) { return tt.reply, tt.err })
+		score, err := judge(context.Background(), judgeModel, "Rating:")
+		if (err == nil) != tt.valid {
+			t.Errorf("judge(%q, %v): error %v, expected valid %v", tt.reply, tt.err, err, tt.valid)
+			continue
+		}
+		if math.Abs(score-tt.score) > 1e-9 {
+			t.Errorf("judge(%q) = %v, expected %v", tt.reply, score, tt.score)
+		}
+	}
+}
+
+func TestLoadDataset(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"cases.yaml":   "- question: What is Go?\n  expected_sources: [go.md]\n- id: rust\n  question: What is Rust?\n  reference_answer: A language.\n",
+		"cases.jsonl":  "{\"question\":\"What is Go?\",\"expected_sources\":[\"go.md\"]}\n\n{\"id\":\"rust\",\"question\":\"What is Rust?\",\"reference_answer\":\"A language.\"}\n",
+		"empty.yaml":   "- question: What is Go?\n- id: blank\n  question: \" \"\n",
+		"broken.jsonl": "{\"question\":\n",
+		"cases.csv":    "question\nWhat is Go?\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []Case{
+		{ID: "1", Question: "What is Go?", ExpectedSources: []string{"go.md"}},
+		{ID: "rust", Question: "What is Rust?", ReferenceAnswer: "A language."},
+	}
+
+	tests := []struct {
+		file  string
+		cases []Case
+		err   string
+	}{
+		{file: "cases.yaml", cases: expected},
+		{file: "cases.jsonl", cases: expected},
+		{file: "empty.yaml", err: "case 2"},
+		{file: "broken.jsonl", err: "line 1"},
+		{file: "cases.csv", err: "unsupported"},
+		{file: "missing.yaml", err: "no such file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			cases, err := LoadDataset(filepath.Join(dir, tt.file))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, expected %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cases, tt.cases) {
+				t.Fatalf("cases %+v, expected %+v", cases, tt.cases)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	vecdb.SetEmbedder(vecdb.NewFakeEmbedder(0))
+	vecdb.SetStore(vecdb.NewEmbeddedStore(dir))
+	vecdb.SetKeywordIndexDir(dir)
+	knowledge := []string{"Go has goroutines.", "Rust guarantees ownership.", "Python yields generators."}
+	if _, err := vecdb.FeedDBContext(context.Background(), knowledge, vecdb.FeedOptions{Collection: "knowledge"}); err != nil {
+		t.Fatal(err)
+	}
+
+	generator := fakeGenerator(func(prompt string) (string, error) {
+		if strings.Contains(prompt, "Question: Who yields?") {
+			return "Nobody knows.", nil
+		}
+		return "Go has goroutines [1].", nil
+	})
+	judgeModel := fakeGenerator(func(prompt string) (string, error) {
+		switch {
+		case strings.Contains(prompt, "Answer: Nobody knows."):
+			return "no idea", nil
+		case strings.Contains(prompt, "Reference answer:"):
+			return "Rating: 5/10", nil
+		default:
+			return "9", nil
+		}
+	})
+	cases := []Case{
+		{ID: "go", Question: "Which has goroutines?", ExpectedSources: []string{knowledge[0]}, ReferenceAnswer: "Go."},
+		{ID: "rust", Question: "Which guarantees ownership?", ExpectedSources: []string{knowledge[0], knowledge[1]}},
+		{ID: "python", Question: "Who yields?"},
+	}
+	opts := Options{
+		Query:     vecdb.Query{Collection: "knowledge", Mode: vecdb.SearchKeyword},
+		Retrieval: rerank.Options{TopK: 1},
+		Generator: generator,
+		Judge:     judgeModel,
+	}
+
+	report := Run(context.Background(), "golden.yaml", cases, opts)
+	summary := report.Summary
+	if summary.Cases != 3 || summary.Errors != 1 {
+		t.Fatalf("summary %+v, expected 3 cases with 1 error", summary)
+	}
+	if summary.RecallAtK != (Stats{Count: 2, Mean: 0.75}) || summary.MRR != (Stats{Count: 2, Mean: 1}) {
+		t.Fatalf("retrieval metrics %+v %+v", summary.RecallAtK, summary.MRR)
+	}
+	if summary.Faithfulness != (Stats{Count: 2, Mean: 0.9}) || summary.Correctness != (Stats{Count: 1, Mean: 0.5}) {
+		t.Fatalf("answer metrics %+v %+v", summary.Faithfulness, summary.Correctness)
+	}
+	if report.Cases[2].Error == "" || report.Cases[2].Answer != "Nobody knows." {
+		t.Fatalf("failed judgement not recorded: %+v", report.Cases[2])
+	}
+	if report.Cases[1].Correctness != nil {
+		t.Fatalf("correctness measured without reference answer: %+v", report.Cases[1])
+	}
+	expected := Setup{Collection: "knowledge", Embedder: vecdb.EmbedderModel(), Mode: vecdb.SearchKeyword, TopK: 1, Transform: "none", Generator: "fake", Judge: "fake"}
+	if !reflect.DeepEqual(report.Setup, expected) {
+		t.Fatalf("setup %+v, expected %+v", report.Setup, expected)
+	}
+}
+
+// fakeGenerator replies to the prompt with the func result
+type fakeGenerator func(prompt string) (string, error)
+
+func (g fakeGenerator) Generate(ctx context.Context, prompt string) (llm.Response, error) {
+	text, err := g(prompt)
+	return llm.Response{Text: text}, err
+}
+
+func (g fakeGenerator) GenerateStream(ctx context.Context, prompt string, onToken func(token string)) (llm.Response, error) {
+	rsp, err := g.Generate(ctx, prompt)
+	onToken(rsp.Text)
+	return rsp, err
+}
+
+func (g fakeGenerator) Model() string {
+	return "fake"
+}
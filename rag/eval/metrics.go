@@ -0,0 +1,130 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+// isRelevant tells if the retrieved result is one of the expected sources: its document path, "path#chunk_index", point id or exact text
+func isRelevant(r vecdb.SearchResult, expected string) bool {
+	source := r.PayloadString("source")
+	return expected == r.ID ||
+		expected == r.Text ||
+		(source != "" && (expected == source || expected == fmt.Sprintf("%s#%s", source, r.PayloadString("chunk_index"))))
+}
+
+// relevance returns for every result whether it is relevant, and which expected sources were found among the results
+func relevance(results []vecdb.SearchResult, expected []string) (relevant []bool, found map[string]bool) {
+	found = map[string]bool{}
+	for _, r := range results {
+		hit := false
+		for _, e := range expected {
+			if isRelevant(r, e) {
+				found[e] = true
+				hit = true
+			}
+		}
+		relevant = append(relevant, hit)
+	}
+	return relevant, found
+}
+
+// recallAtK is the fraction of expected sources found in the results
+func recallAtK(found map[string]bool, expected []string) float64 {
+	return float64(len(found)) / float64(len(expected))
+}
+
+// reciprocalRank is 1/rank of the first relevant result, 0 if none
+func reciprocalRank(relevant []bool) float64 {
+	for i, r := range relevant {
+		if r {
+			return 1 / float64(i+1)
+		}
+	}
+	return 0
+}
+
+// contextPrecision is the mean of precision@i over the positions i of relevant results, so it rewards ranking the relevant results first; 0 if none
+func contextPrecision(relevant []bool) float64 {
+	var sum float64
+	hits := 0
+	for i, r := range relevant {
+		if r {
+			hits++
+			sum += float64(hits) / float64(i+1)
+		}
+	}
+	if hits == 0 {
+		return 0
+	}
+	return sum / float64(hits)
+}
+
+// Stats summarizes the metric over the cases it was measured for
+type Stats struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean"`
+}
+
+// LatencyStats summarizes the latencies, in milliseconds
+type LatencyStats struct {
+	Mean float64 `json:"mean_ms"`
+	P50  float64 `json:"p50_ms"`
+	P95  float64 `json:"p95_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+// meanOf summarizes the values, skipping nil ones (not measured)
+func meanOf(values []*float64) Stats {
+	var s Stats
+	var sum float64
+	for _, v := range values {
+		if v != nil {
+			s.Count++
+			sum += *v
+		}
+	}
+	if s.Count > 0 {
+		s.Mean = round(sum / float64(s.Count))
+	}
+	return s
+}
+
+// latencyOf summarizes the durations
+func latencyOf(durations []time.Duration) LatencyStats {
+	if len(durations) == 0 {
+		return LatencyStats{}
+	}
+	ms := make([]float64, 0, len(durations))
+	var sum float64
+	for _, d := range durations {
+		ms = append(ms, milliseconds(d))
+		sum += milliseconds(d)
+	}
+	sort.Float64s(ms)
+	return LatencyStats{
+		Mean: round(sum / float64(len(ms))),
+		P50:  percentile(ms, 0.50),
+		P95:  percentile(ms, 0.95),
+		Max:  ms[len(ms)-1],
+	}
+}
+
+// percentile of the sorted values, nearest rank method
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return round(float64(d) / float64(time.Millisecond))
+}
+
+// round to 4 decimal places, so that the reports diff cleanly
+func round(v float64) float64 {
+	return math.Round(v*1e4) / 1e4
+}
@@ -0,0 +1,73 @@
+package eval
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+func TestRetrievalMetrics(t *testing.T) {
+	results := []vecdb.SearchResult{
+		{ID: "1", Text: "Go has goroutines.", Payload: map[string]interface{}{"source": "go.md", "chunk_index": 0}},
+		{ID: "2", Text: "Rust guarantees memory safety.", Payload: map[string]interface{}{"source": "rust.md", "chunk_index": 3}},
+		{ID: "3", Text: "Python is dynamically typed."},
+	}
+
+	tests := []struct {
+		name      string
+		expected  []string
+		relevant  []bool
+		recall    float64
+		rr        float64
+		precision float64
+	}{
+		{"by source", []string{"go.md"}, []bool{true, false, false}, 1, 1, 1},
+		{"by chunk", []string{"rust.md#3", "rust.md#4"}, []bool{false, true, false}, 0.5, 0.5, 0.5},
+		{"by id and text", []string{"3", "Go has goroutines."}, []bool{true, false, true}, 1, 1, (1 + 2.0/3) / 2},
+		{"not found", []string{"java.md"}, []bool{false, false, false}, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relevant, found := relevance(results, tt.expected)
+			if !reflect.DeepEqual(relevant, tt.relevant) {
+				t.Fatalf("relevant %v, expected %v", relevant, tt.relevant)
+			}
+			for metric, values := range map[string][2]float64{
+				"recall@k":          {recallAtK(found, tt.expected), tt.recall},
+				"reciprocal rank":   {reciprocalRank(relevant), tt.rr},
+				"context precision": {contextPrecision(relevant), tt.precision},
+			} {
+				if math.Abs(values[0]-values[1]) > 1e-9 {
+					t.Errorf("%s = %v, expected %v", metric, values[0], values[1])
+				}
+			}
+		})
+	}
+}
+
+func TestMeanOf(t *testing.T) {
+	stats := meanOf([]*float64{ptr(1), nil, ptr(0), ptr(0.5)})
+	if stats != (Stats{Count: 3, Mean: 0.5}) {
+		t.Fatalf("stats %+v", stats)
+	}
+	if stats := meanOf([]*float64{nil}); stats != (Stats{}) {
+		t.Fatalf("stats of nothing %+v", stats)
+	}
+}
+
+func TestLatencyOf(t *testing.T) {
+	var durations []time.Duration
+	for i := 20; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+	expected := LatencyStats{Mean: 10.5, P50: 10, P95: 19, Max: 20}
+	if stats := latencyOf(durations); stats != expected {
+		t.Fatalf("stats %+v, expected %+v", stats, expected)
+	}
+	if stats := latencyOf(nil); stats != (LatencyStats{}) {
+		t.Fatalf("stats of nothing %+v", stats)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mateuszmidor/AiStudy/llm"
+	"github.com/mateuszmidor/AiStudy/rag/eval"
+	"github.com/mateuszmidor/AiStudy/rag/rerank"
+)
+
+// evalCommand runs the golden questions from the dataset provided in args and reports the retrieval and answer quality
+func evalCommand(args []string) {
+	flags := flag.NewFlagSet("eval", flag.ExitOnError)
+	search := addRetrievalFlags(flags) // -k is also the k of recall@k; -transform can list strategies to compare, eg. none,hyde
+	model := addModelFlag(flags)
+	prompting := addContextFlags(flags)
+	output := addOutputFlag(flags)
+	out := flags.String("out", "eval-report.json", "where to write the JSON report")
+	noGenerate := flags.Bool("no-generate", false, "evaluate the retrieval only, skip the answers and their judgement")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: rag eval [flags] <dataset.yaml|dataset.jsonl>")
+		fmt.Fprintln(flags.Output(), "the answers are judged by RAG_JUDGE_PROVIDER/RAG_JUDGE_MODEL if set, by the RAG_LLM model otherwise")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	checkOutput(flags, *output)
+
+	cases, err := eval.LoadDataset(flags.Arg(0))
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	setupGenerator(*model) // also transforms the queries
+	opts := eval.Options{
+		Query:    search.query(""),
+		Reranker: reranker,
+		Context:  prompting.options(),
+	}
+	if !*noGenerate {
+		opts.Generator = generator
+		opts.Judge, err = newJudge()
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	// evaluate every query transformation, each with its own report
+	strategies := strings.Split(*search.transform, ",")
+	var reports []eval.Report
+	for _, strategy := range strategies {
+		opts.Retrieval = search.optionsWith(strings.TrimSpace(strategy))
+		report := eval.Run(context.Background(), flags.Arg(0), cases, opts)
+		path := *out
+		if len(strategies) > 1 {
+			path = strings.TrimSuffix(path, filepath.Ext(path)) + "-" + report.Setup.Transform + filepath.Ext(path)
+		}
+		if err := report.WriteJSON(path); err != nil {
+			slog.Error("failed to write report", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("report written", "path", path)
+		reports = append(reports, report)
+	}
+
+	switch {
+	case *output == outputJSON && len(reports) == 1:
+		printJSON(reports[0])
+	case *output == outputJSON:
+		printJSON(reports)
+	case len(reports) == 1:
+		fmt.Println(reports[0].Summary)
+	default:
+		fmt.Println(eval.Compare(reports))
+	}
+}
+
+// newJudge creates the model judging the answers from RAG_JUDGE_* environment variables; nil if not configured
+func newJudge() (llm.Generator, error) {
+	cfg := llm.ConfigFromEnv("RAG_JUDGE")
+	if cfg.Provider == "" && cfg.Model == "" {
+		return nil, nil
+	}
+	return llm.New(cfg, llm.WithTemperature(0), llm.WithMaxTokens(rerank.JudgeMaxTokens), llm.WithTimeout(5*time.Minute), llm.WithRetry(3, time.Second))
+}
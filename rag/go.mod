@@ -1,3 +1,20 @@
 module github.com/mateuszmidor/AiStudy/rag
 
-go 1.21
+go 1.24.0
+
+require (
+	github.com/mateuszmidor/AiStudy/llm v0.0.0
+	github.com/qdrant/go-client v1.16.2
+	google.golang.org/grpc v1.76.0
+	google.golang.org/protobuf v1.36.10
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	golang.org/x/net v0.47.0 // indirect
+	golang.org/x/sys v0.38.0 // indirect
+	golang.org/x/text v0.31.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20251111163417-95abcf5c77ba // indirect
+)
+
+replace github.com/mateuszmidor/AiStudy/llm => ../llm
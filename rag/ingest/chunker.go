@@ -0,0 +1,221 @@
+package ingest
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Chunk is a piece of document text; Start and End are character (rune) offsets in the document text
+type Chunk struct {
+	Index   int    // position of the chunk in the document
+	Text    string // chunk content
+	Start   int    // offset of the first character
+	End     int    // offset past the last character
+	Heading string // optional, the heading of the section the chunk comes from
+}
+
+// Chunker splits document text into chunks
+type Chunker interface {
+	Chunk(text string) []Chunk
+}
+
+// Chunking strategies accepted by NewChunker
+const (
+	StrategyFixed    = "fixed"
+	StrategySentence = "sentence"
+	StrategyHeading  = "heading"
+)
+
+// NewChunker creates Chunker for the strategy; size is max chunk length in characters,
+// overlap is the number of characters (fixed, heading) or sentences (sentence) repeated between neighbouring chunks
+func NewChunker(strategy string, size, overlap int) (Chunker, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("chunk size must be positive, got %d", size)
+	}
+	if overlap < 0 {
+		return nil, fmt.Errorf("chunk overlap can't be negative, got %d", overlap)
+	}
+
+	switch strategy {
+	case StrategyFixed:
+		if overlap >= size {
+			return nil, fmt.Errorf("chunk overlap %d must be smaller than chunk size %d", overlap, size)
+		}
+		return &FixedSizeChunker{Size: size, Overlap: overlap}, nil
+	case StrategySentence:
+		return &SentenceChunker{MaxSize: size, OverlapSentences: overlap}, nil
+	case StrategyHeading:
+		if overlap >= size {
+			return nil, fmt.Errorf("chunk overlap %d must be smaller than chunk size %d", overlap, size)
+		}
+		return &HeadingChunker{MaxSize: size, Overlap: overlap}, nil
+	default:
+		return nil, fmt.Errorf("unknown chunking strategy %q, expected one of: %s, %s, %s", strategy, StrategyFixed, StrategySentence, StrategyHeading)
+	}
+}
+
+// FixedSizeChunker cuts the text every Size characters, neighbouring chunks share Overlap characters
+type FixedSizeChunker struct {
+	Size    int
+	Overlap int
+}
+
+// Chunk implements Chunker
+func (c *FixedSizeChunker) Chunk(text string) []Chunk {
+	return numbered(c.split([]rune(text), 0, len([]rune(text))))
+}
+
+// split cuts runes[from:to] into fixed size chunks
+func (c *FixedSizeChunker) split(runes []rune, from, to int) (chunks []Chunk) {
+	step := max(c.Size-c.Overlap, 1)
+	for start := from; start < to; start += step {
+		end := min(start+c.Size, to)
+		if chunk, ok := makeChunk(runes, start, end); ok {
+			chunks = append(chunks, chunk)
+		}
+		if end == to {
+			break
+		}
+	}
+	return chunks
+}
+
+// SentenceChunker groups whole sentences into chunks of up to MaxSize characters, a sentence longer than MaxSize is cut into fixed size chunks;
+// the last OverlapSentences sentences of a chunk are repeated at the beginning of the next one
+type SentenceChunker struct {
+	MaxSize          int
+	OverlapSentences int
+}
+
+// Chunk implements Chunker
+func (c *SentenceChunker) Chunk(text string) []Chunk {
+	runes := []rune(text)
+	return numbered(c.split(runes, 0, len(runes)))
+}
+
+// split groups the sentences of runes[from:to] into chunks
+func (c *SentenceChunker) split(runes []rune, from, to int) (chunks []Chunk) {
+	sentences := splitSentences(runes, from, to)
+	for first := 0; first < len(sentences); {
+		// take as many sentences as fit, but always at least one; the one that doesn't fit alone is cut below
+		last := first
+		for last+1 < len(sentences) && sentences[last+1].end-sentences[first].start <= c.MaxSize {
+			last++
+		}
+		if chunk, ok := makeChunk(runes, sentences[first].start, sentences[last].end); ok {
+			if chunk.End-chunk.Start > c.MaxSize {
+				// single sentence too long to fit, eg. code or table without punctuation, is cut like by FixedSizeChunker
+				fixed := FixedSizeChunker{Size: c.MaxSize}
+				chunks = append(chunks, fixed.split(runes, chunk.Start, chunk.End)...)
+			} else {
+				chunks = append(chunks, chunk)
+			}
+		}
+		if last == len(sentences)-1 {
+			break
+		}
+		next := last + 1
+		first = max(next-c.OverlapSentences, first+1)
+		if sentences[next].end-sentences[first].start > c.MaxSize {
+			first = next // no room for overlap, otherwise the next chunk would repeat only the already stored sentences
+		}
+	}
+	return chunks
+}
+
+// span is a range of runes
+type span struct {
+	start int
+	end   int
+}
+
+// splitSentences finds sentence boundaries: terminal punctuation followed by whitespace, or a blank line
+func splitSentences(runes []rune, from, to int) (sentences []span) {
+	start := from
+	for i := from; i < to; i++ {
+		endOfSentence := strings.ContainsRune(".!?", runes[i]) && (i+1 == to || unicode.IsSpace(runes[i+1]))
+		endOfParagraph := runes[i] == '\n' && i+1 < to && runes[i+1] == '\n'
+		if endOfSentence || endOfParagraph {
+			sentences = append(sentences, span{start: start, end: i + 1})
+			start = i + 1
+		}
+	}
+	if start < to {
+		sentences = append(sentences, span{start: start, end: to})
+	}
+	return sentences
+}
+
+// HeadingChunker splits markdown text into sections at "#" headings;
+// sections longer than MaxSize are further cut into fixed size chunks sharing Overlap characters
+type HeadingChunker struct {
+	MaxSize int
+	Overlap int
+}
+
+// Chunk implements Chunker
+func (c *HeadingChunker) Chunk(text string) []Chunk {
+	runes := []rune(text)
+	fixed := FixedSizeChunker{Size: c.MaxSize, Overlap: c.Overlap}
+
+	var chunks []Chunk
+	for _, section := range splitSections(runes) {
+		for _, chunk := range fixed.split(runes, section.start, section.end) {
+			chunk.Heading = section.heading
+			chunks = append(chunks, chunk)
+		}
+	}
+	return numbered(chunks)
+}
+
+// section is a part of markdown document that starts with a heading
+type section struct {
+	span
+	heading string
+}
+
+// splitSections finds the lines starting with "#" and splits runes into sections starting at these lines
+func splitSections(runes []rune) (sections []section) {
+	current := section{}
+	lineStart := 0
+	for i := 0; i <= len(runes); i++ {
+		if i < len(runes) && runes[i] != '\n' {
+			continue
+		}
+		line := strings.TrimSpace(string(runes[lineStart:i]))
+		if strings.HasPrefix(line, "#") && lineStart > current.start {
+			current.end = lineStart
+			sections = append(sections, current)
+			current = section{span: span{start: lineStart}}
+		}
+		if strings.HasPrefix(line, "#") {
+			current.heading = strings.TrimSpace(strings.TrimLeft(line, "#"))
+		}
+		lineStart = i + 1
+	}
+	current.end = len(runes)
+	return append(sections, current)
+}
+
+// makeChunk creates chunk of runes[start:end] with surrounding whitespace trimmed; returns false for blank chunk
+func makeChunk(runes []rune, start, end int) (Chunk, bool) {
+	for start < end && unicode.IsSpace(runes[start]) {
+		start++
+	}
+	for end > start && unicode.IsSpace(runes[end-1]) {
+		end--
+	}
+	if start == end {
+		return Chunk{}, false
+	}
+	return Chunk{Text: string(runes[start:end]), Start: start, End: end}, true
+}
+
+// numbered sets the chunk indexes according to their order
+func numbered(chunks []Chunk) []Chunk {
+	for i := range chunks {
+		chunks[i].Index = i
+	}
+	return chunks
+}
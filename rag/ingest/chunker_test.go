@@ -0,0 +1,167 @@
+package ingest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewChunker(t *testing.T) {
+	tests := []struct {
+		strategy string
+		size     int
+		overlap  int
+		valid    bool
+	}{
+		{StrategyFixed, 100, 10, true},
+		{StrategySentence, 100, 2, true},
+		{StrategyHeading, 100, 10, true},
+		{StrategySentence, 100, 200, true}, // overlap in sentences, not limited by size
+		{StrategyFixed, 0, 0, false},
+		{StrategyFixed, 100, -1, false},
+		{StrategyFixed, 100, 100, false},
+		{StrategyHeading, 100, 150, false},
+		{"paragraph", 100, 10, false},
+	}
+	for _, tt := range tests {
+		_, err := NewChunker(tt.strategy, tt.size, tt.overlap)
+		if (err == nil) != tt.valid {
+			t.Errorf("NewChunker(%q, %d, %d): error %v, expected valid %v", tt.strategy, tt.size, tt.overlap, err, tt.valid)
+		}
+	}
+}
+
+func TestFixedSizeChunker(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		size    int
+		overlap int
+		chunks  []string
+	}{
+		{"shorter than size", "abc", 10, 2, []string{"abc"}},
+		{"exact size", "abcdef", 3, 0, []string{"abc", "def"}},
+		{"overlap", "abcdefg", 4, 2, []string{"abcd", "cdef", "efg"}},
+		{"whitespace trimmed", "ab  cd", 3, 0, []string{"ab", "cd"}},
+		{"blank chunk dropped", "ab     cd", 3, 0, []string{"ab", "cd"}},
+		{"multibyte runes", "zażółć", 3, 0, []string{"zaż", "ółć"}},
+		{"empty", "", 3, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := (&FixedSizeChunker{Size: tt.size, Overlap: tt.overlap}).Chunk(tt.text)
+			checkChunks(t, tt.text, chunks, tt.size)
+			if texts := chunkTexts(chunks); !reflect.DeepEqual(texts, tt.chunks) {
+				t.Fatalf("chunks %q, expected %q", texts, tt.chunks)
+			}
+		})
+	}
+}
+
+func TestSentenceChunker(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		size     int
+		overlap  int
+		expected []string
+	}{
+		{
+			name:     "sentences grouped",
+			text:     "One. Two. Three. Four.",
+			size:     10,
+			expected: []string{"One. Two.", "Three.", "Four."},
+		},
+		{
+			name:     "overlap sentence repeated",
+			text:     "One. Two. Three.",
+			size:     12,
+			overlap:  1,
+			expected: []string{"One. Two.", "Two. Three."},
+		},
+		{
+			name:     "no room for overlap",
+			text:     "One. Seventeen.",
+			size:     10,
+			overlap:  1,
+			expected: []string{"One.", "Seventeen."},
+		},
+		{
+			name:     "paragraphs",
+			text:     "first paragraph\n\nsecond",
+			size:     16,
+			expected: []string{"first paragraph", "second"},
+		},
+		{
+			name:     "sentence longer than size cut",
+			text:     "Short. abcdefghijklmnop! Next.",
+			size:     8,
+			expected: []string{"Short.", "abcdefgh", "ijklmnop", "!", "Next."},
+		},
+		{
+			name:     "unpunctuated text cut",
+			text:     strings.Repeat("x", 25),
+			size:     10,
+			overlap:  2,
+			expected: []string{strings.Repeat("x", 10), strings.Repeat("x", 10), strings.Repeat("x", 5)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := (&SentenceChunker{MaxSize: tt.size, OverlapSentences: tt.overlap}).Chunk(tt.text)
+			checkChunks(t, tt.text, chunks, tt.size)
+			if texts := chunkTexts(chunks); !reflect.DeepEqual(texts, tt.expected) {
+				t.Fatalf("chunks %q, expected %q", texts, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHeadingChunker(t *testing.T) {
+	text := "intro\n# Go\ngoroutines\n## Channels\nchan int and select\n# Rust\nownership"
+	chunks := (&HeadingChunker{MaxSize: 12, Overlap: 0}).Chunk(text)
+	checkChunks(t, text, chunks, 12)
+
+	expected := []struct{ heading, text string }{
+		{"", "intro"},
+		{"Go", "# Go\ngorouti"},
+		{"Go", "nes"},
+		{"Channels", "## Channels"},
+		{"Channels", "chan int and"},
+		{"Channels", "select"},
+		{"Rust", "# Rust\nowner"},
+		{"Rust", "ship"},
+	}
+	if len(chunks) != len(expected) {
+		t.Fatalf("chunks %q, expected %d", chunkTexts(chunks), len(expected))
+	}
+	for i, e := range expected {
+		if chunks[i].Heading != e.heading || chunks[i].Text != e.text {
+			t.Errorf("chunk %d: %q under %q, expected %q under %q", i, chunks[i].Text, chunks[i].Heading, e.text, e.heading)
+		}
+	}
+}
+
+// checkChunks verifies the invariants of every chunker: chunks numbered in order, no longer than size, and their offsets pointing at their text
+func checkChunks(t *testing.T, text string, chunks []Chunk, size int) {
+	t.Helper()
+	runes := []rune(text)
+	for i, c := range chunks {
+		if c.Index != i {
+			t.Errorf("chunk %d has index %d", i, c.Index)
+		}
+		if n := len([]rune(c.Text)); n > size {
+			t.Errorf("chunk %d has %d characters, max is %d: %q", i, n, size, c.Text)
+		}
+		if c.Start < 0 || c.End > len(runes) || string(runes[c.Start:c.End]) != c.Text {
+			t.Errorf("chunk %d [%d:%d] doesn't point at its text %q", i, c.Start, c.End, c.Text)
+		}
+	}
+}
+
+func chunkTexts(chunks []Chunk) (texts []string) {
+	for _, c := range chunks {
+		texts = append(texts, c.Text)
+	}
+	return texts
+}
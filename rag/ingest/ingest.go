@@ -0,0 +1,121 @@
+package ingest
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+// Payload keys of the stored chunks
+const (
+	PayloadSource     = "source"      // path of the source document
+	PayloadChunkIndex = "chunk_index" // position of the chunk in the source document
+	PayloadStart      = "start"       // character offset of the chunk start in the source document
+	PayloadEnd        = "end"         // character offset of the chunk end in the source document
+	PayloadHeading    = "heading"     // heading of the section the chunk comes from, heading-aware chunking only
+	PayloadModifiedAt = "modified_at" // RFC 3339 modification time of the source document, for date filtering
+)
+
+// Options controls the ingestion
+type Options struct {
+	Chunker  Chunker
+	Metadata map[string]interface{} // optional, stored in the payload of every chunk, eg. {"lang": "en", "tags": ["manual"]}
+	Feed     vecdb.FeedOptions
+}
+
+// Ingest loads the documents found under root, splits them into chunks and stores the chunks in vector database
+func Ingest(ctx context.Context, root string, opts Options) (vecdb.FeedReport, error) {
+	chunks, err := LoadChunks(root, opts)
+	if err != nil {
+		return vecdb.FeedReport{}, err
+	}
+	return vecdb.FeedDocumentsContext(ctx, chunks, opts.Feed)
+}
+
+// LoadChunks loads the documents found under root and splits them into chunks
+func LoadChunks(root string, opts Options) ([]vecdb.Document, error) {
+	docs, err := LoadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("loaded documents", "root", root, "count", len(docs))
+
+	chunks := ChunkDocuments(docs, opts.Chunker, opts.Metadata)
+	slog.Debug("split documents into chunks", "count", len(chunks))
+	return chunks, nil
+}
+
+// ChunkDocuments splits the documents into chunks, ready to be stored in vector database along with the metadata
+func ChunkDocuments(docs []Document, chunker Chunker, metadata map[string]interface{}) []vecdb.Document {
+	var result []vecdb.Document
+	for _, doc := range docs {
+		for _, chunk := range chunker.Chunk(doc.Text) {
+			result = append(result, makeVecDocument(doc, chunk, metadata))
+		}
+	}
+	return result
+}
+
+// makeVecDocument converts the chunk into vector database document
+func makeVecDocument(doc Document, chunk Chunk, metadata map[string]interface{}) vecdb.Document {
+	payload := map[string]interface{}{}
+	for k, v := range metadata {
+		payload[k] = v
+	}
+	payload[PayloadSource] = doc.Path
+	payload[PayloadChunkIndex] = chunk.Index
+	payload[PayloadStart] = chunk.Start
+	payload[PayloadEnd] = chunk.End
+	if !doc.ModTime.IsZero() {
+		payload[PayloadModifiedAt] = doc.ModTime.UTC().Format(time.RFC3339)
+	}
+	if chunk.Heading != "" {
+		payload[PayloadHeading] = chunk.Heading
+	}
+	title := chunk.Heading
+	if title == "" {
+		title = doc.Title
+	}
+	return vecdb.Document{
+		ID:      chunkID(doc.Path, chunk.Index),
+		Text:    chunk.Text,
+		Title:   title,
+		Vectors: doc.Vectors,
+		Payload: payload,
+	}
+}
+
+// chunkID identifies the chunk by its source and position, so that re-ingesting edited document overwrites its chunks
+func chunkID(source string, index int) string {
+	h := md5.New()
+	fmt.Fprintf(h, "%s#%d", source, index)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// chunkHash identifies the version of the chunk content: the text, the payload except for the modification time
+// which changes even if the file content doesn't, and the precomputed vectors if any
+func chunkHash(doc vecdb.Document) string {
+	payload := map[string]interface{}{}
+	for k, v := range doc.Payload {
+		payload[k] = v
+	}
+	delete(payload, PayloadModifiedAt)
+	payloadJSON, _ := json.Marshal(payload) // map keys get sorted, so the result is stable
+
+	h := md5.New()
+	h.Write([]byte(doc.Text))
+	h.Write([]byte{0})
+	h.Write(payloadJSON)
+	if len(doc.Vectors) > 0 {
+		vectorsJSON, _ := json.Marshal(doc.Vectors)
+		h.Write([]byte{0})
+		h.Write(vectorsJSON)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
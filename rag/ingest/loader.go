@@ -0,0 +1,141 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Document is a single source file with its text extracted
+type Document struct {
+	Path    string
+	Title   string // the first heading, or the file name if there is none
+	Text    string
+	ModTime time.Time            // last modification of the source file
+	Vectors map[string][]float64 // optional, precomputed named vectors stored with every chunk, read from <path>.vectors.json
+}
+
+// VectorsFileSuffix names the file with precomputed vectors of a document, eg. photo.md.vectors.json holds {"image": [0.1, ...]}
+// embedding of the picture described in photo.md, as produced by the vision model
+const VectorsFileSuffix = ".vectors.json"
+
+// supportedExtensions lists the file types that can be ingested;
+// PDFs are expected to be converted to plain text first, eg. with `pdftotext file.pdf`
+var supportedExtensions = map[string]bool{
+	".md":       true,
+	".markdown": true,
+	".txt":      true,
+	".html":     true,
+	".htm":      true,
+}
+
+// LoadDir walks the root directory and loads all supported documents; root can also be a single file
+func LoadDir(root string) ([]Document, error) {
+	var docs []Document
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !IsSupported(path) {
+			return nil
+		}
+		doc, err := LoadFile(path)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, doc)
+		return nil
+	})
+	return docs, err
+}
+
+// IsSupported checks if the file type can be ingested
+func IsSupported(path string) bool {
+	return supportedExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// LoadFile reads the file and extracts its text
+func LoadFile(path string) (Document, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Document{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Document{}, err
+	}
+	vectors, err := loadVectors(path + VectorsFileSuffix)
+	if err != nil {
+		return Document{}, err
+	}
+	text := ExtractText(path, string(data))
+	return Document{Path: path, Title: ExtractTitle(path, text), Text: text, ModTime: info.ModTime(), Vectors: vectors}, nil
+}
+
+// loadVectors reads the precomputed named vectors from file; nil if the file doesn't exist
+func loadVectors(path string) (map[string][]float64, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var vectors map[string][]float64
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return vectors, nil
+}
+
+// ExtractTitle returns the first markdown heading of the extracted text, or the file name without extension if there is none
+func ExtractTitle(path, text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "#") {
+			if title := strings.TrimSpace(strings.TrimLeft(line, "#")); title != "" {
+				return title
+			}
+		}
+	}
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// ExtractText converts the file content into plain text according to file type
+func ExtractText(path, content string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		return extractHTMLText(content)
+	default:
+		return content
+	}
+}
+
+var (
+	htmlSkippedRegex = regexp.MustCompile(`(?is)<(script|style|head)[^>]*>.*?</(script|style|head)>`)
+	htmlHeadingRegex = regexp.MustCompile(`(?is)<h([1-6])[^>]*>(.*?)</h[1-6]>`)
+	htmlBlockRegex   = regexp.MustCompile(`(?i)</?(p|div|br|li|ul|ol|tr|table|section|article|pre|blockquote)[^>]*>`)
+	htmlTagRegex     = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankLinesRegex  = regexp.MustCompile(`\n[ \t]*(\n[ \t]*)+`)
+)
+
+// extractHTMLText strips the markup, keeping headings in markdown form so that heading-aware chunking works for HTML too
+func extractHTMLText(content string) string {
+	text := htmlSkippedRegex.ReplaceAllString(content, "")
+	text = htmlHeadingRegex.ReplaceAllStringFunc(text, func(heading string) string {
+		m := htmlHeadingRegex.FindStringSubmatch(heading)
+		level := int(m[1][0] - '0')
+		return "\n\n" + strings.Repeat("#", level) + " " + strings.TrimSpace(htmlTagRegex.ReplaceAllString(m[2], "")) + "\n\n"
+	})
+	text = htmlBlockRegex.ReplaceAllString(text, "\n")
+	text = htmlTagRegex.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	text = blankLinesRegex.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,96 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+// Manifest remembers which chunks of which source documents are stored in the collection,
+// so that re-ingestion only embeds the changed chunks and deletes the stale ones
+type Manifest struct {
+	Collection string                     `json:"collection"`
+	Sources    map[string][]ManifestChunk `json:"sources"` // source path -> chunks, ordered by chunk index
+}
+
+// ManifestChunk identifies stored chunk and the version of its content
+type ManifestChunk struct {
+	ID   string `json:"id"`   // point id
+	Hash string `json:"hash"` // hash of the chunk text and payload
+}
+
+// NewManifest creates empty manifest for the collection
+func NewManifest(collection string) *Manifest {
+	return &Manifest{Collection: collection, Sources: map[string][]ManifestChunk{}}
+}
+
+// LoadManifest reads manifest from file; missing file means nothing was ingested yet
+func LoadManifest(path, collection string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewManifest(collection), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m := NewManifest(collection)
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %q: %w", path, err)
+	}
+	if m.Collection != collection {
+		return nil, fmt.Errorf("manifest %q describes collection %q, not %q", path, m.Collection, collection)
+	}
+	return m, nil
+}
+
+// Save writes manifest to file
+func (m *Manifest) Save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to unique temporary file first so that interrupted or concurrent save doesn't leave broken manifest
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// SortedSources returns the source paths in alphabetical order
+func (m *Manifest) SortedSources() []string {
+	sources := make([]string, 0, len(m.Sources))
+	for source := range m.Sources {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// DefaultManifestPath returns the manifest file used for the collection when none is specified
+func DefaultManifestPath(collection string) (string, error) {
+	if err := vecdb.CheckCollectionName(collection); err != nil {
+		return "", err
+	}
+	return ".manifest-" + collection + ".json", nil
+}
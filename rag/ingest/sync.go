@@ -0,0 +1,202 @@
+package ingest
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+// SyncReport summarizes the result of Sync
+type SyncReport struct {
+	Added          int                // chunks stored for the first time
+	Updated        int                // chunks whose content changed and got re-embedded
+	Deleted        int                // chunks removed because their source got removed or shorter
+	Unchanged      int                // chunks skipped as already stored
+	RemovedSources []string           // sources that are no longer under root
+	Failed         []vecdb.BatchError // batches that failed to store, they will be retried by the next Sync
+	Duration       time.Duration
+}
+
+// pendingChunk is a chunk that needs to be stored
+type pendingChunk struct {
+	source  string
+	entry   ManifestChunk
+	oldHash string // empty for the new chunks
+}
+
+// Sync incrementally ingests the documents found under root: only new and changed chunks get embedded and stored,
+// the chunks of removed or shortened documents get deleted. The manifest is updated to reflect the collection content,
+// and needs to be saved by the caller
+func Sync(ctx context.Context, root string, manifest *Manifest, opts Options) (SyncReport, error) {
+	start := time.Now()
+	report := SyncReport{}
+
+	docs, err := LoadDir(root)
+	if err != nil {
+		return report, err
+	}
+	slog.Debug("loaded documents", "root", root, "count", len(docs))
+
+	// compare the chunks with the manifest
+	var toStore []vecdb.Document
+	var pending []pendingChunk
+	stale := map[string][]ManifestChunk{} // source -> chunks to delete
+	next := map[string][]ManifestChunk{}
+	loaded := map[string]bool{}
+	for _, doc := range docs {
+		loaded[doc.Path] = true
+		old := map[string]ManifestChunk{}
+		for _, c := range manifest.Sources[doc.Path] {
+			old[c.ID] = c
+		}
+
+		for _, chunk := range opts.Chunker.Chunk(doc.Text) {
+			vecDoc := makeVecDocument(doc, chunk, opts.Metadata)
+			entry := ManifestChunk{ID: vecDoc.ID, Hash: chunkHash(vecDoc)}
+			oldEntry, existed := old[entry.ID]
+			delete(old, entry.ID)
+
+			switch {
+			case existed && oldEntry.Hash == entry.Hash:
+				report.Unchanged++
+				next[doc.Path] = append(next[doc.Path], entry)
+			default:
+				toStore = append(toStore, vecDoc)
+				pending = append(pending, pendingChunk{source: doc.Path, entry: entry, oldHash: oldEntry.Hash})
+			}
+		}
+
+		// chunks past the end of the shortened document
+		for _, c := range manifest.Sources[doc.Path] {
+			if _, ok := old[c.ID]; ok {
+				stale[doc.Path] = append(stale[doc.Path], c)
+			}
+		}
+	}
+
+	// documents removed from under the root
+	for _, source := range manifest.SortedSources() {
+		if !loaded[source] && isUnder(source, root) {
+			report.RemovedSources = append(report.RemovedSources, source)
+			stale[source] = manifest.Sources[source]
+		}
+	}
+
+	// embed and store new and changed chunks
+	if len(toStore) > 0 {
+		feedOpts := opts.Feed
+		feedOpts.Append = true
+		feedReport, err := vecdb.FeedDocumentsContext(ctx, toStore, feedOpts)
+		if err != nil {
+			return report, err
+		}
+		report.Failed = feedReport.Failed
+	}
+	for i, p := range pending {
+		switch {
+		case isFailed(report.Failed, i) && p.oldHash != "":
+			next[p.source] = append(next[p.source], ManifestChunk{ID: p.entry.ID, Hash: p.oldHash}) // still the old version stored
+		case isFailed(report.Failed, i):
+			// not stored at all, will be added by the next sync
+		case p.oldHash != "":
+			report.Updated++
+			next[p.source] = append(next[p.source], p.entry)
+		default:
+			report.Added++
+			next[p.source] = append(next[p.source], p.entry)
+		}
+	}
+
+	// update manifest for the loaded sources
+	for source := range loaded {
+		manifest.Sources[source] = next[source]
+	}
+
+	// delete the stale chunks; on failure they are kept in manifest so that the next sync retries deleting them
+	var staleIDs []string
+	for _, chunks := range stale {
+		for _, c := range chunks {
+			staleIDs = append(staleIDs, c.ID)
+		}
+	}
+	if err := vecdb.DeletePointsFrom(ctx, opts.Feed.Collection, staleIDs); err != nil {
+		for source, chunks := range stale {
+			if loaded[source] { // removed sources still have all their chunks in manifest
+				manifest.Sources[source] = append(manifest.Sources[source], chunks...)
+			}
+		}
+		return report, err
+	}
+	for _, source := range report.RemovedSources {
+		delete(manifest.Sources, source)
+	}
+	report.Deleted = len(staleIDs)
+	report.Duration = time.Since(start)
+	return report, nil
+}
+
+// RecordAll makes the manifest describe the given chunks only, eg. after reindexing into a new collection
+func RecordAll(manifest *Manifest, chunks []vecdb.Document) {
+	manifest.Sources = map[string][]ManifestChunk{}
+	for _, chunk := range chunks {
+		source, _ := chunk.Payload[PayloadSource].(string)
+		manifest.Sources[source] = append(manifest.Sources[source], ManifestChunk{ID: chunk.ID, Hash: chunkHash(chunk)})
+	}
+}
+
+// RecordSources makes the manifest describe the given chunks as the only ones of their sources, eg. documents sent over HTTP
+// that got stored with FeedDocumentsContext. Returns the ids of the previously recorded chunks the sources don't have anymore,
+// eg. past the end of the shortened document, for deleting. The sources with any chunk in the failed batches are left as they were
+func RecordSources(manifest *Manifest, chunks []vecdb.Document, failed []vecdb.BatchError) []string {
+	var sources []string
+	bySource := map[string][]ManifestChunk{}
+	skipped := map[string]bool{}
+	for i, chunk := range chunks {
+		source, _ := chunk.Payload[PayloadSource].(string)
+		if _, ok := bySource[source]; !ok {
+			sources = append(sources, source)
+		}
+		bySource[source] = append(bySource[source], ManifestChunk{ID: chunk.ID, Hash: chunkHash(chunk)})
+		if isFailed(failed, i) {
+			skipped[source] = true
+		}
+	}
+
+	var stale []string
+	for _, source := range sources {
+		if skipped[source] {
+			continue
+		}
+		current := map[string]bool{}
+		for _, c := range bySource[source] {
+			current[c.ID] = true
+		}
+		for _, c := range manifest.Sources[source] {
+			if !current[c.ID] {
+				stale = append(stale, c.ID)
+			}
+		}
+		manifest.Sources[source] = bySource[source]
+	}
+	return stale
+}
+
+// isFailed checks if i-th stored document belongs to one of the failed batches
+func isFailed(failed []vecdb.BatchError, i int) bool {
+	for _, f := range failed {
+		if i >= f.Offset && i < f.Offset+f.Size {
+			return true
+		}
+	}
+	return false
+}
+
+// isUnder checks if path is the root itself or lies inside the root directory
+func isUnder(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,220 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+// offline selects the fake embedder and the embedded store in a temp dir, so that the tests need no services
+func offline(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	vecdb.SetEmbedder(vecdb.NewFakeEmbedder(0))
+	vecdb.SetStore(vecdb.NewEmbeddedStore(dir))
+	vecdb.SetKeywordIndexDir(dir)
+	vecdb.UseCollection("knowledge")
+}
+
+func TestSync(t *testing.T) {
+	offline(t)
+	root := t.TempDir()
+	manifest := NewManifest("knowledge")
+	opts := Options{Chunker: &FixedSizeChunker{Size: 10}}
+
+	steps := []struct {
+		name    string
+		write   map[string]string // file -> content
+		remove  []string
+		report  SyncReport
+		removed []string
+		points  int
+	}{
+		{
+			name:   "first sync adds all",
+			write:  map[string]string{"go.md": "goroutines channels", "rust.txt": "ownership"},
+			report: SyncReport{Added: 3},
+			points: 3,
+		},
+		{
+			name:   "nothing changed",
+			report: SyncReport{Unchanged: 3},
+			points: 3,
+		},
+		{
+			name:   "edited chunk updated",
+			write:  map[string]string{"go.md": "goroutines select"},
+			report: SyncReport{Updated: 1, Unchanged: 2},
+			points: 3,
+		},
+		{
+			name:   "shortened document",
+			write:  map[string]string{"go.md": "goroutine"},
+			report: SyncReport{Updated: 1, Deleted: 1, Unchanged: 1},
+			points: 2,
+		},
+		{
+			name:    "removed document",
+			remove:  []string{"rust.txt"},
+			report:  SyncReport{Deleted: 1, Unchanged: 1},
+			removed: []string{"rust.txt"},
+			points:  1,
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			for name, content := range step.write {
+				if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, name := range step.remove {
+				if err := os.Remove(filepath.Join(root, name)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			report, err := Sync(context.Background(), root, manifest, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			counts := SyncReport{Added: report.Added, Updated: report.Updated, Deleted: report.Deleted, Unchanged: report.Unchanged}
+			if !reflect.DeepEqual(counts, step.report) {
+				t.Fatalf("report %+v, expected %+v", counts, step.report)
+			}
+			var removed []string
+			for _, source := range report.RemovedSources {
+				removed = append(removed, filepath.Base(source))
+			}
+			if !reflect.DeepEqual(removed, step.removed) {
+				t.Fatalf("removed sources %v, expected %v", removed, step.removed)
+			}
+
+			info, err := vecdb.DescribeCollection(context.Background(), "knowledge")
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorded := 0
+			for _, chunks := range manifest.Sources {
+				recorded += len(chunks)
+			}
+			if info.PointsCount != step.points || recorded != step.points {
+				t.Fatalf("%d points stored, %d recorded in manifest, expected %d", info.PointsCount, recorded, step.points)
+			}
+		})
+	}
+}
+
+func TestRecordSources(t *testing.T) {
+	chunk := func(source string, index int, text string) vecdb.Document {
+		return makeVecDocument(Document{Path: source}, Chunk{Index: index, Text: text}, nil)
+	}
+	manifest := NewManifest("knowledge")
+	RecordAll(manifest, []vecdb.Document{chunk("a", 0, "a0"), chunk("a", 1, "a1"), chunk("a", 2, "a2"), chunk("b", 0, "b0")})
+
+	tests := []struct {
+		name   string
+		chunks []vecdb.Document
+		failed []vecdb.BatchError
+		stale  []string
+		a      int // chunks of "a" recorded after
+	}{
+		{
+			name:   "same chunks",
+			chunks: []vecdb.Document{chunk("a", 0, "a0"), chunk("a", 1, "a1"), chunk("a", 2, "a2")},
+			a:      3,
+		},
+		{
+			name:   "failed source kept",
+			chunks: []vecdb.Document{chunk("a", 0, "a0")},
+			failed: []vecdb.BatchError{{Offset: 0, Size: 1}},
+			a:      3,
+		},
+		{
+			name:   "shortened source",
+			chunks: []vecdb.Document{chunk("a", 0, "new a0")},
+			stale:  []string{chunkID("a", 1), chunkID("a", 2)},
+			a:      1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stale := RecordSources(manifest, tt.chunks, tt.failed)
+			if !reflect.DeepEqual(stale, tt.stale) {
+				t.Fatalf("stale %v, expected %v", stale, tt.stale)
+			}
+			if len(manifest.Sources["a"]) != tt.a || len(manifest.Sources["b"]) != 1 {
+				t.Fatalf("recorded %d chunks of a and %d of b, expected %d and 1", len(manifest.Sources["a"]), len(manifest.Sources["b"]), tt.a)
+			}
+		})
+	}
+}
+
+func TestManifestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+
+	loaded, err := LoadManifest(path, "knowledge")
+	if err != nil || len(loaded.Sources) != 0 {
+		t.Fatalf("missing manifest loaded as %+v, %v; expected empty", loaded, err)
+	}
+
+	// concurrent saves don't share the temporary file, so the manifest is always whole
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m := NewManifest("knowledge")
+			m.Sources[fmt.Sprintf("doc%d.md", i)] = []ManifestChunk{{ID: chunkID("doc.md", i), Hash: "h"}}
+			if err := m.Save(path); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	loaded, err = LoadManifest(path, "knowledge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Sources) != 1 {
+		t.Fatalf("loaded %d sources, expected the ones of a single save", len(loaded.Sources))
+	}
+	if leftovers, _ := filepath.Glob(path + ".*.tmp"); len(leftovers) != 0 {
+		t.Fatalf("temporary files left: %v", leftovers)
+	}
+
+	if _, err := LoadManifest(path, "other"); err == nil {
+		t.Fatal("expected error loading manifest of another collection")
+	}
+}
+
+func TestDefaultManifestPath(t *testing.T) {
+	tests := []struct {
+		collection string
+		path       string
+	}{
+		{"knowledge", ".manifest-knowledge.json"},
+		{"docs_v2.1", ".manifest-docs_v2.1.json"},
+		{"..", ""},
+		{"../etc", ""},
+		{"a/b", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		path, err := DefaultManifestPath(tt.collection)
+		if path != tt.path {
+			t.Errorf("DefaultManifestPath(%q) = %q, expected %q", tt.collection, path, tt.path)
+		}
+		if (err == nil) != (tt.path != "") || (err != nil && !errors.Is(err, vecdb.ErrInvalidCollectionName)) {
+			t.Errorf("DefaultManifestPath(%q): unexpected error %v", tt.collection, err)
+		}
+	}
+}
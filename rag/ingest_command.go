@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/mateuszmidor/AiStudy/rag/ingest"
+	"github.com/mateuszmidor/AiStudy/rag/server"
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+// ingestCommand stores the documents found under the path provided in args, in form of chunk embeddings
+func ingestCommand(args []string) {
+	flags := flag.NewFlagSet("ingest", flag.ExitOnError)
+	strategy := flags.String("chunker", ingest.StrategySentence, "chunking strategy: fixed, sentence or heading")
+	size := flags.Int("size", 800, "max chunk size in characters")
+	overlap := flags.Int("overlap", 1, "chunk overlap: characters for fixed and heading, sentences for sentence chunker")
+	batchSize := flags.Int("batch", vecdb.DefaultFeedOptions().BatchSize, "how many chunks are embedded and stored in one request")
+	collection := flags.String("collection", vecdb.CollectionName(), "collection or alias to store the chunks in")
+	distance := flags.String("distance", vecdb.DefaultDistance, "distance func of a new collection: Cosine, Dot, Euclid or Manhattan")
+	storage := addStorageFlags(flags)
+	vectors := flags.String("vectors", "", "comma separated named vectors embedded for every chunk of a new collection: text, title; precomputed ones come from <file>"+ingest.VectorsFileSuffix+" (default single vector of the text)")
+	reindex := flags.Bool("reindex", false, "blue/green: store into a new collection version and switch the -collection alias to it")
+	keepOld := flags.Bool("keep-old", false, "with -reindex, don't delete the previous collection version")
+	manifestPath := flags.String("manifest", "", "manifest file tracking the ingested chunks (default .manifest-<collection>.json)")
+	output := addOutputFlag(flags)
+	metadata := metadataFlag{}
+	flags.Var(metadata, "meta", "key=value metadata stored with every chunk, can be repeated, eg. -meta lang=en -meta tag=manual")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: rag ingest [flags] <path>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	checkOutput(flags, *output)
+
+	chunker, err := ingest.NewChunker(*strategy, *size, *overlap)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	opts := ingest.Options{Chunker: chunker, Metadata: metadata, Feed: vecdb.DefaultFeedOptions()}
+	opts.Feed.BatchSize = *batchSize
+	opts.Feed.Distance = *distance
+	opts.Feed.Storage = storage.storage()
+	opts.Feed.Vectors = splitList(*vectors)
+	opts.Feed.Append = true
+	opts.Feed.Progress = func(done, total int) {
+		slog.Info("ingesting", "done", done, "total", total)
+	}
+
+	*manifestPath = manifestPathOr(*manifestPath, *collection)
+	manifest, err := ingest.LoadManifest(*manifestPath, *collection)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	if *reindex {
+		reindexCommand(flags.Arg(0), *collection, manifest, *manifestPath, opts, *keepOld)
+		return
+	}
+
+	// incremental ingestion: only changed chunks get embedded, stale ones get deleted
+	vecdb.UseCollection(*collection)
+	report, syncErr := ingest.Sync(context.Background(), flags.Arg(0), manifest, opts)
+	if err := manifest.Save(*manifestPath); err != nil {
+		slog.Error("failed to save manifest", "error", err)
+	}
+	if syncErr != nil {
+		slog.Error(syncErr.Error())
+		os.Exit(1)
+	}
+	for _, failed := range report.Failed {
+		slog.Warn(failed.Error())
+	}
+	for _, source := range report.RemovedSources {
+		slog.Info("removed", "source", source)
+	}
+	if *output == outputJSON {
+		rsp := server.IngestResponse{
+			Collection: *collection,
+			Added:      report.Added,
+			Updated:    report.Updated,
+			Deleted:    report.Deleted,
+			Unchanged:  report.Unchanged,
+			DurationMs: report.Duration.Milliseconds(),
+		}
+		for _, failed := range report.Failed {
+			rsp.FailedBatches = append(rsp.FailedBatches, failed.Error())
+		}
+		printJSON(rsp)
+		return
+	}
+	fmt.Printf("added: %d, updated: %d, deleted: %d, unchanged: %d, failed batches: %d, took: %s\n",
+		report.Added, report.Updated, report.Deleted, report.Unchanged, len(report.Failed), report.Duration)
+}
+
+// reindexCommand stores all the chunks in a new version of the collection behind the alias and records them in the manifest
+func reindexCommand(root, alias string, manifest *ingest.Manifest, manifestPath string, opts ingest.Options, keepOld bool) {
+	chunks, err := ingest.LoadChunks(root, opts)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	report, err := vecdb.Reindex(context.Background(), alias, chunks, opts.Feed, keepOld)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	ingest.RecordAll(manifest, chunks)
+	if err := manifest.Save(manifestPath); err != nil {
+		slog.Error("failed to save manifest", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("reindexed", "alias", alias, "collection", report.Collection, "stored", report.Stored, "deleted", report.Deleted, "duration", report.Duration)
+}
+
+// metadataFlag collects repeated -meta key=value flags; repeated key makes a list of values
+type metadataFlag map[string]interface{}
+
+func (m metadataFlag) String() string {
+	return fmt.Sprint(map[string]interface{}(m))
+}
+
+func (m metadataFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	switch existing := m[key].(type) {
+	case nil:
+		m[key] = val
+	case string:
+		m[key] = []string{existing, val}
+	case []string:
+		m[key] = append(existing, val)
+	}
+	return nil
+}
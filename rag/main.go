@@ -2,12 +2,18 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"os/signal"
 	"strings"
 
-	"github.com/mateuszmidor/AiStudy/rag/llm"
+	"github.com/mateuszmidor/AiStudy/llm"
+	"github.com/mateuszmidor/AiStudy/rag/answer"
+	"github.com/mateuszmidor/AiStudy/rag/rerank"
 	"github.com/mateuszmidor/AiStudy/rag/vecdb"
 )
 
@@ -31,12 +37,119 @@ var questions = []string{
 	"What animals do you know?",
 }
 
+// generator answers the questions; configured with RAG_LLM_PROVIDER, RAG_LLM_MODEL and RAG_LLM_URL environment variables, see setupGenerator
+var generator llm.ChatModel
+
+// reranker rescores the retrieved information; nil means retrieval scores are used as they are
+var reranker rerank.Reranker
+
+// conversationWindow is how many recent messages interactiveMode keeps verbatim; older ones get summarized
+const conversationWindow = 6
+
+// retrieval controls how many information pieces are fetched, reranked and put into the prompt
+var retrieval = rerank.DefaultOptions()
+
+// contextOptions controls how the information pieces are fitted into the prompt
+var contextOptions = answer.DefaultContextOptions()
+
+// usage describes the commands
+const usage = `usage: rag <command> [flags] [args]
+
+commands:
+  ingest <path>               store the documents found under the path
+  ask "<question>"            answer the question with the stored documents
+  chat                        answer the questions typed in, following the conversation
+  collections list            list the collections
+  collections drop <name>     delete the collection
+  collections export <name> <file>
+                              snapshot the collection with its vectors to file
+  collections import <file>   restore the collection from snapshot file
+  eval <dataset>              evaluate the retrieval and the answers on golden questions
+  serve                       expose ingest and ask over HTTP
+  bench                       compare the throughput of the vector stores, eg. qdrant REST and gRPC
+  demo                        store the sample knowledge and ask the sample questions
+
+run "rag <command> -h" for the command flags`
+
 func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// select embedder according to RAG_EMBEDDER* environment variables
+	embedder, err := vecdb.NewEmbedder(vecdb.EmbedderConfigFromEnv())
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	vecdb.SetEmbedder(embedder)
+
+	// select vector store according to RAG_STORE* environment variables
+	store, err := vecdb.NewStore(vecdb.StoreConfigFromEnv())
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	vecdb.SetStore(store)
+
+	// select reranker according to RAG_RERANKER* environment variables
+	reranker, err = rerank.NewReranker(os.Getenv("RAG_RERANKER"), llm.ConfigFromEnv("RAG_RERANKER"))
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	command, args := os.Args[1], os.Args[2:]
+	switch command {
+	case "ingest":
+		ingestCommand(args)
+	case "ask":
+		askCommand(args)
+	case "chat":
+		chatCommand(args)
+	case "collections":
+		collectionsCommand(args)
+	case "eval":
+		evalCommand(args)
+	case "serve":
+		serveCommand(args)
+	case "bench":
+		benchCommand(args)
+	case "demo":
+		demoCommand(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Println(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s\n", command, usage)
+		os.Exit(2)
+	}
+
+	if cache := llm.CacheOf(generator); cache != nil {
+		stats := cache.Stats()
+		slog.Info("llm cache", "hits", stats.Hits, "misses", stats.Misses, "stores", stats.Stores, "evictions", stats.Evictions)
+	}
+	if closer, ok := vecdb.Store().(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// demoCommand fills the vector db with the sample knowledge and asks the sample questions
+func demoCommand(args []string) {
+	flags := flag.NewFlagSet("demo", flag.ExitOnError)
+	model := addModelFlag(flags)
+	flags.Parse(args)
+	setupGenerator(*model)
+
 	// fill vector db with knowledge
 	slog.Info("feeding the retriever, can take a dozen seconds...")
-	vecdb.FeedDB(knowledge)
+	// appending makes the demo rerunnable: the points are keyed by the text hash, so the knowledge already stored is just overwritten
+	opts := vecdb.DefaultFeedOptions()
+	opts.Append = true
+	report := vecdb.FeedDBWithOptions(knowledge, opts)
+	slog.Info("fed the retriever", "stored", report.Stored, "failed_batches", len(report.Failed), "duration", report.Duration)
 	demoMode()
-	// interactiveMode()
 }
 
 // demoMode asks the RAG a series of predefined questions
@@ -44,66 +157,120 @@ func demoMode() {
 	for _, question := range questions {
 		// retrieve information relevant to the question from vector db
 		slog.Info("retrieving information regarding: " + question)
-		rsp := vecdb.AskDB(question, 3)
+		rsp := retrieve(vecdb.Query{Text: question}, retrieval)
 		slog.Info("retrieved", "results", rsp)
 
-		// create prompt that includes the retrieved information for ollama
-		prompt := makePrompt(question, rsp)
-		slog.Info("prepared prompt: \n" + prompt) // multiline
+		// create prompt that includes the numbered information for ollama, as much as fits into the budget
+		prompt, err := answer.BuildContext(question, rsp, contextOptions)
+		if err != nil {
+			slog.Error("prompt not created", "error", err)
+			continue
+		}
+		slog.Info("prepared prompt: \n"+prompt.Prompt, "tokens", prompt.Tokens, "dropped", prompt.Dropped) // multiline
 
-		// generate response
+		// generate response and trace it back to the cited information
 		slog.Info("sending prompt to ollama...")
-		response := llm.OllamaGenerateCompletion(prompt)
-		slog.Info("response: " + response)
+		completion, err := generator.Generate(context.Background(), prompt.Prompt)
+		if err != nil {
+			slog.Error("generation failed", "error", err)
+			continue
+		}
+		response := answer.Parse(completion.Text, prompt.Pieces)
+		slog.Info("response: "+response.Text, "sources", len(response.Sources), "uncited", response.Uncited)
+		printAnswer(response)
 		fmt.Println()
 	}
 }
 
-// interactiveMode allows user to ask the RAG custom questions
-func interactiveMode() {
+// interactiveMode allows user to ask the RAG custom questions; every question is searched for with the query settings
+// and the found information is fitted into the prompt with contextOpts
+func interactiveMode(query vecdb.Query, opts rerank.Options, contextOpts answer.ContextOptions) {
 	reader := bufio.NewReader(os.Stdin)
+	conversation := llm.NewConversation(conversationWindow, generator)
 
 	fmt.Println("ask me a question :)")
 	for {
 		fmt.Print("> ")
-		question, _ := reader.ReadString('\n')
+		question, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
 		fmt.Print("(thinking...)")
 
+		// rewrite follow-up question like "and which one is robust?" so that it can be searched for on its own
+		query.Text, err = answer.StandaloneQuestion(context.Background(), generator, conversation, question)
+		if err != nil {
+			slog.Warn("using the question as it is", "error", err)
+			query.Text = strings.TrimSpace(question)
+		}
+
 		// retrieve information relevant to the question from vector db
-		rsp := vecdb.AskDB(question, 3)
+		rsp := retrieve(query, opts)
 
-		// create prompt that includes the retrieved information for ollama
-		prompt := makePrompt(question, rsp)
+		// create prompt that includes the numbered information for ollama, following the conversation so far
+		prompt, err := answer.BuildContext(query.Text, rsp, contextOpts)
+		if err != nil {
+			fmt.Printf("\r(prompt not created: %v)\n\n", err)
+			continue
+		}
+		messages := append(conversation.Messages(), llm.Message{Role: llm.RoleUser, Content: prompt.Prompt})
 
-		// generate response
-		response := llm.OllamaGenerateCompletion(prompt)
-		fmt.Println()
-		fmt.Println(response)
+		// stream the response as it is generated; Ctrl+C stops the generation
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		fmt.Print("\r")
+		final, err := generator.ChatStream(ctx, messages, func(token string) { fmt.Print(token) })
+		stop()
 		fmt.Println()
+		if err != nil {
+			fmt.Printf("(generation failed: %v)\n\n", err)
+			continue
+		}
+
+		// trace the response back to the cited information
+		response := answer.Parse(final.Text, prompt.Pieces)
+		printSources(response)
+		fmt.Printf("(tokens: %d prompt, %d response)\n\n", final.Usage.PromptTokens, final.Usage.CompletionTokens)
+
+		// remember the question and the answer, not the retrieved information, to keep the history short
+		conversation.Add(llm.RoleUser, query.Text)
+		conversation.Add(llm.RoleAssistant, response.Text)
+		if err := conversation.Compact(context.Background()); err != nil {
+			slog.Warn("conversation not compacted", "error", err)
+		}
+	}
+}
+
+// retrieve fetches the information relevant to the query from vector db and reranks it;
+// pieces scored at or below opts.Threshold are left out
+func retrieve(query vecdb.Query, opts rerank.Options) []vecdb.SearchResult {
+	results, err := rerank.Retrieve(context.Background(), query, reranker, opts)
+	if err != nil {
+		slog.Error("retrieval failed", "error", err)
+		return nil
 	}
+	return results
 }
 
-// makePrompt creates a prompt for the ollama based on the question and the information pieces
-func makePrompt(question string, informationPieces []vecdb.SearchResult) string {
-	instruction := "Instruction: Based only on the provided information, answer the question in one short sentence."
-	information := collectInformationPieces(informationPieces)
-	question = "Question: " + question
-	return instruction + "\n" + information + "\n" + question
+// printAnswer prints the answer followed by the cited sources
+func printAnswer(a answer.Answer) {
+	fmt.Println(a.Text)
+	printSources(a)
 }
 
-// collectInformationPieces collects information pieces from the search results,
-// checks if they are useful and returns them as a single string
-func collectInformationPieces(informationItems []vecdb.SearchResult) string {
-	var info []string
-	for _, r := range informationItems {
-		if isUsefulInformation(r) {
-			info = append(info, "Information: "+r.Text)
-		}
+// printSources prints the cited sources of the answer
+func printSources(a answer.Answer) {
+	if a.Uncited {
+		fmt.Println("(no sources cited)")
+	}
+	for _, source := range a.Sources {
+		fmt.Printf("[%d] %s (score %.2f)\n", source.Ref, describeSource(source), source.Score)
 	}
-	return strings.Join(info, "\n")
 }
 
-// isUsefulInformation checks if the information piece is usefulbased on the score and returns true if it is
-func isUsefulInformation(r vecdb.SearchResult) bool {
-	return r.Score > 0.25 // arbitrary threshold but should work fine for now
+// describeSource returns the document and chunk the source comes from, or its text if the document is unknown
+func describeSource(s answer.Source) string {
+	if document, ok := s.Payload["source"]; ok {
+		return fmt.Sprintf("%v#%v", document, s.Payload["chunk_index"])
+	}
+	return s.Text
 }
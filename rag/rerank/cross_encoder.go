@@ -0,0 +1,87 @@
+package rerank
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+const defaultSidecarURL = "http://localhost:5000"
+
+// CrossEncoder scores the (question, passage) pairs with the cross-encoder model served by the local sidecar, see vecdb/embedding-localhost/main.py
+type CrossEncoder struct {
+	URL string
+}
+
+// NewCrossEncoder creates reranker for the sidecar running at url; empty url means default
+func NewCrossEncoder(url string) *CrossEncoder {
+	if url == "" {
+		url = defaultSidecarURL
+	}
+	return &CrossEncoder{URL: url}
+}
+
+// Rerank implements Reranker
+func (c *CrossEncoder) Rerank(ctx context.Context, question string, candidates []vecdb.SearchResult) ([]vecdb.SearchResult, error) {
+	query := struct {
+		Query    string   `json:"query"`
+		Passages []string `json:"passages"`
+	}{Query: question, Passages: make([]string, 0, len(candidates))}
+	for _, candidate := range candidates {
+		query.Passages = append(query.Passages, candidate.Text)
+	}
+
+	rspString, err := postJSON(ctx, c.URL+"/rerank", query)
+	if err != nil {
+		return nil, err
+	}
+
+	var rsp struct {
+		Scores []float64 `json:"scores"`
+	}
+	if err := json.Unmarshal(rspString, &rsp); err != nil {
+		return nil, err
+	}
+	if len(rsp.Scores) != len(candidates) {
+		return nil, fmt.Errorf("sidecar returned %d scores, expected %d", len(rsp.Scores), len(candidates))
+	}
+	return rescored(candidates, rsp.Scores), nil
+}
+
+// Name implements Reranker
+func (c *CrossEncoder) Name() string {
+	return "cross-encoder"
+}
+
+// postJSON sends data as JSON and returns the response body; status other than 200 OK is an error
+func postJSON(ctx context.Context, url string, data interface{}) ([]byte, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	rspBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to perform POST request to %q: %s, Response: %s", url, resp.Status, rspBody)
+	}
+	return rspBody, nil
+}
@@ -0,0 +1,95 @@
+package rerank
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mateuszmidor/AiStudy/llm"
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+// JudgeMaxTokens limits the judge reply; enough for "Rating: 7/10" with a few words around, which the models add despite the instruction
+const JudgeMaxTokens = 32
+
+const (
+	judgeMaxScore      = 10
+	judgePromptPattern = `Instruction: Rate how useful the passage is for answering the question, on a scale from 0 (unrelated) to %d (answers it directly). Reply with the number only.
+Question: %s
+Passage: %s
+Rating:`
+)
+
+var (
+	// ratingLabel finds the labelled rating in the judge reply, with the scale if given, eg. "Rating: 7/10"
+	ratingLabel = regexp.MustCompile(`(?i)rating\s*[:=]?\s*(\d+(?:\.\d+)?)(?:\s*(?:/|out of)\s*(\d+(?:\.\d+)?))?`)
+	// ratingNumber finds the numbers in the judge reply, with the scale if given, eg. "7 out of 10"
+	ratingNumber = regexp.MustCompile(`(\d+(?:\.\d+)?)(?:\s*(?:/|out of)\s*(\d+(?:\.\d+)?))?`)
+)
+
+// ParseRating finds the rating in the judge reply and scales it to 0-1; the last "Rating: N" wins, otherwise the last number,
+// as the models tend to reason first and conclude with the rating. The rating is on the scale given in the reply, eg. "7/10",
+// otherwise on 0-maxScore scale; a fraction up to 1, eg. "0.7", is taken for already scaled to 0-1
+func ParseRating(reply string, maxScore float64) (float64, error) {
+	matches := ratingLabel.FindAllStringSubmatch(reply, -1)
+	if len(matches) == 0 {
+		matches = ratingNumber.FindAllStringSubmatch(reply, -1)
+	}
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("no rating in the judge reply %q", reply)
+	}
+	match := matches[len(matches)-1]
+	rating, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rating in the judge reply %q: %w", reply, err)
+	}
+
+	scale := maxScore
+	if match[2] != "" {
+		if scale, err = strconv.ParseFloat(match[2], 64); err != nil || scale == 0 {
+			return 0, fmt.Errorf("invalid rating scale in the judge reply %q", reply)
+		}
+	} else if strings.Contains(match[1], ".") && rating <= 1 {
+		scale = 1
+	}
+	return min(rating/scale, 1), nil
+}
+
+// LLMJudge asks the LLM to rate every candidate, one prompt per candidate
+type LLMJudge struct {
+	Judge llm.Generator
+}
+
+// NewLLMJudge creates reranker rating with the judge model; it should use temperature 0 so that the ratings are stable
+func NewLLMJudge(judge llm.Generator) *LLMJudge {
+	return &LLMJudge{Judge: judge}
+}
+
+// Rerank implements Reranker
+func (j *LLMJudge) Rerank(ctx context.Context, question string, candidates []vecdb.SearchResult) ([]vecdb.SearchResult, error) {
+	scores := make([]float64, len(candidates))
+	for i, c := range candidates {
+		score, err := j.rate(ctx, question, c.Text)
+		if err != nil {
+			return nil, err
+		}
+		scores[i] = score
+	}
+	return rescored(candidates, scores), nil
+}
+
+// Name implements Reranker
+func (j *LLMJudge) Name() string {
+	return "llm/" + j.Judge.Model()
+}
+
+// rate returns the LLM rating of the passage, scaled to 0-1
+func (j *LLMJudge) rate(ctx context.Context, question, passage string) (float64, error) {
+	rsp, err := j.Judge.Generate(ctx, fmt.Sprintf(judgePromptPattern, judgeMaxScore, question, passage))
+	if err != nil {
+		return 0, err
+	}
+	return ParseRating(rsp.Text, judgeMaxScore)
+}
@@ -0,0 +1,109 @@
+package rerank
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+
+	"github.com/mateuszmidor/AiStudy/llm"
+	"github.com/mateuszmidor/AiStudy/rag/transform"
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+// Reranker rescores the candidates by their relevance to the question.
+// The returned results carry the new score, in range 0-1, and are sorted best first
+type Reranker interface {
+	Rerank(ctx context.Context, question string, candidates []vecdb.SearchResult) ([]vecdb.SearchResult, error)
+	Name() string
+}
+
+// Options controls the retrieval, can be set per query
+type Options struct {
+	Candidates int     // how many candidates to fetch from vector db before reranking; less than TopK means 4*TopK
+	TopK       int     // how many results to keep after reranking
+	Threshold  float64 // results scored at or below the threshold are dropped; not applied to the BM25 scores of keyword search without reranker
+
+	Transformer transform.Transformer // optional, rewrites the question before the search, eg. into paraphrases
+}
+
+// DefaultOptions returns the options used by the demo
+func DefaultOptions() Options {
+	return Options{Candidates: 12, TopK: 3, Threshold: 0.25}
+}
+
+// NewReranker creates Reranker by name: "llm", "cross-encoder"; empty name or "none" means no reranking (nil Reranker).
+// The config selects the judge model for "llm", only its URL is used by "cross-encoder"
+func NewReranker(name string, cfg llm.Config) (Reranker, error) {
+	switch name {
+	case "", "none":
+		return nil, nil
+	case "llm":
+		judge, err := llm.New(cfg, llm.WithTemperature(0), llm.WithMaxTokens(JudgeMaxTokens))
+		if err != nil {
+			return nil, err
+		}
+		return NewLLMJudge(judge), nil
+	case "cross-encoder":
+		return NewCrossEncoder(cfg.URL), nil
+	default:
+		return nil, fmt.Errorf("unknown reranker %q", name)
+	}
+}
+
+// Retrieve fetches the candidates for the query from vector db, reranks them and keeps the TopK results scored above Threshold.
+// The candidates are searched for with the texts the Transformer rewrites the question into, but reranked against the question.
+// Nil reranker keeps the retrieval order and scores
+func Retrieve(ctx context.Context, q vecdb.Query, reranker Reranker, opts Options) ([]vecdb.SearchResult, error) {
+	q.Limit = opts.TopK
+	if reranker != nil {
+		q.Limit = opts.Candidates
+		if q.Limit < opts.TopK {
+			q.Limit = 4 * opts.TopK
+		}
+	}
+
+	candidates, err := transform.Search(ctx, q, opts.Transformer)
+	if err != nil {
+		return nil, err
+	}
+
+	results := candidates
+	if reranker != nil {
+		results, err = reranker.Rerank(ctx, q.Text, candidates)
+		if err != nil {
+			return nil, fmt.Errorf("rerank with %s: %w", reranker.Name(), err)
+		}
+		slog.Debug("reranked", "reranker", reranker.Name(), "candidates", len(candidates))
+	}
+	if reranker == nil && q.Mode == vecdb.SearchKeyword {
+		return selectTop(results, opts.TopK, math.Inf(-1)), nil // BM25 scores are unbounded, the threshold means nothing for them
+	}
+	return selectTop(results, opts.TopK, opts.Threshold), nil
+}
+
+// selectTop keeps up to k results scored above threshold
+func selectTop(results []vecdb.SearchResult, k int, threshold float64) []vecdb.SearchResult {
+	var selected []vecdb.SearchResult
+	for _, r := range results {
+		if len(selected) == k {
+			break
+		}
+		if r.Score > threshold {
+			selected = append(selected, r)
+		}
+	}
+	return selected
+}
+
+// rescored returns copy of candidates with new scores, sorted best first; ties keep the retrieval order
+func rescored(candidates []vecdb.SearchResult, scores []float64) []vecdb.SearchResult {
+	results := make([]vecdb.SearchResult, len(candidates))
+	for i, c := range candidates {
+		c.Score = scores[i]
+		results[i] = c
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	return results
+}
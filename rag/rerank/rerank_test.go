@@ -0,0 +1,199 @@
+package rerank
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mateuszmidor/AiStudy/llm"
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+func TestParseRating(t *testing.T) {
+	tests := []struct {
+		reply  string
+		rating float64
+		valid  bool
+	}{
+		{"7", 0.7, true},
+		{" 10\n", 1, true},
+		{"0", 0, true},
+		{"Rating: 7/10", 0.7, true},
+		{"rating=9.5", 0.95, true},
+		{"I'd say 7 out of 10", 0.7, true},
+		{"Rating: 3/5", 0.6, true},
+		{"The passage mentions 2 of the 3 facts. Rating: 8", 0.8, true},
+		{"Step 1: checked. Step 2: partial. 6", 0.6, true},
+		{"0.7", 0.7, true},           // already scaled to 0-1
+		{"Rating: 0.25", 0.25, true}, // already scaled to 0-1
+		{"1", 0.1, true},             // integer is on the 0-10 scale
+		{"1.0", 1, true},
+		{"15", 1, true}, // capped
+		{"Rating: 7/0", 0, false},
+		{"none", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		rating, err := ParseRating(tt.reply, 10)
+		if (err == nil) != tt.valid {
+			t.Errorf("ParseRating(%q): error %v, expected valid %v", tt.reply, err, tt.valid)
+			continue
+		}
+		if math.Abs(rating-tt.rating) > 1e-9 {
+			t.Errorf("ParseRating(%q) = %v, expected %v", tt.reply, rating, tt.rating)
+		}
+	}
+}
+
+func TestSelectTop(t *testing.T) {
+	results := scored(0.9, 0.5, 0.25, 0.1)
+	tests := []struct {
+		name      string
+		k         int
+		threshold float64
+		scores    []float64
+	}{
+		{"top k", 2, 0, []float64{0.9, 0.5}},
+		{"threshold exclusive", 5, 0.25, []float64{0.9, 0.5}},
+		{"none above", 5, 0.95, nil},
+		{"no threshold", 5, math.Inf(-1), []float64{0.9, 0.5, 0.25, 0.1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var scores []float64
+			for _, r := range selectTop(results, tt.k, tt.threshold) {
+				scores = append(scores, r.Score)
+			}
+			if !reflect.DeepEqual(scores, tt.scores) {
+				t.Fatalf("selected %v, expected %v", scores, tt.scores)
+			}
+		})
+	}
+}
+
+func TestLLMJudge(t *testing.T) {
+	replies := map[string]string{"go": "Rating: 3", "rust": "9", "python": "0.5"}
+	judge := NewLLMJudge(fakeGenerator(func(prompt string) string {
+		for passage, reply := range replies {
+			if strings.Contains(prompt, "Passage: "+passage+"\n") {
+				return reply
+			}
+		}
+		return "no idea"
+	}))
+
+	reranked, err := judge.Rerank(context.Background(), "question", []vecdb.SearchResult{{Text: "go"}, {Text: "rust"}, {Text: "python"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for _, r := range reranked {
+		texts = append(texts, r.Text)
+	}
+	if !reflect.DeepEqual(texts, []string{"rust", "python", "go"}) {
+		t.Fatalf("reranked %v", texts)
+	}
+
+	if _, err := judge.Rerank(context.Background(), "question", []vecdb.SearchResult{{Text: "java"}}); err == nil {
+		t.Fatal("expected error for reply without rating")
+	}
+}
+
+func TestCrossEncoder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query    string   `json:"query"`
+			Passages []string `json:"passages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		scores := []float64{}
+		for _, p := range req.Passages {
+			scores = append(scores, float64(len(p))/10)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"scores": scores})
+	}))
+	defer server.Close()
+
+	reranked, err := NewCrossEncoder(server.URL).Rerank(context.Background(), "q", []vecdb.SearchResult{{Text: "a"}, {Text: "abc"}, {Text: "ab"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reranked[0].Text != "abc" || reranked[2].Text != "a" || math.Abs(reranked[0].Score-0.3) > 1e-9 {
+		t.Fatalf("reranked %+v", reranked)
+	}
+}
+
+func TestRetrieveThreshold(t *testing.T) {
+	dir := t.TempDir()
+	vecdb.SetEmbedder(vecdb.NewFakeEmbedder(0))
+	vecdb.SetStore(vecdb.NewEmbeddedStore(dir))
+	vecdb.SetKeywordIndexDir(dir)
+	knowledge := []string{"Go has goroutines.", "Rust guarantees ownership.", "Python yields generators."}
+	if _, err := vecdb.FeedDBContext(context.Background(), knowledge, vecdb.FeedOptions{Collection: "knowledge"}); err != nil {
+		t.Fatal(err)
+	}
+	judge := NewLLMJudge(fakeGenerator(func(prompt string) string {
+		if strings.Contains(prompt, "Passage: Go has goroutines.") {
+			return "8"
+		}
+		return "1"
+	}))
+
+	tests := []struct {
+		name      string
+		mode      string
+		reranker  Reranker
+		threshold float64
+		results   int
+	}{
+		{name: "keyword scores not thresholded", mode: vecdb.SearchKeyword, threshold: 100, results: 1},
+		{name: "vector scores thresholded", mode: vecdb.SearchVector, threshold: 1, results: 0},
+		{name: "reranked scores thresholded", mode: vecdb.SearchKeyword, reranker: judge, threshold: 0.5, results: 1},
+		{name: "reranked all kept", mode: vecdb.SearchVector, reranker: judge, threshold: 0, results: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := vecdb.Query{Text: "which has goroutines", Collection: "knowledge", Mode: tt.mode}
+			results, err := Retrieve(context.Background(), q, tt.reranker, Options{Candidates: 3, TopK: 3, Threshold: tt.threshold})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != tt.results {
+				t.Fatalf("%d results, expected %d: %+v", len(results), tt.results, results)
+			}
+			if len(results) > 0 && results[0].Text != knowledge[0] {
+				t.Fatalf("expected %q first, got %+v", knowledge[0], results)
+			}
+		})
+	}
+}
+
+// scored creates results with the scores
+func scored(scores ...float64) (results []vecdb.SearchResult) {
+	for _, s := range scores {
+		results = append(results, vecdb.SearchResult{Score: s})
+	}
+	return results
+}
+
+// fakeGenerator replies to the prompt with the func result
+type fakeGenerator func(prompt string) string
+
+func (g fakeGenerator) Generate(ctx context.Context, prompt string) (llm.Response, error) {
+	return llm.Response{Text: g(prompt)}, nil
+}
+
+func (g fakeGenerator) GenerateStream(ctx context.Context, prompt string, onToken func(token string)) (llm.Response, error) {
+	rsp, err := g.Generate(ctx, prompt)
+	onToken(rsp.Text)
+	return rsp, err
+}
+
+func (g fakeGenerator) Model() string {
+	return "fake"
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/mateuszmidor/AiStudy/rag/server"
+)
+
+// serveCommand runs the RAG HTTP server until interrupted
+func serveCommand(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", "localhost:8080", "address to listen on")
+	manifestDir := flags.String("manifest-dir", ".", "where the ingestion manifests are kept")
+	ingestRoot := flags.String("ingest-root", "", "the only dir whose files /ingest can read with paths; empty disables path ingestion")
+	model := addModelFlag(flags)
+	prompting := addContextFlags(flags)
+	flags.Parse(args)
+	setupGenerator(*model)
+
+	srv := &server.Server{
+		Generator:   generator,
+		Reranker:    reranker,
+		Retrieval:   retrieval,
+		Context:     prompting.options(),
+		ManifestDir: *manifestDir,
+		IngestRoot:  *ingestRoot,
+	}
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           srv.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// shut down gracefully on Ctrl+C, letting the running requests finish
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("serving", "addr", *addr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
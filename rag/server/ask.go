@@ -0,0 +1,191 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mateuszmidor/AiStudy/llm"
+	"github.com/mateuszmidor/AiStudy/rag/answer"
+	"github.com/mateuszmidor/AiStudy/rag/rerank"
+	"github.com/mateuszmidor/AiStudy/rag/transform"
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+// AskRequest asks the question; zero retrieval options mean the server defaults
+type AskRequest struct {
+	Question   string               `json:"question"` // REQUIRED
+	Collection string               `json:"collection,omitempty"`
+	Mode       string               `json:"mode,omitempty"` // [vector, keyword, hybrid], default vector
+	Filter     *vecdb.Filter        `json:"filter,omitempty"`
+	TopK       int                  `json:"top_k,omitempty"`
+	Candidates int                  `json:"candidates,omitempty"`
+	Threshold  *float64             `json:"threshold,omitempty"`
+	Search     *vecdb.SearchParams  `json:"search_params,omitempty"` // eg. {"hnsw_ef": 128, "quantization": {"rescore": true}}
+	Using      []string             `json:"using,omitempty"`         // named vectors to search, eg. ["text", "title"], fused if several; default text
+	Vectors    map[string][]float64 `json:"vectors,omitempty"`       // query vectors of the named vectors, eg. {"image": [...]}; default the question embedding
+	Transform  string               `json:"transform,omitempty"`     // [none, multi-query, hyde], default none
+	MaxTokens  int                  `json:"max_tokens,omitempty"`    // token budget of the prompt
+	Stream     bool                 `json:"stream,omitempty"`        // respond with server-sent events; also enabled with "Accept: text/event-stream"
+}
+
+// AskResponse is the answer with its cited sources, and all the chunks put into the prompt
+type AskResponse struct {
+	answer.Answer
+	Retrieved  []Retrieved `json:"retrieved"`
+	Model      string      `json:"model"`
+	Usage      llm.Usage   `json:"usage"`
+	DurationMs int64       `json:"duration_ms"`
+}
+
+// Retrieved is a chunk put into the prompt, numbered as in the prompt
+type Retrieved struct {
+	Ref     int                    `json:"ref"`
+	ID      string                 `json:"id"`
+	Score   float64                `json:"score"`
+	Text    string                 `json:"text"`
+	Payload map[string]interface{} `json:"payload,omitempty"`
+}
+
+// handleAsk answers the question. With streaming enabled, it sends server-sent events:
+// "retrieved" with the chunks, "token" with every generated token, and finally "answer" with the AskResponse, or "error"
+func (s *Server) handleAsk(w http.ResponseWriter, r *http.Request) {
+	var req AskRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if strings.TrimSpace(req.Question) == "" {
+		writeError(w, badRequest{"question is required"})
+		return
+	}
+	if req.Collection != "" {
+		if err := vecdb.CheckCollectionName(req.Collection); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	stream := req.Stream || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	start := time.Now()
+
+	// retrieve
+	opts, err := s.retrievalOptions(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	query := vecdb.Query{Text: req.Question, Collection: req.Collection, Mode: req.Mode, Filter: req.Filter, Params: req.Search, Using: req.Using, Vectors: req.Vectors}
+	results, err := rerank.Retrieve(r.Context(), query, s.Reranker, opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// generate
+	contextOpts := s.Context
+	if req.MaxTokens > 0 {
+		contextOpts.MaxTokens = req.MaxTokens
+	}
+	prompt, err := answer.BuildContext(req.Question, results, contextOpts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !stream {
+		rsp, err := s.Generator.Generate(r.Context(), prompt.Prompt)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, NewAskResponse(rsp, prompt.Pieces, start))
+		return
+	}
+
+	events, err := newEventStream(w)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	events.send("retrieved", NewRetrieved(prompt.Pieces))
+	rsp, err := s.Generator.GenerateStream(r.Context(), prompt.Prompt, func(token string) {
+		events.send("token", token)
+	})
+	if err != nil {
+		events.send("error", errorResponse{Error: err.Error()})
+		return
+	}
+	events.send("answer", NewAskResponse(rsp, prompt.Pieces, start))
+}
+
+// retrievalOptions applies the request overrides to the server defaults
+func (s *Server) retrievalOptions(req AskRequest) (rerank.Options, error) {
+	opts := s.Retrieval
+	if req.TopK > 0 {
+		opts.TopK = req.TopK
+	}
+	if req.Candidates > 0 {
+		opts.Candidates = req.Candidates
+	}
+	if req.Threshold != nil {
+		opts.Threshold = *req.Threshold
+	}
+	if req.Transform != "" {
+		transformer, err := transform.NewTransformer(req.Transform, s.Generator)
+		if err != nil {
+			return opts, badRequest{err.Error()}
+		}
+		opts.Transformer = transformer
+	}
+	return opts, nil
+}
+
+// NewAskResponse traces the generated response back to the pieces of the prompt it cites
+func NewAskResponse(rsp llm.Response, results []vecdb.SearchResult, start time.Time) AskResponse {
+	return AskResponse{
+		Answer:     answer.Parse(rsp.Text, results),
+		Retrieved:  NewRetrieved(results),
+		Model:      rsp.Model,
+		Usage:      rsp.Usage,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+}
+
+// NewRetrieved numbers the results as they are numbered in the prompt
+func NewRetrieved(results []vecdb.SearchResult) []Retrieved {
+	retrieved := make([]Retrieved, 0, len(results))
+	for i, result := range results {
+		retrieved = append(retrieved, Retrieved{Ref: i + 1, ID: result.ID, Score: result.Score, Text: result.Text, Payload: result.Payload})
+	}
+	return retrieved
+}
+
+// eventStream writes server-sent events, see: https://html.spec.whatwg.org/multipage/server-sent-events.html
+type eventStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func newEventStream(w http.ResponseWriter) (*eventStream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("streaming not supported")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	return &eventStream{w: w, flusher: flusher}, nil
+}
+
+// send writes the event with data encoded as JSON, so that it fits in a single line
+func (e *eventStream) send(event string, data interface{}) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		slog.Warn("failed to encode event", "event", event, "error", err)
+		return
+	}
+	fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", event, encoded)
+	e.flusher.Flush()
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mateuszmidor/AiStudy/rag/ingest"
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+// IngestRequest stores the documents sent in the request and/or the files found on the server under the paths
+type IngestRequest struct {
+	Collection string                 `json:"collection,omitempty"` // empty means the default collection
+	Documents  []IngestDocument       `json:"documents,omitempty"`
+	Paths      []string               `json:"paths,omitempty"`    // files or dirs under Server.IngestRoot, relative to it; ingested incrementally, like the ingest command
+	Chunker    string                 `json:"chunker,omitempty"`  // [fixed, sentence, heading], default sentence
+	Size       int                    `json:"size,omitempty"`     // max chunk size in characters, default 800
+	Overlap    *int                   `json:"overlap,omitempty"`  // default 1
+	Metadata   map[string]interface{} `json:"metadata,omitempty"` // stored in the payload of every chunk
+	Vectors    []string               `json:"vectors,omitempty"`  // named vectors embedded for every chunk of a new collection: [text, title]; default single vector of the text
+}
+
+// IngestDocument is a document sent in the request; it is split into chunks like a file would be.
+// Sending the same source again overwrites its chunks, the ones past the end of the shortened document get deleted
+type IngestDocument struct {
+	Source   string                 `json:"source"`            // REQUIRED, identifies the document, eg. its path or URL
+	Text     string                 `json:"text"`              // REQUIRED
+	Title    string                 `json:"title,omitempty"`   // embedded as title vector; default the first heading of the text
+	Vectors  map[string][]float64   `json:"vectors,omitempty"` // precomputed named vectors stored with every chunk, eg. {"image": [...]} from vision model
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// IngestResponse summarizes the ingestion
+type IngestResponse struct {
+	Collection    string   `json:"collection"`
+	Stored        int      `json:"stored"` // chunks of the documents sent in the request
+	Added         int      `json:"added"`  // chunks of the files, see ingest.SyncReport
+	Updated       int      `json:"updated"`
+	Deleted       int      `json:"deleted"` // stale chunks of both the documents and the files
+	Unchanged     int      `json:"unchanged"`
+	FailedBatches []string `json:"failed_batches,omitempty"`
+	DurationMs    int64    `json:"duration_ms"`
+}
+
+func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
+	var req IngestRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(req.Documents) == 0 && len(req.Paths) == 0 {
+		writeError(w, badRequest{"nothing to ingest, provide documents or paths"})
+		return
+	}
+	if req.Collection == "" {
+		req.Collection = vecdb.CollectionName()
+	}
+	manifestFile, err := ingest.DefaultManifestPath(req.Collection) // also rejects the names escaping ManifestDir, eg. ".."
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Chunker == "" {
+		req.Chunker = ingest.StrategySentence
+	}
+	if req.Size == 0 {
+		req.Size = 800
+	}
+	overlap := 1
+	if req.Overlap != nil {
+		overlap = *req.Overlap
+	}
+	chunker, err := ingest.NewChunker(req.Chunker, req.Size, overlap)
+	if err != nil {
+		writeError(w, badRequest{err.Error()})
+		return
+	}
+	paths, err := s.ingestPaths(req.Paths)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	opts := ingest.Options{Chunker: chunker, Metadata: req.Metadata, Feed: vecdb.DefaultFeedOptions()}
+	opts.Feed.Collection = req.Collection
+	opts.Feed.Append = true
+	opts.Feed.Vectors = req.Vectors
+
+	start := time.Now()
+	rsp := IngestResponse{Collection: req.Collection}
+
+	// both the documents and the files are tracked in the collection manifest
+	s.ingestMu.Lock()
+	defer s.ingestMu.Unlock()
+	manifestPath := filepath.Join(s.ManifestDir, manifestFile)
+	manifest, err := ingest.LoadManifest(manifestPath, req.Collection)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// documents sent in the request
+	var chunks []vecdb.Document
+	for i, doc := range req.Documents {
+		if doc.Source == "" || doc.Text == "" {
+			writeError(w, badRequest{fmt.Sprintf("document %d needs source and text", i)})
+			return
+		}
+		metadata := merge(req.Metadata, doc.Metadata)
+		title := doc.Title
+		if title == "" {
+			title = ingest.ExtractTitle(doc.Source, doc.Text)
+		}
+		chunks = append(chunks, ingest.ChunkDocuments([]ingest.Document{{Path: doc.Source, Title: title, Text: doc.Text, ModTime: start, Vectors: doc.Vectors}}, chunker, metadata)...)
+	}
+	if len(chunks) > 0 {
+		report, err := vecdb.FeedDocumentsContext(r.Context(), chunks, opts.Feed)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		rsp.Stored = report.Stored
+		for _, failed := range report.Failed {
+			rsp.FailedBatches = append(rsp.FailedBatches, failed.Error())
+		}
+
+		stale := ingest.RecordSources(manifest, chunks, report.Failed)
+		if err := manifest.Save(manifestPath); err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := vecdb.DeletePointsFrom(r.Context(), req.Collection, stale); err != nil {
+			writeError(w, err)
+			return
+		}
+		rsp.Deleted += len(stale)
+	}
+
+	// files on the server, synced with the collection manifest
+	if len(paths) > 0 {
+		for _, path := range paths {
+			report, syncErr := ingest.Sync(r.Context(), path, manifest, opts)
+			if err := manifest.Save(manifestPath); err != nil {
+				writeError(w, err)
+				return
+			}
+			if syncErr != nil {
+				writeError(w, syncErr)
+				return
+			}
+			rsp.Added += report.Added
+			rsp.Updated += report.Updated
+			rsp.Deleted += report.Deleted
+			rsp.Unchanged += report.Unchanged
+			for _, failed := range report.Failed {
+				rsp.FailedBatches = append(rsp.FailedBatches, failed.Error())
+			}
+		}
+	}
+
+	rsp.DurationMs = time.Since(start).Milliseconds()
+	writeJSON(w, http.StatusOK, rsp)
+}
+
+// ingestPaths resolves the requested paths against IngestRoot, following the symlinks; the paths outside of it are forbidden
+func (s *Server) ingestPaths(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	if s.IngestRoot == "" {
+		return nil, forbidden{"path ingestion is disabled, the server needs ingest root"}
+	}
+	root, err := filepath.Abs(s.IngestRoot)
+	if err == nil {
+		root, err = filepath.EvalSymlinks(root)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid ingest root: %w", err)
+	}
+
+	resolved := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+		path, err := filepath.EvalSymlinks(filepath.Clean(path))
+		if err != nil {
+			return nil, badRequest{fmt.Sprintf("invalid path: %v", err)}
+		}
+		if rel, err := filepath.Rel(root, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, forbidden{fmt.Sprintf("path %q is outside of the ingest root", path)}
+		}
+		resolved = append(resolved, path)
+	}
+	return resolved, nil
+}
+
+// merge returns the metadata overridden with the document specific metadata
+func merge(metadata, overrides map[string]interface{}) map[string]interface{} {
+	if len(overrides) == 0 {
+		return metadata
+	}
+	merged := map[string]interface{}{}
+	for k, v := range metadata {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
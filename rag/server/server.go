@@ -0,0 +1,242 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mateuszmidor/AiStudy/llm"
+	"github.com/mateuszmidor/AiStudy/rag/answer"
+	"github.com/mateuszmidor/AiStudy/rag/rerank"
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+// healthTimeout limits each health check
+const healthTimeout = 3 * time.Second
+
+// Server exposes the RAG over HTTP:
+// - POST /ingest       - store documents sent in the request, or files found on the server
+// - POST /ask          - answer the question, with the cited sources; streamed as server-sent events on request
+// - GET  /collections  - list the collections
+// - GET  /health       - check that the vector store and the LLM are reachable
+type Server struct {
+	Generator   llm.ChatModel
+	Reranker    rerank.Reranker       // nil means no reranking
+	Retrieval   rerank.Options        // defaults, can be overridden per request
+	Context     answer.ContextOptions // how the retrieved chunks are fitted into the prompt; the token budget can be overridden per request
+	ManifestDir string                // where the ingestion manifests are kept; empty means current dir
+	IngestRoot  string                // the only dir whose files can be ingested with IngestRequest.Paths; empty disables path ingestion
+
+	ingestMu sync.Mutex // ingestion updates the manifests, one at a time
+}
+
+// Handler returns the HTTP handler serving all the endpoints
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ingest", only("POST", s.handleIngest))
+	mux.HandleFunc("/ask", only("POST", s.handleAsk))
+	mux.HandleFunc("/collections", only("GET", s.handleCollections))
+	mux.HandleFunc("/health", only("GET", s.handleHealth))
+	return logRequests(mux)
+}
+
+// HealthResponse reports the state of the dependencies; "ok" or the error for each
+type HealthResponse struct {
+	Status string            `json:"status"` // ["ok", "unavailable"]
+	Checks map[string]string `json:"checks"`
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]func(ctx context.Context) error{
+		"vecdb": vecdb.Ping,
+		"llm":   func(ctx context.Context) error { return llm.Ping(ctx, s.Generator) },
+	}
+
+	rsp := HealthResponse{Status: "ok", Checks: map[string]string{}}
+	for name, check := range checks {
+		ctx, cancel := context.WithTimeout(r.Context(), healthTimeout)
+		err := check(ctx)
+		cancel()
+		rsp.Checks[name] = "ok"
+		if err != nil {
+			rsp.Checks[name] = err.Error()
+			rsp.Status = "unavailable"
+		}
+	}
+
+	status := http.StatusOK
+	if rsp.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, rsp)
+}
+
+// CollectionResponse describes a collection
+type CollectionResponse struct {
+	Name         string                        `json:"name"`
+	Aliases      []string                      `json:"aliases,omitempty"`
+	Status       string                        `json:"status"`
+	PointsCount  int                           `json:"points_count"`
+	Dimensions   int                           `json:"dimensions"`
+	Distance     string                        `json:"distance"`
+	Storage      vecdb.VectorStorage           `json:"storage"`                 // datatype, quantization, HNSW params; the unset ones are omitted
+	NamedVectors map[string]vecdb.VectorConfig `json:"named_vectors,omitempty"` // instead of the single vector above, eg. text, title and image
+	Model        string                        `json:"model,omitempty"`         // embedding model
+}
+
+func (s *Server) handleCollections(w http.ResponseWriter, r *http.Request) {
+	rsp, err := ListCollections(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rsp)
+}
+
+// ListCollections describes all the collections, along with the aliases pointing to them
+func ListCollections(ctx context.Context) ([]CollectionResponse, error) {
+	names, err := vecdb.ListCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := vecdb.ListAliases(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []CollectionResponse{}
+	for _, name := range names {
+		info, err := vecdb.DescribeCollection(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		c := CollectionResponse{
+			Name:         name,
+			Status:       info.Status,
+			PointsCount:  info.PointsCount,
+			Dimensions:   info.Vectors.Size,
+			Distance:     info.Vectors.Distance,
+			Storage:      info.Vectors.VectorStorage,
+			NamedVectors: info.NamedVectors,
+			Model:        info.Model,
+		}
+		for alias, collection := range aliases {
+			if collection == name {
+				c.Aliases = append(c.Aliases, alias)
+			}
+		}
+		sort.Strings(c.Aliases)
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+// only rejects the requests with other method
+func only(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed, use " + method})
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// errorResponse is returned with every non-2xx status
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// badRequest is the error in the request content
+type badRequest struct {
+	msg string
+}
+
+func (e badRequest) Error() string {
+	return e.msg
+}
+
+// forbidden is the request for something the server is not configured to allow
+type forbidden struct {
+	msg string
+}
+
+func (e forbidden) Error() string {
+	return e.msg
+}
+
+// errorStatus maps the error to HTTP status
+func errorStatus(err error) int {
+	var statusErr *llm.StatusError
+	switch {
+	case errors.As(err, &badRequest{}), errors.Is(err, vecdb.ErrUnknownVector), errors.Is(err, vecdb.ErrInvalidCollectionName):
+		return http.StatusBadRequest
+	case errors.As(err, &forbidden{}):
+		return http.StatusForbidden
+	case errors.Is(err, vecdb.ErrCollectionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, vecdb.ErrModelMismatch), errors.Is(err, vecdb.ErrDimensionMismatch):
+		return http.StatusConflict
+	case errors.Is(err, vecdb.ErrQdrantUnreachable), errors.Is(err, vecdb.ErrEmbedderFailed), errors.As(err, &statusErr):
+		return http.StatusBadGateway
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, errorStatus(err), errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("failed to write response", "error", err)
+	}
+}
+
+// decodeJSON reads the request body into v, rejecting unknown fields so that typos don't go unnoticed
+func decodeJSON(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return badRequest{"invalid request body: " + err.Error()}
+	}
+	return nil
+}
+
+// statusRecorder remembers the response status for logging; it passes Flush through for server-sent events
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// logRequests logs every request with its status and duration
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		slog.Info("request", "method", r.Method, "path", r.URL.Path, "status", recorder.status, "duration", time.Since(start))
+	})
+}
@@ -0,0 +1,293 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mateuszmidor/AiStudy/llm"
+	"github.com/mateuszmidor/AiStudy/rag/answer"
+	"github.com/mateuszmidor/AiStudy/rag/rerank"
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+// newTestServer serves the RAG over the embedded store and fake embedder in a temp dir, with the documents ingested
+func newTestServer(t *testing.T) (*httptest.Server, *Server) {
+	dir := t.TempDir()
+	vecdb.SetEmbedder(vecdb.NewFakeEmbedder(0))
+	vecdb.SetStore(vecdb.NewEmbeddedStore(dir))
+	vecdb.SetKeywordIndexDir(dir)
+	vecdb.UseCollection("knowledge")
+
+	s := &Server{
+		Generator:   fakeChatModel{},
+		Retrieval:   rerank.Options{TopK: 2},
+		Context:     answer.ContextOptions{},
+		ManifestDir: dir,
+	}
+	server := httptest.NewServer(s.Handler())
+	t.Cleanup(server.Close)
+
+	status, body := post(t, server.URL+"/ingest", `{"documents": [
+		{"source": "go.md", "text": "Go has goroutines."},
+		{"source": "rust.md", "text": "Rust guarantees memory safety.", "metadata": {"lang": "rust"}}
+	]}`)
+	if status != http.StatusOK {
+		t.Fatalf("ingest responded %d: %s", status, body)
+	}
+	return server, s
+}
+
+func TestIngest(t *testing.T) {
+	server, s := newTestServer(t)
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "python.md"), []byte("Python is dynamically typed."), 0o644)
+
+	tests := []struct {
+		name   string
+		root   string
+		body   string
+		status int
+		rsp    IngestResponse
+	}{
+		{
+			name:   "documents",
+			body:   `{"collection": "docs", "documents": [{"source": "go.md", "text": "Go has goroutines. Go compiles fast."}], "size": 20, "overlap": 0}`,
+			status: http.StatusOK,
+			rsp:    IngestResponse{Collection: "docs", Stored: 2},
+		},
+		{
+			name:   "shortened document",
+			body:   `{"collection": "docs", "documents": [{"source": "go.md", "text": "Go has goroutines."}], "size": 20, "overlap": 0}`,
+			status: http.StatusOK,
+			rsp:    IngestResponse{Collection: "docs", Stored: 1, Deleted: 1},
+		},
+		{
+			name:   "paths",
+			root:   root,
+			body:   `{"collection": "docs", "paths": ["python.md"]}`,
+			status: http.StatusOK,
+			rsp:    IngestResponse{Collection: "docs", Added: 1},
+		},
+		{name: "collection escaping manifest dir", body: `{"collection": "..", "documents": [{"source": "a", "text": "a"}]}`, status: http.StatusBadRequest},
+		{name: "collection with path", body: `{"collection": "../docs", "documents": [{"source": "a", "text": "a"}]}`, status: http.StatusBadRequest},
+		{name: "nothing to ingest", body: `{}`, status: http.StatusBadRequest},
+		{name: "document without text", body: `{"documents": [{"source": "a"}]}`, status: http.StatusBadRequest},
+		{name: "unknown field", body: `{"docs": []}`, status: http.StatusBadRequest},
+		{name: "unknown chunker", body: `{"documents": [{"source": "a", "text": "a"}], "chunker": "words"}`, status: http.StatusBadRequest},
+		{name: "paths disabled", body: `{"paths": ["python.md"]}`, status: http.StatusForbidden},
+		{name: "path outside root", root: root, body: `{"paths": ["../"]}`, status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.IngestRoot = tt.root
+			status, body := post(t, server.URL+"/ingest", tt.body)
+			if status != tt.status {
+				t.Fatalf("status %d, expected %d: %s", status, tt.status, body)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			var rsp IngestResponse
+			json.Unmarshal(body, &rsp)
+			rsp.DurationMs = 0
+			if !reflect.DeepEqual(rsp, tt.rsp) {
+				t.Fatalf("response %+v, expected %+v", rsp, tt.rsp)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(s.ManifestDir, ".manifest-docs.json")); err != nil {
+		t.Fatalf("manifest not saved: %v", err)
+	}
+}
+
+func TestAsk(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		sources []string
+	}{
+		{name: "answer", body: `{"question": "Which has goroutines?", "mode": "keyword"}`, status: http.StatusOK, sources: []string{"go.md"}},
+		{name: "filtered", body: `{"question": "Which is safe?", "threshold": -1, "filter": {"must": [{"key": "lang", "match": {"value": "rust"}}]}}`, status: http.StatusOK, sources: []string{"rust.md"}},
+		{name: "no question", body: `{"question": " "}`, status: http.StatusBadRequest},
+		{name: "invalid collection", body: `{"question": "Why?", "collection": ".."}`, status: http.StatusBadRequest},
+		{name: "unknown collection", body: `{"question": "Why?", "collection": "missing"}`, status: http.StatusNotFound},
+		{name: "unknown transform", body: `{"question": "Why?", "transform": "magic"}`, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := post(t, server.URL+"/ask", tt.body)
+			if status != tt.status {
+				t.Fatalf("status %d, expected %d: %s", status, tt.status, body)
+			}
+			if status != http.StatusOK {
+				var rsp errorResponse
+				if err := json.Unmarshal(body, &rsp); err != nil || rsp.Error == "" {
+					t.Fatalf("error response %s", body)
+				}
+				return
+			}
+			var rsp AskResponse
+			if err := json.Unmarshal(body, &rsp); err != nil {
+				t.Fatal(err)
+			}
+			var sources []string
+			for _, s := range rsp.Sources {
+				sources = append(sources, s.Payload["source"].(string))
+			}
+			if !reflect.DeepEqual(sources, tt.sources) || rsp.Model != "fake" || len(rsp.Retrieved) == 0 {
+				t.Fatalf("response %+v, expected sources %v", rsp, tt.sources)
+			}
+		})
+	}
+}
+
+func TestAskStream(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	for _, stream := range []struct {
+		name   string
+		body   string
+		accept string
+	}{
+		{name: "requested in body", body: `{"question": "Which has goroutines?", "mode": "keyword", "stream": true}`},
+		{name: "requested in header", body: `{"question": "Which has goroutines?", "mode": "keyword"}`, accept: "text/event-stream"},
+	} {
+		t.Run(stream.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", server.URL+"/ask", strings.NewReader(stream.body))
+			if stream.accept != "" {
+				req.Header.Set("Accept", stream.accept)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+				t.Fatalf("status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+			}
+
+			var names []string
+			var tokens []string
+			var last AskResponse
+			for _, event := range readEvents(t, resp) {
+				names = append(names, event[0])
+				switch event[0] {
+				case "token":
+					var token string
+					json.Unmarshal([]byte(event[1]), &token)
+					tokens = append(tokens, token)
+				case "answer":
+					json.Unmarshal([]byte(event[1]), &last)
+				}
+			}
+			expected := []string{"retrieved", "token", "token", "token", "token", "answer"}
+			if !reflect.DeepEqual(names, expected) {
+				t.Fatalf("events %v, expected %v", names, expected)
+			}
+			if strings.Join(tokens, "") != last.Text || len(last.Sources) != 1 || last.Sources[0].Payload["source"] != "go.md" {
+				t.Fatalf("answer %+v from tokens %q", last, tokens)
+			}
+		})
+	}
+}
+
+func TestCollections(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	resp, err := http.Get(server.URL + "/collections")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var collections []CollectionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&collections); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || len(collections) != 1 {
+		t.Fatalf("status %d, collections %+v", resp.StatusCode, collections)
+	}
+	c := collections[0]
+	if c.Name != "knowledge" || c.PointsCount != 2 || c.Dimensions == 0 || c.Model != vecdb.EmbedderModel() {
+		t.Fatalf("collection %+v", c)
+	}
+
+	status, _ := post(t, server.URL+"/collections", `{}`)
+	if status != http.StatusMethodNotAllowed {
+		t.Fatalf("POST /collections responded %d, expected 405", status)
+	}
+}
+
+// post sends the JSON body and returns the response status and body
+func post(t *testing.T, url, body string) (int, []byte) {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var rsp json.RawMessage
+	json.NewDecoder(resp.Body).Decode(&rsp)
+	return resp.StatusCode, rsp
+}
+
+// readEvents reads the server-sent events as [name, data] pairs
+func readEvents(t *testing.T, resp *http.Response) [][2]string {
+	t.Helper()
+	var data strings.Builder
+	if _, err := io.Copy(&data, resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	var events [][2]string
+	for _, block := range strings.Split(strings.TrimSpace(data.String()), "\n\n") {
+		var event [2]string
+		for _, line := range strings.Split(block, "\n") {
+			if name, ok := strings.CutPrefix(line, "event: "); ok {
+				event[0] = name
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				event[1] = data
+			}
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// fakeChatModel cites the first piece of the prompt, streaming the answer word by word
+type fakeChatModel struct{}
+
+func (fakeChatModel) Generate(ctx context.Context, prompt string) (llm.Response, error) {
+	return fakeChatModel{}.GenerateStream(ctx, prompt, func(string) {})
+}
+
+func (fakeChatModel) GenerateStream(ctx context.Context, prompt string, onToken func(token string)) (llm.Response, error) {
+	text := "It is this [1]."
+	for _, token := range strings.SplitAfter(text, " ") {
+		onToken(token)
+	}
+	return llm.Response{Text: text, Model: "fake"}, nil
+}
+
+func (fakeChatModel) Chat(ctx context.Context, messages []llm.Message) (llm.Response, error) {
+	return fakeChatModel{}.Generate(ctx, messages[len(messages)-1].Content)
+}
+
+func (fakeChatModel) ChatStream(ctx context.Context, messages []llm.Message, onToken func(token string)) (llm.Response, error) {
+	return fakeChatModel{}.GenerateStream(ctx, messages[len(messages)-1].Content, onToken)
+}
+
+func (fakeChatModel) Model() string {
+	return "fake"
+}
@@ -0,0 +1,43 @@
+package transform
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mateuszmidor/AiStudy/llm"
+)
+
+const hydePrompt = `Instruction: Write a short passage that answers the question below, as it could appear in a document. Write the passage only.
+Question: %s
+Passage:`
+
+// HyDE is hypothetical document embedding: the LLM writes a passage answering the question, and the passage is searched for
+// instead of the question. The passage may be wrong, but it resembles the documents more than the question does.
+// See: https://arxiv.org/abs/2212.10496
+type HyDE struct {
+	Generator llm.Generator
+}
+
+// NewHyDE creates HyDE
+func NewHyDE(generator llm.Generator) *HyDE {
+	return &HyDE{Generator: generator}
+}
+
+// Name implements Transformer
+func (h *HyDE) Name() string {
+	return StrategyHyDE
+}
+
+// Transform returns the hypothetical passage
+func (h *HyDE) Transform(ctx context.Context, question string) ([]string, error) {
+	rsp, err := h.Generator.Generate(ctx, fmt.Sprintf(hydePrompt, strings.TrimSpace(question)))
+	if err != nil {
+		return nil, err
+	}
+	passage := strings.TrimSpace(rsp.Text)
+	if passage == "" {
+		return nil, fmt.Errorf("empty hypothetical passage")
+	}
+	return []string{passage}, nil
+}
@@ -0,0 +1,57 @@
+package transform
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mateuszmidor/AiStudy/llm"
+)
+
+const multiQueryPrompt = `Instruction: Write %d different versions of the question below, to help finding the relevant documents in a search engine. Use different words and perspectives, but keep the meaning. Write one question per line, without numbering or any other text.
+Question: %s
+Alternative questions:`
+
+// listMarker matches the numbering or bullet the model puts in front of the lines despite being told not to, eg. "1. ", "- "
+var listMarker = regexp.MustCompile(`^\s*(\d+[.)]|[-*•])\s*`)
+
+// MultiQuery expands the question into its paraphrases; vague questions get more chances to match the documents' wording
+type MultiQuery struct {
+	Generator llm.Generator
+	Count     int // how many paraphrases to generate
+}
+
+// NewMultiQuery creates MultiQuery generating 3 paraphrases
+func NewMultiQuery(generator llm.Generator) *MultiQuery {
+	return &MultiQuery{Generator: generator, Count: 3}
+}
+
+// Name implements Transformer
+func (m *MultiQuery) Name() string {
+	return StrategyMultiQuery
+}
+
+// Transform returns the question followed by up to Count distinct paraphrases
+func (m *MultiQuery) Transform(ctx context.Context, question string) ([]string, error) {
+	question = strings.TrimSpace(question)
+	rsp, err := m.Generator.Generate(ctx, fmt.Sprintf(multiQueryPrompt, m.Count, question))
+	if err != nil {
+		return nil, err
+	}
+
+	texts := []string{question}
+	seen := map[string]bool{strings.ToLower(question): true}
+	for _, line := range strings.Split(rsp.Text, "\n") {
+		line = strings.TrimSpace(listMarker.ReplaceAllString(line, ""))
+		if line == "" || seen[strings.ToLower(line)] {
+			continue
+		}
+		if len(texts) > m.Count {
+			break
+		}
+		seen[strings.ToLower(line)] = true
+		texts = append(texts, line)
+	}
+	return texts, nil
+}
@@ -0,0 +1,85 @@
+package transform
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/mateuszmidor/AiStudy/llm"
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+// Transformer rewrites the question into the texts to search for, before the retrieval
+type Transformer interface {
+	Transform(ctx context.Context, question string) ([]string, error)
+	Name() string
+}
+
+// Transformation strategies
+const (
+	StrategyNone       = "none"        // search for the question as it is
+	StrategyMultiQuery = "multi-query" // search for the question and its LLM-generated paraphrases, merge the results
+	StrategyHyDE       = "hyde"        // search for an LLM-generated hypothetical passage answering the question
+)
+
+// NewTransformer creates Transformer by strategy name; empty name or StrategyNone means no transformation (nil Transformer).
+// The generator writes the paraphrases or the hypothetical passages
+func NewTransformer(name string, generator llm.Generator) (Transformer, error) {
+	switch name {
+	case "", StrategyNone:
+		return nil, nil
+	case StrategyMultiQuery:
+		return NewMultiQuery(generator), nil
+	case StrategyHyDE:
+		return NewHyDE(generator), nil
+	default:
+		return nil, fmt.Errorf("unknown query transformation %q, expected one of: %s, %s, %s", name, StrategyNone, StrategyMultiQuery, StrategyHyDE)
+	}
+}
+
+// Name returns the strategy name of the transformer, StrategyNone for nil
+func Name(t Transformer) string {
+	if t == nil {
+		return StrategyNone
+	}
+	return t.Name()
+}
+
+// Search finds q.Limit results for every text the question is transformed into, and merges them with reciprocal rank fusion,
+// so that the results found for many of the texts come first. The merged result keeps its best score from the searches,
+// so that thresholds keep their meaning. Nil transformer searches for the question as it is; so does a failed transformation
+func Search(ctx context.Context, q vecdb.Query, t Transformer) ([]vecdb.SearchResult, error) {
+	if t == nil {
+		return vecdb.AskDBQuery(ctx, q)
+	}
+
+	texts, err := t.Transform(ctx, q.Text)
+	if err != nil || len(texts) == 0 {
+		slog.Warn("searching for the question as it is", "transformation", t.Name(), "error", err)
+		return vecdb.AskDBQuery(ctx, q)
+	}
+	slog.Debug("transformed question", "transformation", t.Name(), "question", q.Text, "texts", texts)
+
+	var rankings [][]vecdb.SearchResult
+	best := map[string]float64{}
+	for _, text := range texts {
+		query := q
+		query.Text = text
+		results, err := vecdb.AskDBQuery(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			if score, ok := best[r.ID]; !ok || r.Score > score {
+				best[r.ID] = r.Score
+			}
+		}
+		rankings = append(rankings, results)
+	}
+
+	merged := vecdb.FuseRankings(rankings, nil, q.Limit)
+	for i := range merged {
+		merged[i].Score = best[merged[i].ID]
+	}
+	return merged, nil
+}
@@ -0,0 +1,238 @@
+package transform
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mateuszmidor/AiStudy/llm"
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+func TestMultiQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		err   error
+		texts []string
+	}{
+		{
+			name:  "one per line",
+			reply: "What animals are there?\nWhich species exist?\nList the animals.",
+			texts: []string{"What animals do you know?", "What animals are there?", "Which species exist?", "List the animals."},
+		},
+		{
+			name:  "numbering and bullets removed",
+			reply: "1. What animals are there?\n2) Which species exist?\n- List the animals.",
+			texts: []string{"What animals do you know?", "What animals are there?", "Which species exist?", "List the animals."},
+		},
+		{
+			name:  "duplicates and blank lines skipped",
+			reply: "\nwhat animals do you know?\n* Which species exist?\n\nWHICH SPECIES EXIST?\n",
+			texts: []string{"What animals do you know?", "Which species exist?"},
+		},
+		{
+			name:  "capped at count",
+			reply: "A?\nB?\nC?\nD?\nE?",
+			texts: []string{"What animals do you know?", "A?", "B?", "C?"},
+		},
+		{
+			name: "generator failed",
+			err:  errors.New("model unreachable"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prompt string
+			m := NewMultiQuery(fakeGenerator(func(p string) (string, error) {
+				prompt = p
+				return tt.reply, tt.err
+			}))
+			texts, err := m.Transform(context.Background(), " What animals do you know? ")
+			if (err != nil) != (tt.err != nil) {
+				t.Fatalf("error %v, expected %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(texts, tt.texts) {
+				t.Fatalf("texts %q, expected %q", texts, tt.texts)
+			}
+			if !strings.Contains(prompt, "Write 3 different versions") || !strings.Contains(prompt, "Question: What animals do you know?\n") {
+				t.Fatalf("prompt:\n%s", prompt)
+			}
+		})
+	}
+}
+
+func TestHyDE(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		err   error
+		texts []string
+	}{
+		{name: "passage", reply: " Cats and dogs are popular pets.\n", texts: []string{"Cats and dogs are popular pets."}},
+		{name: "empty passage", reply: " \n", err: errors.New("empty")},
+		{name: "generator failed", err: errors.New("model unreachable")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prompt string
+			h := NewHyDE(fakeGenerator(func(p string) (string, error) {
+				prompt = p
+				if tt.reply == "" {
+					return "", tt.err
+				}
+				return tt.reply, nil
+			}))
+			texts, err := h.Transform(context.Background(), "What animals do you know?")
+			if (err != nil) != (tt.err != nil) {
+				t.Fatalf("error %v, expected %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(texts, tt.texts) {
+				t.Fatalf("texts %q, expected %q", texts, tt.texts)
+			}
+			if !strings.Contains(prompt, "Question: What animals do you know?\nPassage:") {
+				t.Fatalf("prompt:\n%s", prompt)
+			}
+		})
+	}
+}
+
+func TestNewTransformer(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		valid    bool
+	}{
+		{"", StrategyNone, true},
+		{StrategyNone, StrategyNone, true},
+		{StrategyMultiQuery, StrategyMultiQuery, true},
+		{StrategyHyDE, StrategyHyDE, true},
+		{"magic", "", false},
+	}
+	for _, tt := range tests {
+		transformer, err := NewTransformer(tt.name, fakeGenerator(nil))
+		if (err == nil) != tt.valid {
+			t.Errorf("NewTransformer(%q): error %v, expected valid %v", tt.name, err, tt.valid)
+			continue
+		}
+		if tt.valid && Name(transformer) != tt.strategy {
+			t.Errorf("NewTransformer(%q) is %q, expected %q", tt.name, Name(transformer), tt.strategy)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	knowledge := feedKnowledge(t)
+	paraphrases := fakeGenerator(func(prompt string) (string, error) {
+		return "goroutines\nownership goroutines", nil
+	})
+	failing := fakeGenerator(func(prompt string) (string, error) {
+		return "", errors.New("model unreachable")
+	})
+
+	tests := []struct {
+		name        string
+		transformer Transformer
+		texts       []string
+	}{
+		{name: "question as it is", texts: nil},
+		{name: "paraphrases merged", transformer: NewMultiQuery(paraphrases), texts: []string{knowledge[0], knowledge[1]}},
+		{name: "failed transformation", transformer: NewMultiQuery(failing), texts: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := vecdb.Query{Text: "which language", Collection: "knowledge", Limit: 3, Mode: vecdb.SearchKeyword}
+			results, err := Search(context.Background(), q, tt.transformer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var texts []string
+			for _, r := range results {
+				texts = append(texts, r.Text)
+			}
+			if !reflect.DeepEqual(texts, tt.texts) {
+				t.Fatalf("found %q, expected %q", texts, tt.texts)
+			}
+		})
+	}
+}
+
+func TestSearchKeepsBestScore(t *testing.T) {
+	knowledge := feedKnowledge(t)
+	q := vecdb.Query{Text: "goroutines", Collection: "knowledge", Limit: 3, Mode: vecdb.SearchVector}
+	texts := []string{"goroutines", "memory ownership", "generators yield"}
+
+	best := map[string]float64{}
+	for _, text := range texts {
+		query := q
+		query.Text = text
+		results, err := vecdb.AskDBQuery(context.Background(), query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range results {
+			best[r.Text] = max(best[r.Text], r.Score)
+		}
+	}
+
+	merged, err := Search(context.Background(), q, fixedTransformer(texts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []string
+	for _, r := range merged {
+		found = append(found, r.Text)
+		if r.Score != best[r.Text] {
+			t.Errorf("%q scored %v, expected its best score %v", r.Text, r.Score, best[r.Text])
+		}
+	}
+	sort.Strings(found)
+	if !reflect.DeepEqual(found, []string{knowledge[0], knowledge[2], knowledge[1]}) {
+		t.Fatalf("found %q, expected all the knowledge", found)
+	}
+}
+
+// feedKnowledge stores three texts in the embedded store in a temp dir, with the fake embedder
+func feedKnowledge(t *testing.T) []string {
+	dir := t.TempDir()
+	vecdb.SetEmbedder(vecdb.NewFakeEmbedder(0))
+	vecdb.SetStore(vecdb.NewEmbeddedStore(dir))
+	vecdb.SetKeywordIndexDir(dir)
+	knowledge := []string{"Go has goroutines.", "Rust guarantees ownership.", "Python yields generators."}
+	if _, err := vecdb.FeedDBContext(context.Background(), knowledge, vecdb.FeedOptions{Collection: "knowledge"}); err != nil {
+		t.Fatal(err)
+	}
+	return knowledge
+}
+
+// fixedTransformer transforms every question into the same texts
+type fixedTransformer []string
+
+func (f fixedTransformer) Transform(ctx context.Context, question string) ([]string, error) {
+	return f, nil
+}
+
+func (f fixedTransformer) Name() string {
+	return "fixed"
+}
+
+// fakeGenerator replies to the prompt with the func result
+type fakeGenerator func(prompt string) (string, error)
+
+func (g fakeGenerator) Generate(ctx context.Context, prompt string) (llm.Response, error) {
+	text, err := g(prompt)
+	return llm.Response{Text: text}, err
+}
+
+func (g fakeGenerator) GenerateStream(ctx context.Context, prompt string, onToken func(token string)) (llm.Response, error) {
+	rsp, err := g.Generate(ctx, prompt)
+	onToken(rsp.Text)
+	return rsp, err
+}
+
+func (g fakeGenerator) Model() string {
+	return "fake"
+}
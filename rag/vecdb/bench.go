@@ -0,0 +1,164 @@
+package vecdb
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BenchOptions controls the vector store benchmark
+type BenchOptions struct {
+	Points      int           // how many random points are upserted
+	Dimensions  int           // vector size
+	BatchSize   int           // points per upsert call
+	Queries     int           // how many searches are run
+	Limit       int           // search results per query
+	Concurrency int           // how many upserts and searches run in parallel
+	TextSize    int           // length of the text stored in the payload of every point, as in the real chunks
+	Storage     VectorStorage // how the benchmark collection stores the vectors, eg. with quantization
+	Search      *SearchParams // optional, eg. HNSW ef or quantization rescoring
+}
+
+// DefaultBenchOptions returns the options resembling ingestion of mid-sized documentation
+func DefaultBenchOptions() BenchOptions {
+	return BenchOptions{Points: 10000, Dimensions: 384, BatchSize: 256, Queries: 500, Limit: 10, Concurrency: 4, TextSize: 800}
+}
+
+// BenchReport summarizes the throughput of the vector store
+type BenchReport struct {
+	Points           int           `json:"points"`
+	Dimensions       int           `json:"dimensions"`
+	UpsertDuration   time.Duration `json:"upsert_duration_ns"`
+	PointsPerSecond  float64       `json:"points_per_second"`
+	Queries          int           `json:"queries"`
+	SearchDuration   time.Duration `json:"search_duration_ns"`
+	QueriesPerSecond float64       `json:"queries_per_second"`
+	SearchP50        time.Duration `json:"search_p50_ns"`
+	SearchP95        time.Duration `json:"search_p95_ns"`
+}
+
+// Benchmark measures the upsert and search throughput of the store on random vectors. The collection gets created
+// and deleted afterwards; it must not exist. The vectors are generated with fixed seed, so every run stores the same points
+func Benchmark(ctx context.Context, s VectorStore, collection string, opts BenchOptions) (BenchReport, error) {
+	if opts.Points <= 0 || opts.Dimensions <= 0 || opts.BatchSize <= 0 || opts.Concurrency <= 0 {
+		return BenchReport{}, fmt.Errorf("invalid benchmark options: %+v", opts)
+	}
+
+	config := CollectionConfig{Vectors: VectorConfig{Size: opts.Dimensions, Distance: DefaultDistance, VectorStorage: opts.Storage}}
+	if err := s.CreateCollection(ctx, collection, config); err != nil {
+		return BenchReport{}, err
+	}
+	defer s.DeleteCollection(context.WithoutCancel(ctx), collection)
+
+	rng := rand.New(rand.NewSource(1))
+	text := randomText(rng, opts.TextSize)
+	points := make([]Point, opts.Points)
+	for i := range points {
+		points[i] = Point{
+			ID:      generateMD5HashString(fmt.Sprint(i)),
+			Vector:  randomVector(rng, opts.Dimensions),
+			Payload: map[string]interface{}{"text": text, "chunk_index": i, "source": fmt.Sprintf("bench/doc-%d.md", i/100)},
+		}
+	}
+	queries := make([][]float32, opts.Queries)
+	for i := range queries {
+		queries[i] = randomVector(rng, opts.Dimensions)
+	}
+
+	report := BenchReport{Points: opts.Points, Dimensions: opts.Dimensions, Queries: opts.Queries}
+
+	start := time.Now()
+	err := inParallel(opts.Concurrency, (len(points)+opts.BatchSize-1)/opts.BatchSize, func(i int) error {
+		batch := points[i*opts.BatchSize : min((i+1)*opts.BatchSize, len(points))]
+		return s.Upsert(ctx, collection, batch)
+	})
+	if err != nil {
+		return report, err
+	}
+	report.UpsertDuration = time.Since(start)
+	report.PointsPerSecond = float64(opts.Points) / report.UpsertDuration.Seconds()
+
+	latencies := make([]time.Duration, len(queries))
+	start = time.Now()
+	err = inParallel(opts.Concurrency, len(queries), func(i int) error {
+		queryStart := time.Now()
+		_, err := s.Search(ctx, collection, SearchQuery{Vector: queries[i], Params: opts.Search, Limit: opts.Limit, WithPayload: true})
+		latencies[i] = time.Since(queryStart)
+		return err
+	})
+	if err != nil {
+		return report, err
+	}
+	report.SearchDuration = time.Since(start)
+	if len(queries) > 0 {
+		report.QueriesPerSecond = float64(len(queries)) / report.SearchDuration.Seconds()
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		report.SearchP50 = latencies[len(latencies)/2]
+		report.SearchP95 = latencies[len(latencies)*95/100]
+	}
+	return report, nil
+}
+
+// inParallel runs job for 0..count-1 using a pool of workers; returns the first error, the jobs not started yet are skipped then
+func inParallel(workers, count int, job func(i int) error) error {
+	jobs := make(chan int)
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := job(i); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		mu.Lock()
+		failed := len(errs) > 0
+		mu.Unlock()
+		if failed {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// randomVector returns vector of normally distributed values
+func randomVector(rng *rand.Rand, dimensions int) []float32 {
+	v := make([]float32, dimensions)
+	for i := range v {
+		v[i] = float32(rng.NormFloat64())
+	}
+	return v
+}
+
+// randomText returns text of random lowercase words
+func randomText(rng *rand.Rand, size int) string {
+	text := make([]byte, size)
+	for i := range text {
+		if rng.Intn(6) == 0 {
+			text[i] = ' '
+		} else {
+			text[i] = byte('a' + rng.Intn(26))
+		}
+	}
+	return string(text)
+}
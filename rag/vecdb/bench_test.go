@@ -0,0 +1,114 @@
+package vecdb
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// The benchmarks compare the REST and gRPC transports of qdrant at DefaultQdrantURL and DefaultQdrantGRPCAddr,
+// they are skipped if qdrant is not running there:
+//
+//	go test ./vecdb -run '^$' -bench 'REST|GRPC'
+const (
+	benchDimensions = 384
+	benchBatchSize  = 256
+	benchPoints     = 10000 // stored before the search benchmark starts
+	benchLimit      = 10
+)
+
+func BenchmarkUpsertREST(b *testing.B) {
+	benchmarkUpsert(b, NewQdrantStore(""))
+}
+
+func BenchmarkUpsertGRPC(b *testing.B) {
+	s := NewQdrantGRPCStore("")
+	b.Cleanup(func() { s.Close() })
+	benchmarkUpsert(b, s)
+}
+
+func BenchmarkSearchREST(b *testing.B) {
+	benchmarkSearch(b, NewQdrantStore(""))
+}
+
+func BenchmarkSearchGRPC(b *testing.B) {
+	s := NewQdrantGRPCStore("")
+	b.Cleanup(func() { s.Close() })
+	benchmarkSearch(b, s)
+}
+
+// benchmarkUpsert measures upserting batches of random points, one batch per iteration
+func benchmarkUpsert(b *testing.B, s VectorStore) {
+	collection := benchCollection(b, s)
+	rng := rand.New(rand.NewSource(1))
+	batch := benchPointsOf(rng, 0, benchBatchSize)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range batch {
+			batch[j].ID = generateMD5HashString(fmt.Sprint(i*benchBatchSize + j))
+		}
+		if err := s.Upsert(context.Background(), collection, batch); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.N*benchBatchSize)/b.Elapsed().Seconds(), "points/s")
+}
+
+// benchmarkSearch measures searching collection of benchPoints random points, one query per iteration
+func benchmarkSearch(b *testing.B, s VectorStore) {
+	collection := benchCollection(b, s)
+	rng := rand.New(rand.NewSource(1))
+	for start := 0; start < benchPoints; start += benchBatchSize {
+		if err := s.Upsert(context.Background(), collection, benchPointsOf(rng, start, min(benchBatchSize, benchPoints-start))); err != nil {
+			b.Fatal(err)
+		}
+	}
+	queries := make([][]float32, 100)
+	for i := range queries {
+		queries[i] = randomVector(rng, benchDimensions)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		query := SearchQuery{Vector: queries[i%len(queries)], Limit: benchLimit, WithPayload: true}
+		if _, err := s.Search(context.Background(), collection, query); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchCollection creates fresh collection for the benchmark, deleted when it's done; skips the benchmark if qdrant is unreachable
+func benchCollection(b *testing.B, s VectorStore) string {
+	b.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := s.Ping(ctx); err != nil {
+		b.Skipf("qdrant unreachable: %v", err)
+	}
+
+	collection := "bench_" + b.Name()
+	s.DeleteCollection(context.Background(), collection) // left by interrupted run
+	config := CollectionConfig{Vectors: VectorConfig{Size: benchDimensions, Distance: DefaultDistance}}
+	if err := s.CreateCollection(context.Background(), collection, config); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { s.DeleteCollection(context.Background(), collection) })
+	return collection
+}
+
+// benchPointsOf returns count random points with ids made of the numbers from start on
+func benchPointsOf(rng *rand.Rand, start, count int) []Point {
+	text := randomText(rng, 800)
+	points := make([]Point, count)
+	for i := range points {
+		points[i] = Point{
+			ID:      generateMD5HashString(fmt.Sprint(start + i)),
+			Vector:  randomVector(rng, benchDimensions),
+			Payload: map[string]interface{}{"text": text, "chunk_index": start + i},
+		}
+	}
+	return points
+}
@@ -0,0 +1,282 @@
+package vecdb
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// BM25 parameters, the usual defaults
+const (
+	bm25K1 = 1.2  // term frequency saturation
+	bm25B  = 0.75 // document length normalization
+)
+
+// KeywordIndex is a local BM25 index of the stored texts, kept alongside the qdrant collection.
+// It finds exact identifiers, error codes and product names that the embeddings tend to miss
+type KeywordIndex struct {
+	mu          sync.RWMutex
+	Docs        map[string]keywordDoc `json:"docs"`  // point id -> document
+	DocFreq     map[string]int        `json:"df"`    // term -> number of documents containing it
+	TotalLength int                   `json:"total"` // sum of document lengths, in terms
+}
+
+// keywordDoc is a single indexed document
+type keywordDoc struct {
+	Terms   map[string]int         `json:"terms"` // term -> frequency
+	Length  int                    `json:"length"`
+	Payload map[string]interface{} `json:"payload"` // including "text"
+}
+
+// NewKeywordIndex creates empty index
+func NewKeywordIndex() *KeywordIndex {
+	return &KeywordIndex{Docs: map[string]keywordDoc{}, DocFreq: map[string]int{}}
+}
+
+// Add indexes the point payload "text", replacing the previous version of the point
+func (idx *KeywordIndex) Add(point Point) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(point.ID)
+	text, _ := point.Payload["text"].(string)
+	terms := tokenize(text)
+	doc := keywordDoc{Terms: map[string]int{}, Length: len(terms), Payload: point.Payload}
+	for _, term := range terms {
+		doc.Terms[term]++
+	}
+	for term := range doc.Terms {
+		idx.DocFreq[term]++
+	}
+	idx.Docs[point.ID] = doc
+	idx.TotalLength += doc.Length
+}
+
+// Remove drops the point from index
+func (idx *KeywordIndex) Remove(id string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+}
+
+func (idx *KeywordIndex) remove(id string) {
+	doc, ok := idx.Docs[id]
+	if !ok {
+		return
+	}
+	for term := range doc.Terms {
+		idx.DocFreq[term]--
+		if idx.DocFreq[term] == 0 {
+			delete(idx.DocFreq, term)
+		}
+	}
+	idx.TotalLength -= doc.Length
+	delete(idx.Docs, id)
+}
+
+// Search returns up to limit documents matching the filter, best BM25 score first; documents sharing no terms with the query are skipped
+func (idx *KeywordIndex) Search(query string, limit int, filter *Filter) []SearchResult {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if len(idx.Docs) == 0 {
+		return nil
+	}
+	avgLength := float64(idx.TotalLength) / float64(len(idx.Docs))
+	queryTerms := uniq(tokenize(query))
+
+	var results []SearchResult
+	for id, doc := range idx.Docs {
+		var score float64
+		for _, term := range queryTerms {
+			tf := float64(doc.Terms[term])
+			if tf == 0 {
+				continue
+			}
+			df := float64(idx.DocFreq[term])
+			idf := math.Log(1 + (float64(len(idx.Docs))-df+0.5)/(df+0.5))
+			score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(doc.Length)/avgLength))
+		}
+		if score == 0 || !filter.Matches(doc.Payload) {
+			continue
+		}
+		text, _ := doc.Payload["text"].(string)
+		results = append(results, SearchResult{ID: id, Score: score, Text: text, Payload: doc.Payload})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// Len returns the number of indexed documents
+func (idx *KeywordIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.Docs)
+}
+
+// save writes the index to file, through a unique temporary file so that interrupted save doesn't leave broken index
+func (idx *KeywordIndex) save(path string) error {
+	idx.mu.RLock()
+	data, err := json.Marshal(idx)
+	idx.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// loadKeywordIndex reads the index from file
+func loadKeywordIndex(path string) (*KeywordIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	idx := NewKeywordIndex()
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// tokenize splits text into lowercase terms; "_" is kept inside the terms so that identifiers like ERR_TIMEOUT stay whole
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '_'
+	})
+}
+
+// uniq removes duplicates, keeping the order
+func uniq(terms []string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, t := range terms {
+		if !seen[t] {
+			seen[t] = true
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// keywordIndexDir is where the keyword indexes are stored, one file per collection
+var keywordIndexDir = "."
+
+// SetKeywordIndexDir selects the directory where the keyword indexes are stored; the indexes loaded from the previous one are forgotten
+func SetKeywordIndexDir(dir string) {
+	keywordIndexesMu.Lock()
+	defer keywordIndexesMu.Unlock()
+	keywordIndexDir = dir
+	keywordIndexes = map[string]*KeywordIndex{}
+}
+
+// keywordIndexes caches the indexes loaded from files, by collection name; the saves of every collection are serialized,
+// so that concurrent ingestion and deletion don't persist older version of the index over the newer one
+var (
+	keywordIndexes   = map[string]*KeywordIndex{}
+	keywordSaves     = map[string]*sync.Mutex{}
+	keywordIndexesMu sync.Mutex
+)
+
+// keywordIndexPath returns the index file of the collection
+func keywordIndexPath(collection string) (string, error) {
+	if err := CheckCollectionName(collection); err != nil {
+		return "", err
+	}
+	return filepath.Join(keywordIndexDir, ".bm25-"+collection+".json"), nil
+}
+
+// keywordIndex returns the index of the collection, loading it from file or creating an empty one
+func keywordIndex(collection string) (*KeywordIndex, error) {
+	keywordIndexesMu.Lock()
+	defer keywordIndexesMu.Unlock()
+
+	if idx, ok := keywordIndexes[collection]; ok {
+		return idx, nil
+	}
+	path, err := keywordIndexPath(collection)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := loadKeywordIndex(path)
+	if errors.Is(err, os.ErrNotExist) {
+		idx, err = NewKeywordIndex(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	keywordIndexes[collection] = idx
+	return idx, nil
+}
+
+// saveKeywordIndex writes the index of the collection to file
+func saveKeywordIndex(collection string) error {
+	keywordIndexesMu.Lock()
+	saveMu, ok := keywordSaves[collection]
+	if !ok {
+		saveMu = &sync.Mutex{}
+		keywordSaves[collection] = saveMu
+	}
+	keywordIndexesMu.Unlock()
+
+	saveMu.Lock()
+	defer saveMu.Unlock()
+	idx, err := keywordIndex(collection)
+	if err != nil {
+		return err
+	}
+	path, err := keywordIndexPath(collection)
+	if err != nil {
+		return err
+	}
+	return idx.save(path)
+}
+
+// dropKeywordIndex removes the index of the collection, eg. when the collection gets deleted
+func dropKeywordIndex(collection string) error {
+	keywordIndexesMu.Lock()
+	delete(keywordIndexes, collection)
+	keywordIndexesMu.Unlock()
+
+	path, err := keywordIndexPath(collection)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+package vecdb
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestKeywordIndexSearch(t *testing.T) {
+	idx := NewKeywordIndex()
+	for id, text := range map[string]string{
+		"1": "connection error ERR_CONN_RESET",
+		"2": "connection error timeout",
+		"3": "error error error retry",
+		"4": "Ownership and borrowing",
+	} {
+		idx.Add(Point{ID: id, Payload: map[string]interface{}{"text": text, "lang": "en"}})
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		limit  int
+		filter *Filter
+		ids    []string
+	}{
+		{name: "identifier", query: "what does err_conn_reset mean?", limit: 3, ids: []string{"1"}},
+		{name: "rare term first", query: "timeout error", limit: 3, ids: []string{"2", "3", "1"}},
+		{name: "term frequency", query: "error", limit: 3, ids: []string{"3", "1", "2"}},
+		{name: "limit", query: "error", limit: 1, ids: []string{"3"}},
+		{name: "case insensitive", query: "OWNERSHIP", limit: 3, ids: []string{"4"}},
+		{name: "no common terms", query: "goroutines", limit: 3, ids: nil},
+		{name: "filter", query: "error", limit: 3, filter: &Filter{MustNot: []Condition{MatchValue("lang", "en")}}, ids: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			for _, r := range idx.Search(tt.query, tt.limit, tt.filter) {
+				ids = append(ids, r.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Fatalf("found %v, expected %v", ids, tt.ids)
+			}
+		})
+	}
+}
+
+func TestKeywordIndexScore(t *testing.T) {
+	idx := NewKeywordIndex()
+	idx.Add(Point{ID: "1", Payload: map[string]interface{}{"text": "a b"}})
+	idx.Add(Point{ID: "2", Payload: map[string]interface{}{"text": "a c"}})
+
+	// idf = ln(1 + (2 - 1 + 0.5) / (1 + 0.5)) = ln 2; tf part = 1 * 2.2 / (1 + 1.2 * (1 - 0.75 + 0.75 * 2/2)) = 1
+	results := idx.Search("b", 1, nil)
+	if len(results) != 1 || math.Abs(results[0].Score-math.Ln2) > 1e-9 {
+		t.Fatalf("results %+v, expected score ln 2", results)
+	}
+}
+
+func TestKeywordIndexReplaceAndRemove(t *testing.T) {
+	idx := NewKeywordIndex()
+	idx.Add(Point{ID: "1", Payload: map[string]interface{}{"text": "old text"}})
+	idx.Add(Point{ID: "1", Payload: map[string]interface{}{"text": "new text"}})
+	idx.Add(Point{ID: "2", Payload: map[string]interface{}{"text": "other text"}})
+
+	if results := idx.Search("old", 3, nil); len(results) != 0 {
+		t.Fatalf("replaced text still found: %+v", results)
+	}
+	idx.Remove("2")
+	idx.Remove("missing")
+	if idx.Len() != 1 || idx.TotalLength != 2 || !reflect.DeepEqual(idx.DocFreq, map[string]int{"new": 1, "text": 1}) {
+		t.Fatalf("index after removal: %d docs, total length %d, df %v", idx.Len(), idx.TotalLength, idx.DocFreq)
+	}
+}
+
+func TestKeywordIndexSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".bm25-knowledge.json")
+	idx := NewKeywordIndex()
+	idx.Add(Point{ID: "1", Payload: map[string]interface{}{"text": "goroutines and channels"}})
+	if err := idx.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadKeywordIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Search("channels", 1, nil), idx.Search("channels", 1, nil)) {
+		t.Fatal("loaded index finds other results than the saved one")
+	}
+}
+
+func TestKeywordIndexPath(t *testing.T) {
+	SetKeywordIndexDir("indexes")
+	defer SetKeywordIndexDir(".")
+
+	tests := []struct {
+		collection string
+		path       string
+	}{
+		{"knowledge", filepath.Join("indexes", ".bm25-knowledge.json")},
+		{"..", ""},
+		{"../knowledge", ""},
+		{".hidden", ""},
+	}
+	for _, tt := range tests {
+		path, err := keywordIndexPath(tt.collection)
+		if path != tt.path || (err == nil) != (tt.path != "") {
+			t.Errorf("keywordIndexPath(%q) = %q, %v; expected %q", tt.collection, path, err, tt.path)
+		}
+	}
+	if _, err := keywordIndex(".."); !errors.Is(err, ErrInvalidCollectionName) {
+		t.Fatalf("loading index of \"..\": %v, expected invalid name", err)
+	}
+}
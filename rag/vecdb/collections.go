@@ -0,0 +1,323 @@
+package vecdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Collection metadata keys, recorded at collection creation (qdrant stores them since 1.16)
+const (
+	MetadataEmbeddingModel      = "embedding_model"
+	MetadataEmbeddingDimensions = "embedding_dimensions"
+)
+
+// DefaultDistance is used when VectorConfig.Distance is not specified
+const DefaultDistance = DistanceCosine
+
+// collectionNamePattern keeps collection names usable as file names: the embedded store, keyword index and manifest files are named after them
+var collectionNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+
+// CheckCollectionName returns ErrInvalidCollectionName if the name could escape the directory of the files named after it, eg. ".."
+func CheckCollectionName(name string) error {
+	if !collectionNamePattern.MatchString(name) {
+		return fmt.Errorf("%w %q, allowed are letters, digits, '_', '-' and '.', not leading", ErrInvalidCollectionName, name)
+	}
+	return nil
+}
+
+// collectionName is the collection (or alias) used by FeedDB and AskDB
+var collectionName = "knowledge"
+
+// UseCollection selects the collection (or alias) used by FeedDB and AskDB
+func UseCollection(name string) {
+	collectionName = name
+}
+
+// CollectionName returns the collection (or alias) used by FeedDB and AskDB
+func CollectionName() string {
+	return collectionName
+}
+
+// collectionOr returns name, or the collection selected with UseCollection if name is empty
+func collectionOr(name string) string {
+	if name == "" {
+		return collectionName
+	}
+	return name
+}
+
+// CollectionInfo describes a collection
+type CollectionInfo struct {
+	Name         string
+	Status       string // ["green", "yellow", "grey", "red"]
+	PointsCount  int
+	Vectors      VectorConfig            // the single vector of every point, zero if the collection has named vectors
+	NamedVectors map[string]VectorConfig // the named vectors of every point, nil if the collection has single vector
+	Model        string                  // embedding model recorded at creation, empty if unknown
+	Metadata     map[string]string       // all the recorded metadata
+}
+
+// CreateCollection creates collection for vectors produced by the embedding model; empty vectors.Distance means DefaultDistance
+func CreateCollection(ctx context.Context, name string, vectors VectorConfig, model string) error {
+	return addCollection(ctx, name, CollectionConfig{Vectors: vectors}, model)
+}
+
+// CreateNamedCollection creates collection with several named vectors per point, eg. VectorText and VectorTitle produced by
+// the embedding model, and VectorImage produced by vision model; empty Distance of any vector means DefaultDistance
+func CreateNamedCollection(ctx context.Context, name string, vectors map[string]VectorConfig, model string) error {
+	return addCollection(ctx, name, CollectionConfig{NamedVectors: vectors}, model)
+}
+
+// ListCollections returns names of all collections, sorted
+func ListCollections(ctx context.Context) ([]string, error) {
+	return store.ListCollections(ctx)
+}
+
+// DescribeCollection returns information about collection; name can also be an alias
+func DescribeCollection(ctx context.Context, name string) (CollectionInfo, error) {
+	return store.DescribeCollection(ctx, name)
+}
+
+// DeleteCollection removes the collection with all its points
+func DeleteCollection(ctx context.Context, name string) error {
+	slog.Debug("delete collection", slog.String("name", name))
+	err := store.DeleteCollection(ctx, name)
+	forgetVerifiedModels()
+	if err != nil {
+		return err
+	}
+	return dropKeywordIndex(name)
+}
+
+// ListAliases returns all aliases, mapped to the collections they point to
+func ListAliases(ctx context.Context) (map[string]string, error) {
+	return store.ListAliases(ctx)
+}
+
+// SwitchAlias atomically points the alias at the collection, creating the alias if needed
+func SwitchAlias(ctx context.Context, alias, collection string) error {
+	slog.Debug("switch alias", slog.String("alias", alias), slog.String("collection", collection))
+	err := store.SwitchAlias(ctx, alias, collection)
+	forgetVerifiedModels()
+	return err
+}
+
+// makeCollectionInfo describes the collection, reading the embedding model from its metadata
+func makeCollectionInfo(name, status string, pointsCount int, config CollectionConfig) CollectionInfo {
+	info := CollectionInfo{
+		Name:        name,
+		Status:      status,
+		PointsCount: pointsCount,
+		Metadata:    map[string]string{},
+	}
+	if len(config.NamedVectors) > 0 {
+		info.NamedVectors = config.NamedVectors
+	} else {
+		info.Vectors = config.Vectors
+	}
+	for k, v := range config.Metadata {
+		info.Metadata[k] = fmt.Sprint(v)
+	}
+	info.Model = info.Metadata[MetadataEmbeddingModel]
+	return info
+}
+
+// ReindexReport summarizes the result of Reindex
+type ReindexReport struct {
+	FeedReport
+	Collection string   // the new collection the alias points to
+	Deleted    []string // the collections the alias pointed to before
+}
+
+// Reindex does blue/green reindexing: feeds the documents into a new, versioned collection (alias_v1, alias_v2, ...) and then
+// atomically switches the alias to it, so that the readers never see half-filled collection.
+// The previous collection is deleted unless keepOld is set; the alias is left untouched and the new collection deleted if any batch fails
+func Reindex(ctx context.Context, alias string, docs []Document, opts FeedOptions, keepOld bool) (ReindexReport, error) {
+	aliases, err := ListAliases(ctx)
+	if err != nil {
+		return ReindexReport{}, err
+	}
+	previous, hadAlias := aliases[alias]
+
+	collection, err := nextVersion(ctx, alias)
+	if err != nil {
+		return ReindexReport{}, err
+	}
+
+	report := ReindexReport{Collection: collection}
+	opts.Append = false
+	report.FeedReport, err = feedDocuments(ctx, report.Collection, docs, opts)
+	if err == nil && len(report.Failed) > 0 {
+		err = fmt.Errorf("%d batches failed, alias %q still points to %q", len(report.Failed), alias, previous)
+	}
+	if err == nil {
+		err = SwitchAlias(ctx, alias, report.Collection)
+	}
+	if err != nil && !errors.Is(err, ErrCollectionExists) {
+		// the half-filled collection is not left behind, otherwise every retry would leave another one;
+		// the one that already existed is not ours to delete
+		if dropErr := DeleteCollection(context.WithoutCancel(ctx), report.Collection); dropErr != nil && !errors.Is(dropErr, ErrCollectionNotFound) {
+			slog.Warn("failed to delete the new collection", "collection", report.Collection, "error", dropErr)
+		}
+	}
+	if err != nil {
+		return report, err
+	}
+
+	if hadAlias && !keepOld {
+		if err := DeleteCollection(ctx, previous); err != nil {
+			return report, err
+		}
+		report.Deleted = append(report.Deleted, previous)
+	}
+	return report, nil
+}
+
+// nextVersion returns the name of the next collection version for the alias, eg. "knowledge_v3" if "knowledge_v2" exists
+func nextVersion(ctx context.Context, alias string) (string, error) {
+	names, err := ListCollections(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	latest := 0
+	prefix := alias + "_v"
+	for _, name := range names {
+		if version, err := strconv.Atoi(strings.TrimPrefix(name, prefix)); err == nil && strings.HasPrefix(name, prefix) {
+			latest = max(latest, version)
+		}
+	}
+	return fmt.Sprintf("%s%d", prefix, latest+1), nil
+}
+
+// addCollection creates new collection of entries in vector database, recording the embedding model in its metadata;
+// the config metadata is kept, eg. restored from snapshot, but the model and dimensions always come from the arguments
+func addCollection(ctx context.Context, name string, config CollectionConfig, model string) error {
+	// Prepare database config
+	dimensions := config.Vectors.Size
+	if config.Vectors.Distance == "" {
+		config.Vectors.Distance = DefaultDistance
+	}
+	if len(config.NamedVectors) > 0 {
+		named := map[string]VectorConfig{}
+		for vectorName, vectors := range config.NamedVectors {
+			if vectors.Distance == "" {
+				vectors.Distance = DefaultDistance
+			}
+			named[vectorName] = vectors
+		}
+		config.NamedVectors = named
+		dimensions = named[VectorText].Size
+		if dimensions == 0 {
+			dimensions = named[VectorTitle].Size
+		}
+	}
+	for vectorName, vectors := range config.vectorConfigs() {
+		if err := checkHNSW(vectors.HNSW); err != nil {
+			return fmt.Errorf("%w%s", err, vectorLabel(vectorName))
+		}
+	}
+	metadata := map[string]interface{}{}
+	for k, v := range config.Metadata {
+		metadata[k] = v
+	}
+	metadata[MetadataEmbeddingModel] = model
+	metadata[MetadataEmbeddingDimensions] = dimensions
+	config.Metadata = metadata
+
+	slog.Debug("add collection", slog.String("name", name), slog.Int("dimensions", dimensions), slog.Int("named_vectors", len(config.NamedVectors)), slog.String("model", model))
+	return store.CreateCollection(ctx, name, config)
+}
+
+// prepareCollection creates the collection; existing collection is reused if reuse is set and it stores
+// the same vectors of the same size, produced by the same model
+func prepareCollection(ctx context.Context, name string, config CollectionConfig, model string, reuse bool) error {
+	err := addCollection(ctx, name, config, model)
+	if err == nil || !reuse || !errors.Is(err, ErrCollectionExists) {
+		return err
+	}
+	return verifyCollection(ctx, name, config, model)
+}
+
+// verifyCollection checks that collection is compatible with the vectors of the config, embedded by given model
+func verifyCollection(ctx context.Context, name string, config CollectionConfig, model string) error {
+	info, err := DescribeCollection(ctx, name)
+	if err != nil {
+		return err
+	}
+	if len(config.NamedVectors) == 0 && len(info.NamedVectors) > 0 {
+		return fmt.Errorf("%w: collection %q stores named vectors %v, expected single vector", ErrDimensionMismatch, name, sortedKeys(info.NamedVectors))
+	}
+	existing := CollectionConfig{Vectors: info.Vectors, NamedVectors: info.NamedVectors}.vectorConfigs()
+	for vectorName, vectors := range config.vectorConfigs() {
+		stored, ok := existing[vectorName]
+		if !ok {
+			return fmt.Errorf("%w: collection %q has no vector named %q", ErrDimensionMismatch, name, vectorName)
+		}
+		if stored.Size != vectors.Size {
+			return fmt.Errorf("%w: collection %q stores %d dimensions%s, embedder produces %d", ErrDimensionMismatch, name, stored.Size, vectorLabel(vectorName), vectors.Size)
+		}
+	}
+	if info.Model != "" && info.Model != model {
+		return fmt.Errorf("%w: collection %q stores embeddings of %q, embedder uses %q", ErrModelMismatch, name, info.Model, model)
+	}
+	return nil
+}
+
+// verifiedModels caches the collections already checked against the embedding model, so that AskDB doesn't query collection info every time
+var (
+	verifiedModels   = map[string]verifiedCollection{} // collection -> model
+	verifiedModelsMu sync.Mutex
+)
+
+// verifiedCollection is the collection checked against the embedding model
+type verifiedCollection struct {
+	model        string
+	namedVectors []string          // sorted names of the vectors, nil if the collection has single vector
+	distances    map[string]string // vector name -> distance func; the single vector is named ""
+}
+
+// checkModel makes sure the collection was not filled by a different embedding model than the one in use;
+// returns the vectors of the collection
+func checkModel(ctx context.Context, collection string) (verifiedCollection, error) {
+	model, err := embedderModel()
+	if err != nil {
+		return verifiedCollection{}, err
+	}
+	verifiedModelsMu.Lock()
+	verified, ok := verifiedModels[collection]
+	verifiedModelsMu.Unlock()
+	if ok && verified.model == model {
+		return verified, nil
+	}
+
+	info, err := DescribeCollection(ctx, collection)
+	if err != nil {
+		return verifiedCollection{}, err
+	}
+	if info.Model != "" && info.Model != model {
+		return verifiedCollection{}, fmt.Errorf("%w: collection %q stores embeddings of %q, embedder uses %q", ErrModelMismatch, collection, info.Model, model)
+	}
+
+	verified = verifiedCollection{model: model, namedVectors: sortedKeys(info.NamedVectors), distances: map[string]string{}}
+	for name, vectors := range (CollectionConfig{Vectors: info.Vectors, NamedVectors: info.NamedVectors}).vectorConfigs() {
+		verified.distances[name] = vectors.Distance
+	}
+	verifiedModelsMu.Lock()
+	verifiedModels[collection] = verified
+	verifiedModelsMu.Unlock()
+	return verified, nil
+}
+
+// forgetVerifiedModels clears the cache, eg. after alias switch
+func forgetVerifiedModels() {
+	verifiedModelsMu.Lock()
+	verifiedModels = map[string]verifiedCollection{}
+	verifiedModelsMu.Unlock()
+}
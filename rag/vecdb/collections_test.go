@@ -0,0 +1,86 @@
+package vecdb_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+func TestReindex(t *testing.T) {
+	offline(t)
+	ctx := context.Background()
+	docs := []vecdb.Document{{Text: knowledge[0]}, {Text: knowledge[1]}}
+
+	tests := []struct {
+		name        string
+		keepOld     bool
+		collection  string
+		deleted     []string
+		collections []string
+	}{
+		{name: "first version", collection: "docs_v1", collections: []string{"docs_v1"}},
+		{name: "previous deleted", collection: "docs_v2", deleted: []string{"docs_v1"}, collections: []string{"docs_v2"}},
+		{name: "previous kept", keepOld: true, collection: "docs_v3", collections: []string{"docs_v2", "docs_v3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := vecdb.Reindex(ctx, "docs", docs, vecdb.DefaultFeedOptions(), tt.keepOld)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Collection != tt.collection || !reflect.DeepEqual(report.Deleted, tt.deleted) {
+				t.Fatalf("reindexed into %q deleting %v, expected %q deleting %v", report.Collection, report.Deleted, tt.collection, tt.deleted)
+			}
+			checkCollections(t, tt.collections)
+			aliases, err := vecdb.ListAliases(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if aliases["docs"] != tt.collection {
+				t.Fatalf("alias points to %q, expected %q", aliases["docs"], tt.collection)
+			}
+			results, err := vecdb.AskDBQuery(ctx, vecdb.Query{Text: "goroutines", Collection: "docs", Limit: 1})
+			if err != nil || len(results) != 1 {
+				t.Fatalf("search through the alias: %v, %v", results, err)
+			}
+		})
+	}
+}
+
+func TestReindexFailedLeavesNoCollection(t *testing.T) {
+	offline(t)
+	ctx := context.Background()
+	docs := []vecdb.Document{{Text: knowledge[0]}, {Text: knowledge[1]}}
+	if _, err := vecdb.Reindex(ctx, "docs", docs, vecdb.DefaultFeedOptions(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	vecdb.SetEmbedder(&recordingEmbedder{FakeEmbedder: vecdb.NewFakeEmbedder(0), failing: "Rust"})
+	for retry := 0; retry < 2; retry++ {
+		if _, err := vecdb.Reindex(ctx, "docs", docs, vecdb.FeedOptions{BatchSize: 1}, false); err == nil {
+			t.Fatal("expected reindex with failed batch to fail")
+		}
+		checkCollections(t, []string{"docs_v1"})
+	}
+	aliases, err := vecdb.ListAliases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aliases["docs"] != "docs_v1" {
+		t.Fatalf("alias points to %q, expected the previous collection", aliases["docs"])
+	}
+}
+
+// checkCollections verifies the collections in the store
+func checkCollections(t *testing.T, expected []string) {
+	t.Helper()
+	collections, err := vecdb.ListCollections(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(collections, expected) {
+		t.Fatalf("collections %v, expected %v", collections, expected)
+	}
+}
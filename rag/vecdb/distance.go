@@ -0,0 +1,89 @@
+package vecdb
+
+import (
+	"fmt"
+	"math"
+)
+
+// Distance funcs of the collections, named as in qdrant
+const (
+	DistanceCosine    = "Cosine"
+	DistanceDot       = "Dot"
+	DistanceEuclid    = "Euclid"
+	DistanceManhattan = "Manhattan"
+)
+
+// metric compares the vectors the way qdrant does for given distance func
+type metric struct {
+	prepare  func(v []float32) []float32  // applied to the stored and the query vectors, eg. normalization for cosine
+	distance func(a, b []float32) float64 // lower means closer
+	score    func(distance float64) float64
+}
+
+// metricOf returns the metric of the distance func; the score is similarity for Cosine and Dot, and the distance itself
+// for Euclid and Manhattan, just like in qdrant
+func metricOf(distance string) (metric, error) {
+	same := func(v []float32) []float32 { return v }
+	switch distance {
+	case DistanceCosine:
+		// vectors are normalized up front so that cosine similarity is just dot product
+		return metric{
+			prepare:  normalize,
+			distance: func(a, b []float32) float64 { return 1 - dot(a, b) },
+			score:    func(d float64) float64 { return 1 - d },
+		}, nil
+	case DistanceDot:
+		return metric{
+			prepare:  same,
+			distance: func(a, b []float32) float64 { return -dot(a, b) },
+			score:    func(d float64) float64 { return -d },
+		}, nil
+	case DistanceEuclid:
+		return metric{prepare: same, distance: euclid, score: func(d float64) float64 { return d }}, nil
+	case DistanceManhattan:
+		return metric{prepare: same, distance: manhattan, score: func(d float64) float64 { return d }}, nil
+	default:
+		return metric{}, fmt.Errorf("unknown distance %q, expected one of: %s, %s, %s, %s", distance, DistanceCosine, DistanceDot, DistanceEuclid, DistanceManhattan)
+	}
+}
+
+// dot returns the dot product of the vectors of the same size; the sums are float64 to not lose precision
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// euclid returns the euclidean distance between the vectors of the same size
+func euclid(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		d := float64(a[i]) - float64(b[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
+
+// manhattan returns the sum of absolute differences between the vectors of the same size
+func manhattan(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += math.Abs(float64(a[i]) - float64(b[i]))
+	}
+	return sum
+}
+
+// normalize returns copy of the vector scaled to length 1; zero vector is returned as is
+func normalize(v []float32) []float32 {
+	length := math.Sqrt(dot(v, v))
+	if length == 0 {
+		return v
+	}
+	normalized := make([]float32, len(v))
+	for i := range v {
+		normalized[i] = float32(float64(v[i]) / length)
+	}
+	return normalized
+}
@@ -0,0 +1,647 @@
+package vecdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Embedded store indexes
+const (
+	IndexHNSW = "hnsw" // approximate search over HNSW graph, built in memory on first search
+	IndexFlat = "flat" // exact, brute-force search comparing the query with every point
+)
+
+const (
+	collectionFileExt  = ".jsonl"
+	aliasesFile        = "aliases.json"
+	defaultSearchLimit = 10 // as in qdrant
+)
+
+// EmbeddedStore is in-process VectorStore that persists the collections in files, so that small corpora and tests don't need qdrant.
+// Every change is appended as JSON line to the file of its collection, <dir>/<collection>.jsonl; the file is compacted when loaded
+// if the history got much bigger than the points. The aliases are kept in <dir>/aliases.json.
+// The files are read on first use, and must not be modified by other processes meanwhile.
+// The vectors are always kept in memory as float32: the datatype, quantization and on-disk settings of VectorStorage are only
+// recorded, while the HNSW params of the collection override the ones of the store
+type EmbeddedStore struct {
+	Dir         string
+	Index       string // IndexHNSW or IndexFlat
+	M           int    // HNSW links per node; more means better recall but bigger graph
+	EfConstruct int    // HNSW candidates considered when linking new node; more means better graph but slower upserts
+	Ef          int    // HNSW candidates considered when searching, raised to the search limit; more means better recall but slower search
+
+	mu          sync.Mutex // search can build the graph, so a plain mutex
+	collections map[string]*embeddedCollection
+	aliases     map[string]string // alias -> collection
+}
+
+// embeddedCollection keeps the points of a collection in memory
+type embeddedCollection struct {
+	config  CollectionConfig
+	metrics map[string]metric // vector name -> metric; the single vector is named ""
+	points  map[string]Point
+	graphs  map[string]*hnswIndex // vector name -> HNSW graph, built on the first HNSW search of the vector
+}
+
+// logRecord is a line of collection file, exactly one of the fields is set
+type logRecord struct {
+	Create *CollectionConfig `json:"create,omitempty"`
+	Upsert []Point           `json:"upsert,omitempty"`
+	Delete []string          `json:"delete,omitempty"`
+}
+
+// neighbour is a point found for a vector
+type neighbour struct {
+	id       string
+	distance float64
+}
+
+// NewEmbeddedStore creates EmbeddedStore with HNSW index and default HNSW params; empty dir means ".vecdb"
+func NewEmbeddedStore(dir string) *EmbeddedStore {
+	if dir == "" {
+		dir = defaultEmbeddedDir
+	}
+	return &EmbeddedStore{Dir: dir, Index: IndexHNSW, M: DefaultHNSWM, EfConstruct: DefaultHNSWEfConstruct, Ef: DefaultHNSWEf}
+}
+
+// CreateCollection implements VectorStore
+func (s *EmbeddedStore) CreateCollection(ctx context.Context, name string, config CollectionConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	if err := CheckCollectionName(name); err != nil {
+		return err
+	}
+	if _, exists := s.collections[name]; exists {
+		return fmt.Errorf("%w: %q", ErrCollectionExists, name)
+	}
+	c, err := newEmbeddedCollection(config)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(logRecord{Create: &config})
+	if err != nil {
+		return err
+	}
+	if err := replaceFile(s.collectionPath(name), append(data, '\n')); err != nil {
+		return err
+	}
+	s.collections[name] = c
+	return nil
+}
+
+// DescribeCollection implements VectorStore
+func (s *EmbeddedStore) DescribeCollection(ctx context.Context, name string) (CollectionInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, _, err := s.collection(name)
+	if err != nil {
+		return CollectionInfo{}, err
+	}
+	return makeCollectionInfo(name, "green", len(c.points), c.config), nil
+}
+
+// ListCollections implements VectorStore
+func (s *EmbeddedStore) ListCollections(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name := range s.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DeleteCollection implements VectorStore; the aliases pointing to the collection are deleted as well
+func (s *EmbeddedStore) DeleteCollection(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	if _, exists := s.collections[name]; !exists {
+		return fmt.Errorf("%w: %q", ErrCollectionNotFound, name)
+	}
+	if err := os.Remove(s.collectionPath(name)); err != nil {
+		return err
+	}
+	delete(s.collections, name)
+
+	aliasesChanged := false
+	for alias, collection := range s.aliases {
+		if collection == name {
+			delete(s.aliases, alias)
+			aliasesChanged = true
+		}
+	}
+	if !aliasesChanged {
+		return nil
+	}
+	return s.saveAliases()
+}
+
+// ListAliases implements VectorStore
+func (s *EmbeddedStore) ListAliases(ctx context.Context) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	aliases := map[string]string{}
+	for alias, collection := range s.aliases {
+		aliases[alias] = collection
+	}
+	return aliases, nil
+}
+
+// SwitchAlias implements VectorStore
+func (s *EmbeddedStore) SwitchAlias(ctx context.Context, alias, collection string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	if _, exists := s.collections[collection]; !exists {
+		return fmt.Errorf("%w: %q", ErrCollectionNotFound, collection)
+	}
+	if _, exists := s.collections[alias]; exists {
+		return fmt.Errorf("%w: can't use collection name %q as alias", ErrCollectionExists, alias)
+	}
+
+	previous, existed := s.aliases[alias]
+	s.aliases[alias] = collection
+	if err := s.saveAliases(); err != nil {
+		if existed {
+			s.aliases[alias] = previous
+		} else {
+			delete(s.aliases, alias)
+		}
+		return err
+	}
+	return nil
+}
+
+// Upsert implements VectorStore
+func (s *EmbeddedStore) Upsert(ctx context.Context, collection string, points []Point) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, name, err := s.collection(collection)
+	if err != nil {
+		return err
+	}
+
+	prepared := make([]Point, len(points))
+	for i, p := range points {
+		if p, err = c.prepare(name, p); err != nil {
+			return err
+		}
+		prepared[i] = p
+	}
+	return s.write(name, c, logRecord{Upsert: prepared})
+}
+
+// Delete implements VectorStore
+func (s *EmbeddedStore) Delete(ctx context.Context, collection string, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, name, err := s.collection(collection)
+	if err != nil {
+		return err
+	}
+	return s.write(name, c, logRecord{Delete: ids})
+}
+
+// Search implements VectorStore; the points always come with payload. HNSW search falls back to brute force if the graph
+// neighbourhood of the vector gives less than limit points, eg. because the filter rejected most of them.
+// Of the search params, HNSW ef and exact search are supported; the quantization params are ignored
+func (s *EmbeddedStore) Search(ctx context.Context, collection string, query SearchQuery) ([]SearchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, name, err := s.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	config, ok := c.config.vectorConfigs()[query.Using]
+	if !ok {
+		return nil, fmt.Errorf("%w: collection %q has no vector named %q", ErrUnknownVector, name, query.Using)
+	}
+	if len(query.Vector) != config.Size {
+		return nil, fmt.Errorf("%w: collection %q stores %d dimensions%s, query has %d", ErrDimensionMismatch, name, config.Size, vectorLabel(query.Using), len(query.Vector))
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	m := c.metrics[query.Using]
+	vector := m.prepare(query.Vector)
+	accept := func(id string) bool { return query.Filter.Matches(c.points[id].Payload) }
+
+	ef, exact := s.Ef, s.Index == IndexFlat
+	if query.Params != nil {
+		if query.Params.HNSWEf < 0 {
+			return nil, fmt.Errorf("invalid HNSW ef %d, expected at least 1", query.Params.HNSWEf)
+		}
+		if query.Params.HNSWEf > 0 {
+			ef = query.Params.HNSWEf
+		}
+		exact = exact || query.Params.Exact
+	}
+	if !exact && (s.M < 2 || s.EfConstruct < 1 || ef < 1) {
+		return nil, fmt.Errorf("invalid HNSW params of the store: m %d, ef_construct %d, ef %d; expected m at least 2 and the others at least 1", s.M, s.EfConstruct, ef)
+	}
+
+	var found []neighbour
+	if exact {
+		found = c.bruteForce(query.Using, vector, limit, accept)
+	} else {
+		graph := c.hnsw(query.Using, s.M, s.EfConstruct)
+		found = graph.search(vector, limit, ef, accept)
+		if len(found) < limit && len(found) < graph.len() {
+			found = c.bruteForce(query.Using, vector, limit, accept)
+		}
+	}
+
+	result := make([]SearchResult, 0, len(found))
+	for _, n := range found {
+		payload := map[string]interface{}{}
+		for k, v := range c.points[n.id].Payload {
+			payload[k] = v
+		}
+		text, _ := payload["text"].(string)
+		result = append(result, SearchResult{ID: n.id, Score: m.score(n.distance), Text: text, Payload: payload})
+	}
+	return result, nil
+}
+
+// Scroll implements VectorStore; the points come in the order of their ids, with the cosine vectors normalized
+func (s *EmbeddedStore) Scroll(ctx context.Context, collection, offset string, limit int) ([]Point, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, _, err := s.collection(collection)
+	if err != nil {
+		return nil, "", err
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	ids := c.sortedIDs()
+	start := sort.SearchStrings(ids, offset)
+	end := min(start+limit, len(ids))
+	points := make([]Point, 0, end-start)
+	for _, id := range ids[start:end] {
+		points = append(points, c.points[id])
+	}
+	next := ""
+	if end < len(ids) {
+		next = ids[end]
+	}
+	return points, next, nil
+}
+
+// CreatePayloadIndex implements VectorStore; it only checks the collection exists, as filtering goes over the points in memory
+func (s *EmbeddedStore) CreatePayloadIndex(ctx context.Context, collection, field, schema string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _, err := s.collection(collection)
+	return err
+}
+
+// Ping implements VectorStore; it checks the collections can be read
+func (s *EmbeddedStore) Ping(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+// collection returns the collection and its name, resolving the alias
+func (s *EmbeddedStore) collection(name string) (*embeddedCollection, string, error) {
+	if err := s.load(); err != nil {
+		return nil, "", err
+	}
+
+	if target, ok := s.aliases[name]; ok {
+		name = target
+	}
+	c, ok := s.collections[name]
+	if !ok {
+		return nil, "", fmt.Errorf("%w: %q", ErrCollectionNotFound, name)
+	}
+	return c, name, nil
+}
+
+// write appends the record to the collection file, and then applies it to the collection in memory
+func (s *EmbeddedStore) write(name string, c *embeddedCollection, r logRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.collectionPath(name), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	c.apply(r)
+	return nil
+}
+
+// load reads the collections and aliases from the files, unless already done
+func (s *EmbeddedStore) load() error {
+	if s.collections != nil {
+		return nil
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+
+	aliases := map[string]string{}
+	data, err := os.ReadFile(filepath.Join(s.Dir, aliasesFile))
+	if err == nil {
+		err = json.Unmarshal(data, &aliases)
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read aliases: %w", err)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(s.Dir, "*"+collectionFileExt))
+	if err != nil {
+		return err
+	}
+	collections := map[string]*embeddedCollection{}
+	for _, path := range paths {
+		c, err := loadCollection(path)
+		if err != nil {
+			return err
+		}
+		collections[strings.TrimSuffix(filepath.Base(path), collectionFileExt)] = c
+	}
+
+	slog.Debug("embedded store loaded", slog.String("dir", s.Dir), slog.Int("collections", len(collections)))
+	s.collections, s.aliases = collections, aliases
+	return nil
+}
+
+// saveAliases writes the aliases to file
+func (s *EmbeddedStore) saveAliases() error {
+	data, err := json.MarshalIndent(s.aliases, "", "  ")
+	if err != nil {
+		return err
+	}
+	return replaceFile(filepath.Join(s.Dir, aliasesFile), data)
+}
+
+// collectionPath returns the path of the collection file
+func (s *EmbeddedStore) collectionPath(name string) string {
+	return filepath.Join(s.Dir, name+collectionFileExt)
+}
+
+// newEmbeddedCollection creates empty collection, checking the config
+func newEmbeddedCollection(config CollectionConfig) (*embeddedCollection, error) {
+	c := &embeddedCollection{config: config, metrics: map[string]metric{}, points: map[string]Point{}, graphs: map[string]*hnswIndex{}}
+	for name, vectors := range config.vectorConfigs() {
+		if vectors.Size <= 0 {
+			return nil, fmt.Errorf("invalid vector size %d%s", vectors.Size, vectorLabel(name))
+		}
+		m, err := metricOf(vectors.Distance)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkDatatype(vectors.Datatype); err != nil {
+			return nil, err
+		}
+		if err := checkHNSW(vectors.HNSW); err != nil {
+			return nil, err
+		}
+		c.metrics[name] = m
+	}
+	return c, nil
+}
+
+// loadCollection replays the collection file; the file is compacted if it has much more records than points,
+// or if its last record was cut short, eg. by a crash during write
+func loadCollection(path string) (*embeddedCollection, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var c *embeddedCollection
+	written := 0
+	truncated := false
+	decoder := json.NewDecoder(f)
+	for {
+		var r logRecord
+		err := decoder.Decode(&r)
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			slog.Warn("ignoring truncated last record", slog.String("file", path))
+			truncated = true
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		if r.Create != nil {
+			if c, err = newEmbeddedCollection(*r.Create); err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			continue
+		}
+		if c == nil {
+			return nil, fmt.Errorf("failed to read %s: collection config missing", path)
+		}
+		c.apply(r)
+		written += len(r.Upsert) + len(r.Delete)
+	}
+	if c == nil {
+		return nil, fmt.Errorf("failed to read %s: collection config missing", path)
+	}
+
+	if truncated || written > 2*len(c.points) {
+		slog.Debug("compact collection file", slog.String("file", path), slog.Int("records", written), slog.Int("points", len(c.points)))
+		if err := c.save(path); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// save writes the collection to file as config followed by all the points
+func (c *embeddedCollection) save(path string) error {
+	create, err := json.Marshal(logRecord{Create: &c.config})
+	if err != nil {
+		return err
+	}
+	data := append(create, '\n')
+
+	if len(c.points) > 0 {
+		points := make([]Point, 0, len(c.points))
+		for _, id := range c.sortedIDs() {
+			points = append(points, c.points[id])
+		}
+		upsert, err := json.Marshal(logRecord{Upsert: points})
+		if err != nil {
+			return err
+		}
+		data = append(append(data, upsert...), '\n')
+	}
+	return replaceFile(path, data)
+}
+
+// prepare checks the point vectors against the collection config and prepares them for search, eg. normalizes for cosine
+func (c *embeddedCollection) prepare(collection string, p Point) (Point, error) {
+	if len(c.config.NamedVectors) == 0 {
+		if len(p.Vectors) > 0 {
+			return p, fmt.Errorf("collection %q has single vector, point %q has named vectors", collection, p.ID)
+		}
+		if len(p.Vector) != c.config.Vectors.Size {
+			return p, fmt.Errorf("%w: collection %q stores %d dimensions, point %q has %d", ErrDimensionMismatch, collection, c.config.Vectors.Size, p.ID, len(p.Vector))
+		}
+		p.Vector = c.metrics[""].prepare(p.Vector)
+		return p, nil
+	}
+
+	if len(p.Vector) > 0 {
+		return p, fmt.Errorf("collection %q has named vectors, point %q has single vector", collection, p.ID)
+	}
+	vectors := make(map[string][]float32, len(p.Vectors))
+	for name, vector := range p.Vectors {
+		config, ok := c.config.NamedVectors[name]
+		if !ok {
+			return p, fmt.Errorf("collection %q has no vector named %q, point %q has it", collection, name, p.ID)
+		}
+		if len(vector) != config.Size {
+			return p, fmt.Errorf("%w: collection %q stores %d dimensions%s, point %q has %d", ErrDimensionMismatch, collection, config.Size, vectorLabel(name), p.ID, len(vector))
+		}
+		vectors[name] = c.metrics[name].prepare(vector)
+	}
+	p.Vectors = vectors
+	return p, nil
+}
+
+// apply performs the record changes on the points, and on the HNSW graphs already built
+func (c *embeddedCollection) apply(r logRecord) {
+	for _, p := range r.Upsert {
+		c.points[p.ID] = p
+		for name, graph := range c.graphs {
+			if vector := p.vector(name); vector != nil {
+				graph.add(p.ID, vector)
+			} else {
+				graph.remove(p.ID)
+			}
+		}
+	}
+	for _, id := range r.Delete {
+		delete(c.points, id)
+		for _, graph := range c.graphs {
+			graph.remove(id)
+		}
+	}
+}
+
+// hnsw returns the HNSW graph of the named vector of the points, building it if missing or if most of its nodes were removed;
+// m and efConstruct are the store defaults, used unless the collection config sets them
+func (c *embeddedCollection) hnsw(name string, m, efConstruct int) *hnswIndex {
+	if h := c.config.vectorConfigs()[name].HNSW; h != nil && h.M > 0 {
+		m = h.M
+	}
+	if h := c.config.vectorConfigs()[name].HNSW; h != nil && h.EfConstruct > 0 {
+		efConstruct = h.EfConstruct
+	}
+	graph := c.graphs[name]
+	if graph == nil || graph.stale() {
+		graph = newHNSWIndex(m, efConstruct, c.metrics[name].distance)
+		for _, id := range c.sortedIDs() {
+			if vector := c.points[id].vector(name); vector != nil {
+				graph.add(id, vector)
+			}
+		}
+		c.graphs[name] = graph
+	}
+	return graph
+}
+
+// bruteForce compares the vector with the named vector of every accepted point and returns up to limit nearest ones, best first
+func (c *embeddedCollection) bruteForce(name string, vector []float32, limit int, accept func(id string) bool) []neighbour {
+	distance := c.metrics[name].distance
+	var found []neighbour
+	for id, p := range c.points {
+		if pointVector := p.vector(name); pointVector != nil && accept(id) {
+			found = append(found, neighbour{id, distance(vector, pointVector)})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].distance == found[j].distance {
+			return found[i].id < found[j].id
+		}
+		return found[i].distance < found[j].distance
+	})
+	return found[:min(len(found), limit)]
+}
+
+// sortedIDs returns the point ids in alphabetical order
+func (c *embeddedCollection) sortedIDs() []string {
+	ids := make([]string, 0, len(c.points))
+	for id := range c.points {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// replaceFile writes to unique temporary file in the same dir first, so that interrupted or concurrent write doesn't leave broken file
+func replaceFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,186 @@
+package vecdb
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestEmbeddedStoreReopen(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	store := NewEmbeddedStore(dir)
+
+	config := CollectionConfig{Vectors: VectorConfig{Size: 2, Distance: DistanceEuclid}, Metadata: map[string]interface{}{MetadataEmbeddingModel: "fake"}}
+	if err := store.CreateCollection(ctx, "docs", config); err != nil {
+		t.Fatal(err)
+	}
+	points := []Point{
+		{ID: "a", Vector: []float32{0, 0}, Payload: map[string]interface{}{"text": "a"}},
+		{ID: "b", Vector: []float32{1, 0}, Payload: map[string]interface{}{"text": "b"}},
+		{ID: "c", Vector: []float32{0, 1}, Payload: map[string]interface{}{"text": "c"}},
+	}
+	if err := store.Upsert(ctx, "docs", points); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, "docs", []string{"b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Upsert(ctx, "docs", []Point{{ID: "c", Vector: []float32{2, 2}, Payload: map[string]interface{}{"text": "c2"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SwitchAlias(ctx, "latest", "docs"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := NewEmbeddedStore(dir)
+	names, err := reopened.ListCollections(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"docs"}) {
+		t.Fatalf("collections %v, expected [docs]", names)
+	}
+	aliases, err := reopened.ListAliases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(aliases, map[string]string{"latest": "docs"}) {
+		t.Fatalf("aliases %v", aliases)
+	}
+	info, err := reopened.DescribeCollection(ctx, "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "latest" || info.PointsCount != 2 || info.Vectors.Size != 2 || info.Model != "fake" {
+		t.Fatalf("collection %+v", info)
+	}
+
+	stored, _, err := reopened.Scroll(ctx, "docs", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Point{
+		{ID: "a", Vector: []float32{0, 0}, Payload: map[string]interface{}{"text": "a"}},
+		{ID: "c", Vector: []float32{2, 2}, Payload: map[string]interface{}{"text": "c2"}},
+	}
+	if !reflect.DeepEqual(stored, expected) {
+		t.Fatalf("points %+v, expected %+v", stored, expected)
+	}
+
+	results, err := reopened.Search(ctx, "latest", SearchQuery{Vector: []float32{2, 1}, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].ID != "c" || results[0].Text != "c2" {
+		t.Fatalf("found %+v, expected c", results)
+	}
+
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(leftovers) != 0 {
+		t.Fatalf("temporary files left: %v", leftovers)
+	}
+}
+
+func TestEmbeddedStoreCompaction(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	store := NewEmbeddedStore(dir)
+	if err := store.CreateCollection(ctx, "docs", CollectionConfig{Vectors: VectorConfig{Size: 1, Distance: DistanceEuclid}}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := store.Upsert(ctx, "docs", []Point{{ID: "a", Vector: []float32{float32(i)}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the last record cut short, as if the process crashed during write
+	path := filepath.Join(dir, "docs"+collectionFileExt)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"upsert": [{"id": "b", "vec`)
+	f.Close()
+
+	reopened := NewEmbeddedStore(dir)
+	stored, _, err := reopened.Scroll(ctx, "docs", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || stored[0].ID != "a" || stored[0].Vector[0] != 4 {
+		t.Fatalf("points %+v, expected the last version of a", stored)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Fatalf("compacted file has %d lines, expected config and points:\n%s", lines, data)
+	}
+}
+
+func TestHNSWRecall(t *testing.T) {
+	const (
+		points     = 2000
+		dimensions = 16
+		queries    = 50
+		k          = 10
+	)
+	rng := rand.New(rand.NewSource(7))
+	randomVector := func() []float32 {
+		v := make([]float32, dimensions)
+		for i := range v {
+			v[i] = rng.Float32()
+		}
+		return v
+	}
+
+	for _, distance := range []string{DistanceCosine, DistanceEuclid} {
+		t.Run(distance, func(t *testing.T) {
+			m, err := metricOf(distance)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := &embeddedCollection{metrics: map[string]metric{"": m}, points: map[string]Point{}}
+			graph := newHNSWIndex(DefaultHNSWM, DefaultHNSWEfConstruct, m.distance)
+			for i := 0; i < points; i++ {
+				p := Point{ID: fmt.Sprint(i), Vector: m.prepare(randomVector())}
+				c.points[p.ID] = p
+				graph.add(p.ID, p.Vector)
+			}
+
+			acceptAll := func(string) bool { return true }
+			found, expected := 0, 0
+			for i := 0; i < queries; i++ {
+				query := m.prepare(randomVector())
+				exact := map[string]bool{}
+				for _, n := range c.bruteForce("", query, k, acceptAll) {
+					exact[n.id] = true
+				}
+				approximate := graph.search(query, k, DefaultHNSWEf, acceptAll)
+				if !sort.SliceIsSorted(approximate, func(i, j int) bool { return approximate[i].distance < approximate[j].distance }) {
+					t.Fatalf("results not sorted by distance: %v", approximate)
+				}
+				for _, n := range approximate {
+					if exact[n.id] {
+						found++
+					}
+				}
+				expected += len(exact)
+			}
+
+			recall := float64(found) / float64(expected)
+			if recall < 0.95 {
+				t.Fatalf("recall %.3f, expected at least 0.95", recall)
+			}
+		})
+	}
+}
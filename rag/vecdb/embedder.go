@@ -0,0 +1,373 @@
+package vecdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Embedder turns text into an embedding vector
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float64, error)
+	EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) // returns one embedding per text, in the same order
+	Model() string                                                       // name of the embedding model, recorded in collection metadata; empty if not known, eg. the server is down
+}
+
+// EmbedderConfig selects and configures the Embedder implementation
+type EmbedderConfig struct {
+	Provider   string // ["sidecar", "ollama", "openai", "fake"]
+	Model      string // model name, provider specific; empty means provider default
+	URL        string // server URL; empty means provider default
+	APIKey     string // required by openai
+	Dimensions int    // used by fake embedder only
+}
+
+const (
+	defaultSidecarURL     = "http://localhost:5000"
+	defaultOllamaURL      = "http://localhost:11434"
+	defaultOllamaModel    = "nomic-embed-text"
+	defaultOpenAIURL      = "https://api.openai.com/v1"
+	defaultOpenAIModel    = "text-embedding-3-small"
+	defaultFakeDimensions = 384
+	sidecarHealthTimeout  = 5 * time.Second
+)
+
+// EmbedderConfigFromEnv reads the embedder configuration from environment variables:
+// RAG_EMBEDDER, RAG_EMBEDDER_MODEL, RAG_EMBEDDER_URL and GPT_APIKEY
+func EmbedderConfigFromEnv() EmbedderConfig {
+	return EmbedderConfig{
+		Provider: os.Getenv("RAG_EMBEDDER"),
+		Model:    os.Getenv("RAG_EMBEDDER_MODEL"),
+		URL:      os.Getenv("RAG_EMBEDDER_URL"),
+		APIKey:   os.Getenv("GPT_APIKEY"),
+	}
+}
+
+// NewEmbedder creates Embedder according to provided config; empty provider means "sidecar"
+func NewEmbedder(cfg EmbedderConfig) (Embedder, error) {
+	switch cfg.Provider {
+	case "", "sidecar":
+		return NewSidecarEmbedder(cfg.URL), nil
+	case "ollama":
+		return NewOllamaEmbedder(cfg.URL, cfg.Model), nil
+	case "openai":
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("OpenAI API key is not set")
+		}
+		return NewOpenAIEmbedder(cfg.URL, cfg.Model, cfg.APIKey), nil
+	case "fake":
+		return NewFakeEmbedder(cfg.Dimensions), nil
+	default:
+		return nil, fmt.Errorf("unknown embedder provider %q", cfg.Provider)
+	}
+}
+
+// SidecarEmbedder talks to the long-lived local embedding server, see embedding-localhost/main.py
+type SidecarEmbedder struct {
+	URL string
+
+	mu    sync.Mutex
+	model string // reported by the sidecar with every response
+}
+
+// NewSidecarEmbedder creates embedder for the sidecar running at url; empty url means default
+func NewSidecarEmbedder(url string) *SidecarEmbedder {
+	if url == "" {
+		url = defaultSidecarURL
+	}
+	return &SidecarEmbedder{URL: url}
+}
+
+// Embed implements Embedder
+func (e *SidecarEmbedder) Embed(ctx context.Context, text string) ([]float64, error) {
+	return embedOne(ctx, e, text)
+}
+
+// EmbedBatch implements Embedder
+func (e *SidecarEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	query := struct {
+		Texts []string `json:"texts"`
+	}{Texts: texts}
+
+	rspString, err := request(ctx, e.URL+"/embed", "POST", query)
+	if err != nil {
+		return nil, err
+	}
+
+	var rsp struct {
+		Model      string      `json:"model"`
+		Embeddings [][]float64 `json:"embeddings"`
+	}
+	if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.model = rsp.Model
+	e.mu.Unlock()
+	if len(rsp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("sidecar returned %d embeddings, expected %d", len(rsp.Embeddings), len(texts))
+	}
+	return rsp.Embeddings, nil
+}
+
+// Model implements Embedder; the model is asked from the sidecar /health endpoint until reported with embeddings.
+// Unreachable sidecar gives empty model, and is asked again next time
+func (e *SidecarEmbedder) Model() string {
+	e.mu.Lock()
+	model := e.model
+	e.mu.Unlock()
+	if model == "" {
+		// not under the lock, so that the other callers don't wait for the timeout of unreachable sidecar
+		model = e.healthModel()
+		if model == "" {
+			return ""
+		}
+		e.mu.Lock()
+		if e.model == "" {
+			e.model = model
+		}
+		model = e.model
+		e.mu.Unlock()
+	}
+	return "sidecar/" + model
+}
+
+// healthModel returns the model reported by the sidecar /health endpoint, empty if the sidecar doesn't respond
+func (e *SidecarEmbedder) healthModel() string {
+	ctx, cancel := context.WithTimeout(context.Background(), sidecarHealthTimeout)
+	defer cancel()
+
+	rspString, err := request(ctx, e.URL+"/health", "GET", nil)
+	if err != nil {
+		return ""
+	}
+	var rsp struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
+		return ""
+	}
+	return rsp.Model
+}
+
+// OllamaEmbedder uses the Ollama /api/embeddings endpoint
+type OllamaEmbedder struct {
+	URL       string
+	ModelName string
+}
+
+// NewOllamaEmbedder creates embedder for ollama running at url; empty url or model means default
+func NewOllamaEmbedder(url, model string) *OllamaEmbedder {
+	if url == "" {
+		url = defaultOllamaURL
+	}
+	if model == "" {
+		model = defaultOllamaModel
+	}
+	return &OllamaEmbedder{URL: url, ModelName: model}
+}
+
+// Embed implements Embedder
+func (e *OllamaEmbedder) Embed(ctx context.Context, text string) ([]float64, error) {
+	query := struct {
+		Model  string `json:"model"`
+		Prompt string `json:"prompt"`
+	}{Model: e.ModelName, Prompt: text}
+
+	rspString, err := request(ctx, e.URL+"/api/embeddings", "POST", query)
+	if err != nil {
+		return nil, err
+	}
+
+	var rsp struct {
+		Embedding []float64 `json:"embedding"`
+	}
+	if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
+		return nil, err
+	}
+	if len(rsp.Embedding) == 0 {
+		return nil, fmt.Errorf("ollama returned empty embedding for model %q", e.ModelName)
+	}
+	return rsp.Embedding, nil
+}
+
+// EmbedBatch implements Embedder; /api/embeddings takes a single prompt so texts are embedded one by one
+func (e *OllamaEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	return embedEach(ctx, e, texts)
+}
+
+// Model implements Embedder
+func (e *OllamaEmbedder) Model() string {
+	return "ollama/" + e.ModelName
+}
+
+// OpenAIEmbedder uses the OpenAI embeddings API
+type OpenAIEmbedder struct {
+	URL       string
+	ModelName string
+	APIKey    string
+}
+
+// NewOpenAIEmbedder creates embedder for OpenAI API; empty url or model means default
+func NewOpenAIEmbedder(url, model, apiKey string) *OpenAIEmbedder {
+	if url == "" {
+		url = defaultOpenAIURL
+	}
+	if model == "" {
+		model = defaultOpenAIModel
+	}
+	return &OpenAIEmbedder{URL: url, ModelName: model, APIKey: apiKey}
+}
+
+// Embed implements Embedder
+func (e *OpenAIEmbedder) Embed(ctx context.Context, text string) ([]float64, error) {
+	return embedOne(ctx, e, text)
+}
+
+// EmbedBatch implements Embedder
+func (e *OpenAIEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	query := struct {
+		Model string   `json:"model"`
+		Input []string `json:"input"`
+	}{Model: e.ModelName, Input: texts}
+
+	jsonData, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+
+	url := e.URL + "/embeddings"
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+e.APIKey)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPError{URL: url, Method: "POST", StatusCode: resp.StatusCode, Status: resp.Status, Body: string(body)}
+	}
+
+	var rsp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &rsp); err != nil {
+		return nil, err
+	}
+	if len(rsp.Data) != len(texts) {
+		return nil, fmt.Errorf("openai returned %d embeddings, expected %d", len(rsp.Data), len(texts))
+	}
+	embeddings := make([][]float64, len(texts))
+	for _, d := range rsp.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("openai returned embedding with invalid index %d", d.Index)
+		}
+		embeddings[d.Index] = d.Embedding
+	}
+	return embeddings, nil
+}
+
+// Model implements Embedder
+func (e *OpenAIEmbedder) Model() string {
+	return "openai/" + e.ModelName
+}
+
+// FakeEmbedder is a deterministic, hash-based embedder for running the RAG flow offline.
+// Every word is hashed into one of the dimensions, so texts sharing words end up close to each other.
+type FakeEmbedder struct {
+	Dimensions int
+}
+
+// NewFakeEmbedder creates fake embedder; zero dimensions means default
+func NewFakeEmbedder(dimensions int) *FakeEmbedder {
+	if dimensions <= 0 {
+		dimensions = defaultFakeDimensions
+	}
+	return &FakeEmbedder{Dimensions: dimensions}
+}
+
+// Embed implements Embedder
+func (e *FakeEmbedder) Embed(ctx context.Context, text string) ([]float64, error) {
+	vector := make([]float64, e.Dimensions)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, word := range words {
+		sum := sha256.Sum256([]byte(word))
+		index := binary.BigEndian.Uint64(sum[:8]) % uint64(e.Dimensions)
+		sign := 1.0
+		if sum[8]&1 == 1 {
+			sign = -1.0
+		}
+		vector[index] += sign
+	}
+
+	// normalize so that cosine and dot distances behave the same
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range vector {
+			vector[i] /= norm
+		}
+	}
+	return vector, nil
+}
+
+// Model implements Embedder
+func (e *FakeEmbedder) Model() string {
+	return fmt.Sprintf("fake/hash-%d", e.Dimensions)
+}
+
+// EmbedBatch implements Embedder
+func (e *FakeEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	return embedEach(ctx, e, texts)
+}
+
+// embedOne embeds single text using batch embedding
+func embedOne(ctx context.Context, e Embedder, text string) ([]float64, error) {
+	embeddings, err := e.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// embedEach embeds texts one by one, for embedders that have no batch API
+func embedEach(ctx context.Context, e Embedder, texts []string) ([][]float64, error) {
+	embeddings := make([][]float64, 0, len(texts))
+	for _, text := range texts {
+		embedding, err := e.Embed(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, embedding)
+	}
+	return embeddings, nil
+}
@@ -0,0 +1,84 @@
+package vecdb_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+func TestSidecarModel(t *testing.T) {
+	tests := []struct {
+		name   string
+		health http.HandlerFunc
+		model  string
+	}{
+		{
+			name:   "reported by health",
+			health: func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, `{"status": "ok", "model": "m1"}`) },
+			model:  "sidecar/m1",
+		},
+		{
+			name:   "sidecar failing",
+			health: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) },
+			model:  "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.health)
+			defer server.Close()
+
+			if model := vecdb.NewSidecarEmbedder(server.URL).Model(); model != tt.model {
+				t.Fatalf("model %q, expected %q", model, tt.model)
+			}
+		})
+	}
+}
+
+func TestSidecarModelAfterRestart(t *testing.T) {
+	var up atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"model": "m1"}`)
+	}))
+	defer server.Close()
+
+	e := vecdb.NewSidecarEmbedder(server.URL)
+	if model := e.Model(); model != "" {
+		t.Fatalf("model %q of unreachable sidecar, expected none", model)
+	}
+	up.Store(true)
+	if model := e.Model(); model != "sidecar/m1" {
+		t.Fatalf("model %q after the sidecar came back, expected %q", model, "sidecar/m1")
+	}
+}
+
+func TestFeedWithUnknownModel(t *testing.T) {
+	offline(t)
+	vecdb.SetEmbedder(unknownModel{vecdb.NewFakeEmbedder(0)})
+
+	_, err := vecdb.FeedDBContext(context.Background(), knowledge, vecdb.DefaultFeedOptions())
+	if err == nil {
+		t.Fatal("expected error for unknown embedding model")
+	}
+	if _, err := vecdb.DescribeCollection(context.Background(), "knowledge"); err == nil {
+		t.Fatal("expected no collection created with unknown embedding model")
+	}
+}
+
+// unknownModel is embedder that can't tell its model, like unreachable sidecar
+type unknownModel struct {
+	*vecdb.FakeEmbedder
+}
+
+func (unknownModel) Model() string {
+	return ""
+}
@@ -1,5 +1,9 @@
-from sentence_transformers import SentenceTransformer
+from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
+from sentence_transformers import CrossEncoder, SentenceTransformer
+import json
+import math
 import sys
+import threading
 
 # Load a pre-trained model
 # - all-MiniLM-L6-v2: A smaller, faster model that is suitable for many tasks and works well with limited resources.
@@ -8,8 +12,80 @@ import sys
 model_name = 'paraphrase-MiniLM-L6-v2'
 model = SentenceTransformer(model_name)
 
-# Usage: python main.py "My text to embed"
+# Cross-encoder scores (question, passage) pairs, used for reranking; loaded on first use
+cross_encoder_name = 'cross-encoder/ms-marco-MiniLM-L-6-v2'
+cross_encoder = None
+cross_encoder_lock = threading.Lock()
+
+default_port = 5000
+
+
+def get_cross_encoder():
+    global cross_encoder
+    with cross_encoder_lock:
+        if cross_encoder is None:
+            cross_encoder = CrossEncoder(cross_encoder_name)
+        return cross_encoder
+
+
+# EmbeddingHandler serves embeddings over HTTP so that the model is loaded only once
+# - GET  /health -> {"status": "ok", "model": "..."}
+# - POST /embed  {"texts": ["text 1", "text 2"]} -> {"model": "...", "embeddings": [[...], [...]]}
+# - POST /rerank {"query": "question", "passages": ["text 1", "text 2"]} -> {"model": "...", "scores": [0.93, 0.01]}
+class EmbeddingHandler(BaseHTTPRequestHandler):
+    def do_GET(self):
+        if self.path != "/health":
+            self.send_error(404)
+            return
+        self.respond(200, {"status": "ok", "model": model_name})
+
+    def do_POST(self):
+        if self.path not in ("/embed", "/rerank"):
+            self.send_error(404)
+            return
+        try:
+            length = int(self.headers.get("Content-Length", 0))
+            request = json.loads(self.rfile.read(length))
+        except ValueError as e:
+            self.respond(400, {"error": str(e)})
+            return
+        try:
+            if self.path == "/embed":
+                self.embed(request)
+            else:
+                self.rerank(request)
+        except KeyError as e:
+            self.respond(400, {"error": f"missing field {e}"})
+
+    def embed(self, request):
+        embeddings = model.encode(request["texts"])
+        self.respond(200, {"model": model_name, "embeddings": embeddings.tolist()})
+
+    def rerank(self, request):
+        pairs = [(request["query"], passage) for passage in request["passages"]]
+        logits = get_cross_encoder().predict(pairs) if pairs else []
+        scores = [1 / (1 + math.exp(-float(logit))) for logit in logits]  # sigmoid, to range 0-1
+        self.respond(200, {"model": cross_encoder_name, "scores": scores})
+
+    def respond(self, status, body):
+        data = json.dumps(body).encode()
+        self.send_response(status)
+        self.send_header("Content-Type", "application/json")
+        self.send_header("Content-Length", str(len(data)))
+        self.end_headers()
+        self.wfile.write(data)
+
+
+# Usage:
+# - python main.py "My text to embed"
+# - python main.py --serve [port]
 def main():
+    if sys.argv[1] == "--serve":
+        port = int(sys.argv[2]) if len(sys.argv) > 2 else default_port
+        print(f"serving {model_name} embeddings on port {port}", flush=True)
+        ThreadingHTTPServer(("localhost", port), EmbeddingHandler).serve_forever()
+        return
+
     text = sys.argv[1]
     sentences = [text]
 
@@ -20,4 +96,4 @@ def main():
 
 
 if __name__ == "__main__":
-    main()
\ No newline at end of file
+    main()
@@ -0,0 +1,45 @@
+package vecdb
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrCollectionExists is returned when creating a collection that is already there
+	ErrCollectionExists = errors.New("collection already exists")
+
+	// ErrInvalidCollectionName is returned when the collection name can't be used as file name
+	ErrInvalidCollectionName = errors.New("invalid collection name")
+
+	// ErrCollectionNotFound is returned when the collection (or alias) doesn't exist
+	ErrCollectionNotFound = errors.New("collection not found")
+
+	// ErrModelMismatch is returned when collection stores embeddings of a different model than the embedder in use
+	ErrModelMismatch = errors.New("embedding model mismatch")
+
+	// ErrDimensionMismatch is returned when embedding size differs from the collection vector size
+	ErrDimensionMismatch = errors.New("embedding dimension mismatch")
+
+	// ErrUnknownVector is returned when searching named vector the collection doesn't have
+	ErrUnknownVector = errors.New("unknown vector")
+
+	// ErrQdrantUnreachable is returned when the vector database can't be connected to
+	ErrQdrantUnreachable = errors.New("qdrant unreachable")
+
+	// ErrEmbedderFailed is returned when the embedder could not produce embeddings
+	ErrEmbedderFailed = errors.New("embedder failed")
+)
+
+// HTTPError is returned when the server responded with status other than 200 OK
+type HTTPError struct {
+	URL        string
+	Method     string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("failed to perform %s request to %q: %s, Response: %s", e.Method, e.URL, e.Status, e.Body)
+}
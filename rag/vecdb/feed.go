@@ -0,0 +1,230 @@
+package vecdb
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FeedOptions controls how FeedDB embeds and stores the knowledge
+type FeedOptions struct {
+	Collection  string                // collection or alias to store into; empty means the one selected with UseCollection
+	BatchSize   int                   // how many texts are embedded and upserted in one request
+	Concurrency int                   // how many batches are processed in parallel
+	Append      bool                  // store into already existing collection instead of failing with ErrCollectionExists
+	Distance    string                // distance func of created collection, see VectorConfig; empty means DefaultDistance
+	Storage     VectorStorage         // how created collection stores the vectors, eg. as float16 or quantized; zero value means the store defaults
+	Vectors     []string              // named vectors embedded for every document, VectorText and/or VectorTitle; empty means single vector of the text, or VectorText if the documents come with precomputed vectors
+	Progress    func(done, total int) // optional, called after every batch with number of processed texts
+}
+
+// DefaultFeedOptions returns the options used by FeedDB
+func DefaultFeedOptions() FeedOptions {
+	return FeedOptions{BatchSize: 64, Concurrency: 4}
+}
+
+// BatchError describes a batch that could not be embedded or stored
+type BatchError struct {
+	Offset int // index of the first text of the batch
+	Size   int // number of texts in the batch
+	Err    error
+}
+
+func (e BatchError) Error() string {
+	return fmt.Sprintf("batch [%d:%d]: %v", e.Offset, e.Offset+e.Size, e.Err)
+}
+
+func (e BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Document is a piece of knowledge together with its metadata
+type Document struct {
+	ID      string                 // optional, MD5 hash of Text is used if empty
+	Text    string                 // content that gets embedded
+	Title   string                 // optional, embedded as VectorTitle if FeedOptions.Vectors include it
+	Vectors map[string][]float64   // optional, precomputed named vectors stored as they are, eg. VectorImage embedding from vision model
+	Payload map[string]interface{} // optional metadata stored next to the text, eg. source path
+}
+
+// batch is a range of documents processed together
+type batch struct {
+	offset int
+	size   int
+}
+
+// FeedReport summarizes the result of FeedDB
+type FeedReport struct {
+	Stored   int          // number of successfully stored texts
+	Failed   []BatchError // batches that failed, the remaining batches are stored anyway
+	Duration time.Duration
+}
+
+// FeedDB creates a new collection in the vector database and stores the provided knowledge in form of embeddings
+func FeedDB(knowledge []string) FeedReport {
+	return FeedDBWithOptions(knowledge, DefaultFeedOptions())
+}
+
+// FeedDBWithOptions is FeedDB that embeds and stores the knowledge in batches, using a pool of opts.Concurrency workers
+func FeedDBWithOptions(knowledge []string, opts FeedOptions) FeedReport {
+	report, err := FeedDBContext(context.Background(), knowledge, opts)
+	panicOnError(err)
+	return report
+}
+
+// FeedDBContext is FeedDBWithOptions that returns an error instead of exiting the process.
+// The error is returned when the collection can't be prepared or ctx gets done;
+// failures of individual batches don't stop the feeding and are listed in FeedReport.Failed
+func FeedDBContext(ctx context.Context, knowledge []string, opts FeedOptions) (FeedReport, error) {
+	docs := make([]Document, 0, len(knowledge))
+	for _, k := range knowledge {
+		docs = append(docs, Document{Text: k})
+	}
+	return FeedDocumentsContext(ctx, docs, opts)
+}
+
+// FeedDocumentsContext is FeedDBContext for knowledge with metadata; the metadata is stored as point payload
+func FeedDocumentsContext(ctx context.Context, docs []Document, opts FeedOptions) (FeedReport, error) {
+	return feedDocuments(ctx, collectionOr(opts.Collection), docs, opts)
+}
+
+// feedDocuments stores the documents in the given collection
+func feedDocuments(ctx context.Context, collection string, docs []Document, opts FeedOptions) (FeedReport, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultFeedOptions().BatchSize
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultFeedOptions().Concurrency
+	}
+
+	// the keyword index is kept under the collection name, not the alias, as keywordSearch reads it
+	collection, err := resolveAlias(ctx, collection)
+	if err != nil {
+		return FeedReport{}, err
+	}
+
+	slog.Debug("determining embeding dimensions")
+	probe, err := embed(ctx, "Check embeding dimensions")
+	if err != nil {
+		return FeedReport{}, err
+	}
+
+	// create the collection in vector database
+	config, err := feedConfig(len(probe), docs, opts)
+	if err != nil {
+		return FeedReport{}, err
+	}
+	model, err := embedderModel()
+	if err != nil {
+		return FeedReport{}, err
+	}
+	if err := prepareCollection(ctx, collection, config, model, opts.Append); err != nil {
+		return FeedReport{}, err
+	}
+
+	// split knowledge into batches
+	var batches []batch
+	for offset := 0; offset < len(docs); offset += opts.BatchSize {
+		size := min(opts.BatchSize, len(docs)-offset)
+		batches = append(batches, batch{offset: offset, size: size})
+	}
+
+	// store embeddings in collection, do it in parallel using a pool of workers
+	start := time.Now()
+	report := FeedReport{}
+	mu := sync.Mutex{}
+	jobs := make(chan batch)
+	wg := sync.WaitGroup{}
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range jobs {
+				err := feedBatch(ctx, collection, docs[b.offset:b.offset+b.size], config)
+
+				mu.Lock()
+				if err != nil {
+					report.Failed = append(report.Failed, BatchError{Offset: b.offset, Size: b.size, Err: err})
+					slog.Warn("failed to store batch", "offset", b.offset, "size", b.size, "error", err)
+				} else {
+					report.Stored += b.size
+				}
+				if opts.Progress != nil {
+					opts.Progress(report.Stored+failedCount(report.Failed), len(docs))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, b := range batches {
+		select {
+		case jobs <- b:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := saveKeywordIndex(collection); err != nil {
+		return report, err
+	}
+
+	sort.Slice(report.Failed, func(i, j int) bool { return report.Failed[i].Offset < report.Failed[j].Offset })
+	report.Duration = time.Since(start)
+	slog.Debug("Embedding and adding points", "duration", report.Duration, "stored", report.Stored, "failed_batches", len(report.Failed))
+	return report, ctx.Err()
+}
+
+// feedBatch embeds the documents with a single embedder call per embedded vector and stores them with a single upsert
+func feedBatch(ctx context.Context, collection string, docs []Document, config CollectionConfig) error {
+	points := make([]Point, 0, len(docs))
+	for _, doc := range docs {
+		points = append(points, makePoint(doc))
+	}
+
+	if len(config.NamedVectors) > 0 {
+		if err := setNamedVectors(ctx, points, docs, config.NamedVectors); err != nil {
+			return err
+		}
+	} else {
+		texts := make([]string, 0, len(docs))
+		for _, doc := range docs {
+			texts = append(texts, doc.Text)
+		}
+		embeddings, err := embedBatch(ctx, texts)
+		if err != nil {
+			return err
+		}
+		for i := range points {
+			points[i].Vector = float32s(embeddings[i])
+		}
+	}
+
+	if err := addPoints(ctx, collection, points); err != nil {
+		return err
+	}
+
+	// index the texts for keyword search too
+	idx, err := keywordIndex(collection)
+	if err != nil {
+		return err
+	}
+	for _, point := range points {
+		idx.Add(point)
+	}
+	return nil
+}
+
+// failedCount returns the number of texts in failed batches
+func failedCount(failed []BatchError) (count int) {
+	for _, f := range failed {
+		count += f.Size
+	}
+	return count
+}
@@ -0,0 +1,114 @@
+package vecdb_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+func TestFeedBatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		texts     int
+		batchSize int
+		failing   string // embedder fails the batches with text containing this
+		batches   []int  // sizes of the embedded batches, sorted; the probe of the dimensions excluded
+		stored    int
+		failed    []int // offsets of the failed batches
+	}{
+		{name: "single batch", texts: 3, batchSize: 8, batches: []int{3}, stored: 3},
+		{name: "last batch smaller", texts: 10, batchSize: 3, batches: []int{1, 3, 3, 3}, stored: 10},
+		{name: "failed batch skipped", texts: 10, batchSize: 3, failing: "text 4", batches: []int{1, 3, 3, 3}, stored: 7, failed: []int{3}},
+		{name: "all failed", texts: 4, batchSize: 2, failing: "text", batches: []int{2, 2}, failed: []int{0, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offline(t)
+			e := &recordingEmbedder{FakeEmbedder: vecdb.NewFakeEmbedder(0), failing: tt.failing}
+			vecdb.SetEmbedder(e)
+
+			var texts []string
+			for i := 0; i < tt.texts; i++ {
+				texts = append(texts, "text "+string(rune('0'+i)))
+			}
+			progress := 0
+			opts := vecdb.FeedOptions{BatchSize: tt.batchSize, Concurrency: 2, Progress: func(done, total int) { progress = done }}
+			report, err := vecdb.FeedDBContext(context.Background(), texts, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if report.Stored != tt.stored {
+				t.Errorf("stored %d, expected %d", report.Stored, tt.stored)
+			}
+			var failed []int
+			for _, f := range report.Failed {
+				failed = append(failed, f.Offset)
+				if !errors.Is(f, vecdb.ErrEmbedderFailed) {
+					t.Errorf("batch error %v is not ErrEmbedderFailed", f)
+				}
+			}
+			if !reflect.DeepEqual(failed, tt.failed) {
+				t.Errorf("failed batches at %v, expected %v", failed, tt.failed)
+			}
+			if progress != tt.texts {
+				t.Errorf("progress reported %d done, expected %d", progress, tt.texts)
+			}
+			if batches := e.sortedBatches(); !reflect.DeepEqual(batches, tt.batches) {
+				t.Errorf("embedded batches %v, expected %v", batches, tt.batches)
+			}
+			info, err := vecdb.DescribeCollection(context.Background(), "knowledge")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.PointsCount != tt.stored {
+				t.Errorf("collection has %d points, expected %d", info.PointsCount, tt.stored)
+			}
+		})
+	}
+}
+
+// recordingEmbedder records the sizes of the embedded batches, failing the ones with text containing failing
+type recordingEmbedder struct {
+	*vecdb.FakeEmbedder
+	failing string
+
+	mu      sync.Mutex
+	batches []int
+}
+
+func (e *recordingEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	if len(texts) == 1 && strings.HasPrefix(texts[0], "Check") {
+		return e.FakeEmbedder.EmbedBatch(ctx, texts) // the probe of the dimensions
+	}
+	e.mu.Lock()
+	e.batches = append(e.batches, len(texts))
+	e.mu.Unlock()
+	for _, text := range texts {
+		if e.failing != "" && strings.Contains(text, e.failing) {
+			return nil, errors.New("embedding failed")
+		}
+	}
+	return e.FakeEmbedder.EmbedBatch(ctx, texts)
+}
+
+func (e *recordingEmbedder) Embed(ctx context.Context, text string) ([]float64, error) {
+	vectors, err := e.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+func (e *recordingEmbedder) sortedBatches() []int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sort.Ints(e.batches)
+	return e.batches
+}
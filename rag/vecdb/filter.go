@@ -0,0 +1,269 @@
+package vecdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Filter narrows down the search to points whose payload matches the conditions, see: https://qdrant.tech/documentation/concepts/filtering/
+// Example:
+// {"must":[{"key":"source","match":{"value":"docs/rust.md"}}],"must_not":[{"key":"year","range":{"lt":2020}}]}
+type Filter struct {
+	Must    []Condition `json:"must,omitempty"`     // all conditions must be satisfied
+	Should  []Condition `json:"should,omitempty"`   // at least one condition must be satisfied
+	MustNot []Condition `json:"must_not,omitempty"` // none of the conditions can be satisfied
+}
+
+// Condition is either a payload field condition or a nested Filter
+type Condition struct {
+	Key    string  `json:"key,omitempty"`   // payload field, nested fields are separated with dots, eg. "meta.lang"
+	Match  *Match  `json:"match,omitempty"` // exact match
+	Range  *Range  `json:"range,omitempty"` // numeric or RFC 3339 date range
+	Filter *Filter `json:"-"`               // nested filter, mutually exclusive with field conditions
+}
+
+// Match represents exact match condition, only one of the fields should be set
+type Match struct {
+	Value  interface{}   `json:"value,omitempty"`  // payload field equals the value; string, integer or bool
+	Any    []interface{} `json:"any,omitempty"`    // payload field equals any of the values
+	Except []interface{} `json:"except,omitempty"` // payload field equals none of the values
+	Text   string        `json:"text,omitempty"`   // payload field contains the text
+}
+
+// Range represents range condition; values are numbers, or RFC 3339 strings for dates
+type Range struct {
+	Gt  interface{} `json:"gt,omitempty"`
+	Gte interface{} `json:"gte,omitempty"`
+	Lt  interface{} `json:"lt,omitempty"`
+	Lte interface{} `json:"lte,omitempty"`
+}
+
+// MatchValue creates condition: payload key equals value
+func MatchValue(key string, value interface{}) Condition {
+	return Condition{Key: key, Match: &Match{Value: value}}
+}
+
+// MatchAny creates condition: payload key equals any of values
+func MatchAny(key string, values ...interface{}) Condition {
+	return Condition{Key: key, Match: &Match{Any: values}}
+}
+
+// MatchText creates condition: payload key contains the text
+func MatchText(key, text string) Condition {
+	return Condition{Key: key, Match: &Match{Text: text}}
+}
+
+// InRange creates condition: gte <= payload key <= lte; nil bound means unbounded
+func InRange(key string, gte, lte interface{}) Condition {
+	return Condition{Key: key, Range: &Range{Gte: gte, Lte: lte}}
+}
+
+// InDateRange creates condition: from <= payload key <= to; zero time means unbounded
+func InDateRange(key string, from, to time.Time) Condition {
+	r := &Range{}
+	if !from.IsZero() {
+		r.Gte = from.Format(time.RFC3339)
+	}
+	if !to.IsZero() {
+		r.Lte = to.Format(time.RFC3339)
+	}
+	return Condition{Key: key, Range: r}
+}
+
+// Nested creates condition out of filter, eg. to express (a AND b) OR c
+func Nested(f Filter) Condition {
+	return Condition{Filter: &f}
+}
+
+// MarshalJSON serializes nested filter as the condition itself, as expected by qdrant
+func (c Condition) MarshalJSON() ([]byte, error) {
+	if c.Filter != nil {
+		return json.Marshal(c.Filter)
+	}
+	type plain Condition // avoid recursion
+	return json.Marshal(plain(c))
+}
+
+// UnmarshalJSON recognizes nested filter by "must", "should" or "must_not" keys
+func (c *Condition) UnmarshalJSON(data []byte) error {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	_, must := keys["must"]
+	_, should := keys["should"]
+	_, mustNot := keys["must_not"]
+	if must || should || mustNot {
+		c.Filter = &Filter{}
+		return json.Unmarshal(data, c.Filter)
+	}
+
+	type plain Condition // avoid recursion
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // keep integers as integers, qdrant won't match 1.0 against 1
+	return dec.Decode((*plain)(c))
+}
+
+// Matches evaluates the filter against the payload locally, the way qdrant would; used by the local keyword index
+func (f *Filter) Matches(payload map[string]interface{}) bool {
+	if f == nil {
+		return true
+	}
+	for _, c := range f.Must {
+		if !c.matches(payload) {
+			return false
+		}
+	}
+	for _, c := range f.MustNot {
+		if c.matches(payload) {
+			return false
+		}
+	}
+	if len(f.Should) == 0 {
+		return true
+	}
+	for _, c := range f.Should {
+		if c.matches(payload) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches evaluates single condition; array payload values match if any of the elements matches
+func (c Condition) matches(payload map[string]interface{}) bool {
+	if c.Filter != nil {
+		return c.Filter.Matches(payload)
+	}
+
+	for _, value := range payloadValues(payload, c.Key) {
+		if (c.Match == nil || c.Match.matches(value)) && (c.Range == nil || c.Range.matches(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// payloadValues returns the values under the dotted key, flattening arrays
+func payloadValues(payload map[string]interface{}, key string) []interface{} {
+	var current interface{} = payload
+	for _, part := range strings.Split(key, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[part]
+	}
+
+	switch v := current.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	case []string:
+		values := make([]interface{}, 0, len(v))
+		for _, s := range v {
+			values = append(values, s)
+		}
+		return values
+	default:
+		return []interface{}{v}
+	}
+}
+
+func (m *Match) matches(value interface{}) bool {
+	switch {
+	case m.Value != nil:
+		return sameValue(m.Value, value)
+	case m.Any != nil:
+		for _, v := range m.Any {
+			if sameValue(v, value) {
+				return true
+			}
+		}
+		return false
+	case m.Except != nil:
+		for _, v := range m.Except {
+			if sameValue(v, value) {
+				return false
+			}
+		}
+		return true
+	default:
+		s, ok := value.(string)
+		return ok && strings.Contains(strings.ToLower(s), strings.ToLower(m.Text))
+	}
+}
+
+func (r *Range) matches(value interface{}) bool {
+	return compareBound(value, r.Gt, func(c int) bool { return c > 0 }) &&
+		compareBound(value, r.Gte, func(c int) bool { return c >= 0 }) &&
+		compareBound(value, r.Lt, func(c int) bool { return c < 0 }) &&
+		compareBound(value, r.Lte, func(c int) bool { return c <= 0 })
+}
+
+// compareBound compares value with the bound; missing bound is always satisfied
+func compareBound(value, bound interface{}, ok func(cmp int) bool) bool {
+	if bound == nil {
+		return true
+	}
+	if v, isNum := toFloat(value); isNum {
+		b, isNum := toFloat(bound)
+		return isNum && ok(cmpFloat(v, b))
+	}
+	v, isTime := toTime(value)
+	b, isBoundTime := toTime(bound)
+	return isTime && isBoundTime && ok(v.Compare(b))
+}
+
+// sameValue compares payload values, treating all numbers alike
+func sameValue(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return a == b
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func toTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		return parsed, err == nil
+	default:
+		return time.Time{}, false
+	}
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,132 @@
+package vecdb_test
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+func TestFilterMatches(t *testing.T) {
+	payload := map[string]interface{}{
+		"source":      "docs/rust.md",
+		"year":        2021,
+		"lang":        "en",
+		"tags":        []interface{}{"memory", "safety"},
+		"meta":        map[string]interface{}{"author": "ann"},
+		"modified_at": "2025-03-10T12:00:00Z",
+		"text":        "Rust guarantees Memory Safety",
+	}
+	march := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		filter  *vecdb.Filter
+		matches bool
+	}{
+		{"nil filter", nil, true},
+		{"empty filter", &vecdb.Filter{}, true},
+		{"value", &vecdb.Filter{Must: []vecdb.Condition{vecdb.MatchValue("lang", "en")}}, true},
+		{"other value", &vecdb.Filter{Must: []vecdb.Condition{vecdb.MatchValue("lang", "pl")}}, false},
+		{"integer as float", &vecdb.Filter{Must: []vecdb.Condition{vecdb.MatchValue("year", 2021.0)}}, true},
+		{"missing key", &vecdb.Filter{Must: []vecdb.Condition{vecdb.MatchValue("author", "ann")}}, false},
+		{"nested key", &vecdb.Filter{Must: []vecdb.Condition{vecdb.MatchValue("meta.author", "ann")}}, true},
+		{"array element", &vecdb.Filter{Must: []vecdb.Condition{vecdb.MatchValue("tags", "safety")}}, true},
+		{"any", &vecdb.Filter{Must: []vecdb.Condition{vecdb.MatchAny("lang", "pl", "en")}}, true},
+		{"except", &vecdb.Filter{Must: []vecdb.Condition{{Key: "lang", Match: &vecdb.Match{Except: []interface{}{"en"}}}}}, false},
+		{"text case insensitive", &vecdb.Filter{Must: []vecdb.Condition{vecdb.MatchText("text", "memory safety")}}, true},
+		{"range", &vecdb.Filter{Must: []vecdb.Condition{vecdb.InRange("year", 2020, 2021)}}, true},
+		{"range exclusive", &vecdb.Filter{Must: []vecdb.Condition{{Key: "year", Range: &vecdb.Range{Lt: 2021}}}}, false},
+		{"date range", &vecdb.Filter{Must: []vecdb.Condition{vecdb.InDateRange("modified_at", march, time.Time{})}}, true},
+		{"date range before", &vecdb.Filter{Must: []vecdb.Condition{vecdb.InDateRange("modified_at", time.Time{}, march)}}, false},
+		{"number against date bound", &vecdb.Filter{Must: []vecdb.Condition{vecdb.InDateRange("year", march, time.Time{})}}, false},
+		{"must not", &vecdb.Filter{MustNot: []vecdb.Condition{vecdb.MatchValue("lang", "en")}}, false},
+		{"should one of", &vecdb.Filter{Should: []vecdb.Condition{vecdb.MatchValue("lang", "pl"), vecdb.MatchValue("year", 2021)}}, true},
+		{"should none", &vecdb.Filter{Should: []vecdb.Condition{vecdb.MatchValue("lang", "pl")}}, false},
+		{
+			name: "(lang=pl AND year=2021) OR source=rust",
+			filter: &vecdb.Filter{Should: []vecdb.Condition{
+				vecdb.Nested(vecdb.Filter{Must: []vecdb.Condition{vecdb.MatchValue("lang", "pl"), vecdb.MatchValue("year", 2021)}}),
+				vecdb.MatchValue("source", "docs/rust.md"),
+			}},
+			matches: true,
+		},
+		{
+			name:    "nested must not",
+			filter:  &vecdb.Filter{Must: []vecdb.Condition{vecdb.Nested(vecdb.Filter{MustNot: []vecdb.Condition{vecdb.MatchValue("lang", "en")}})}},
+			matches: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if matches := tt.filter.Matches(payload); matches != tt.matches {
+				t.Fatalf("matches %v, expected %v", matches, tt.matches)
+			}
+		})
+	}
+}
+
+func TestFilterJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"field conditions", `{"must":[{"key":"source","match":{"value":"docs/rust.md"}}],"must_not":[{"key":"year","range":{"lt":2020}}]}`},
+		{"nested filter", `{"should":[{"must":[{"key":"lang","match":{"value":"pl"}},{"key":"year","match":{"any":[2020,2021]}}]},{"key":"source","match":{"text":"rust"}}]}`},
+		{"deeply nested", `{"must":[{"must_not":[{"should":[{"key":"lang","match":{"except":["en"]}}]}]}]}`},
+		{"date range", `{"must":[{"key":"modified_at","range":{"gte":"2025-03-01T00:00:00Z"}}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var filter vecdb.Filter
+			if err := json.Unmarshal([]byte(tt.json), &filter); err != nil {
+				t.Fatal(err)
+			}
+			data, err := json.Marshal(filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.json {
+				t.Fatalf("round trip gives\n%s\nexpected\n%s", data, tt.json)
+			}
+		})
+	}
+}
+
+func TestFilterJSONKeepsIntegers(t *testing.T) {
+	var filter vecdb.Filter
+	if err := json.Unmarshal([]byte(`{"must":[{"key":"year","match":{"value":2021}}]}`), &filter); err != nil {
+		t.Fatal(err)
+	}
+	value := filter.Must[0].Match.Value
+	if !reflect.DeepEqual(value, json.Number("2021")) {
+		t.Fatalf("value %v of type %T, expected integer json.Number", value, value)
+	}
+}
+
+func TestAskWithFilter(t *testing.T) {
+	offline(t)
+	ctx := context.Background()
+	docs := []vecdb.Document{
+		{Text: "Go has goroutines.", Payload: map[string]interface{}{"lang": "en", "year": 2012}},
+		{Text: "Go ma gorutyny.", Payload: map[string]interface{}{"lang": "pl", "year": 2012}},
+		{Text: "Go 1.22 has range over integers.", Payload: map[string]interface{}{"lang": "en", "year": 2024}},
+	}
+	if _, err := vecdb.FeedDocumentsContext(ctx, docs, vecdb.DefaultFeedOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	filter := &vecdb.Filter{Must: []vecdb.Condition{vecdb.MatchValue("lang", "en"), vecdb.InRange("year", 2020, nil)}}
+	for _, mode := range []string{vecdb.SearchVector, vecdb.SearchKeyword, vecdb.SearchHybrid} {
+		results, err := vecdb.AskDBQuery(ctx, vecdb.Query{Text: "go goroutines", Limit: 3, Filter: filter, Mode: mode})
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		if len(results) != 1 || results[0].Text != docs[2].Text {
+			t.Fatalf("%s: results %+v, expected only %q", mode, results, docs[2].Text)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package vecdb
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// HNSW defaults, the same as in qdrant
+const (
+	DefaultHNSWM           = 16  // links per node
+	DefaultHNSWEfConstruct = 100 // candidates considered when linking new node
+	DefaultHNSWEf          = 64  // candidates considered when searching
+)
+
+// hnswIndex is Hierarchical Navigable Small World graph for approximate nearest neighbours search, see: https://arxiv.org/abs/1603.09320.
+// Removed nodes stay in the graph to keep it connected, they are just skipped in the results
+type hnswIndex struct {
+	m           int
+	efConstruct int
+	levelFactor float64 // normalizes the random level of new nodes, 1/ln(m)
+	distance    func(a, b []float32) float64
+	rng         *rand.Rand
+
+	nodes    []hnswNode
+	byID     map[string]int // live node of each point
+	entry    int            // node where every search starts, -1 if graph is empty
+	maxLevel int
+	removed  int
+
+	visited   []uint32 // node -> number of the search that visited it last, reused to avoid allocating per search
+	searchNum uint32
+}
+
+// hnswNode is a point in the graph, linked to its nearest neighbours on each level it belongs to
+type hnswNode struct {
+	id      string
+	vector  []float32
+	links   [][]int // level -> neighbour nodes
+	removed bool
+}
+
+// candidate is a graph node found for a vector
+type candidate struct {
+	node     int
+	distance float64
+}
+
+// newHNSWIndex creates empty graph; the random levels are seeded so that the same inserts build the same graph
+func newHNSWIndex(m, efConstruct int, distance func(a, b []float32) float64) *hnswIndex {
+	return &hnswIndex{
+		m:           m,
+		efConstruct: efConstruct,
+		levelFactor: 1 / math.Log(float64(m)),
+		distance:    distance,
+		rng:         rand.New(rand.NewSource(1)),
+		byID:        map[string]int{},
+		entry:       -1,
+	}
+}
+
+// add inserts the point into the graph, replacing the point with the same id
+func (h *hnswIndex) add(id string, vector []float32) {
+	h.remove(id)
+
+	level := int(-math.Log(1-h.rng.Float64()) * h.levelFactor)
+	node := len(h.nodes)
+	h.nodes = append(h.nodes, hnswNode{id: id, vector: vector, links: make([][]int, level+1)})
+	h.byID[id] = node
+	if h.entry < 0 {
+		h.entry, h.maxLevel = node, level
+		return
+	}
+
+	// Greedy descent through the levels above the new node, then link it with its nearest neighbours on the remaining levels
+	entries := []candidate{{h.entry, h.distance(vector, h.nodes[h.entry].vector)}}
+	for l := h.maxLevel; l > level; l-- {
+		entries = h.searchLevel(vector, entries, 1, l)
+	}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		entries = h.searchLevel(vector, entries, h.efConstruct, l)
+		for _, nearest := range entries[:min(len(entries), h.m)] {
+			h.nodes[node].links[l] = append(h.nodes[node].links[l], nearest.node)
+			h.link(nearest.node, node, l)
+		}
+	}
+
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = node, level
+	}
+}
+
+// remove marks the point as removed; no-op if it's not in the graph
+func (h *hnswIndex) remove(id string) {
+	node, ok := h.byID[id]
+	if !ok {
+		return
+	}
+	h.nodes[node].removed = true
+	delete(h.byID, id)
+	h.removed++
+}
+
+// len returns the number of live points
+func (h *hnswIndex) len() int {
+	return len(h.byID)
+}
+
+// stale tells if most of the graph are removed nodes, ie. it's time to build it again
+func (h *hnswIndex) stale() bool {
+	return h.removed > len(h.nodes)/2
+}
+
+// search returns up to k accepted points nearest to the vector, best first; ef is raised to k if lower
+func (h *hnswIndex) search(vector []float32, k, ef int, accept func(id string) bool) []neighbour {
+	if h.entry < 0 || k <= 0 {
+		return nil
+	}
+
+	entries := []candidate{{h.entry, h.distance(vector, h.nodes[h.entry].vector)}}
+	for l := h.maxLevel; l > 0; l-- {
+		entries = h.searchLevel(vector, entries, 1, l)
+	}
+	found := h.searchLevel(vector, entries, max(ef, k), 0)
+
+	var result []neighbour
+	for _, c := range found {
+		if len(result) == k {
+			break
+		}
+		if n := h.nodes[c.node]; !n.removed && accept(n.id) {
+			result = append(result, neighbour{n.id, c.distance})
+		}
+	}
+	return result
+}
+
+// searchLevel finds up to ef nodes nearest to the vector on given level, starting from the entries; returns them best first
+func (h *hnswIndex) searchLevel(vector []float32, entries []candidate, ef, level int) []candidate {
+	h.searchNum++
+	if len(h.visited) < len(h.nodes) {
+		h.visited = append(h.visited, make([]uint32, len(h.nodes)-len(h.visited))...)
+	}
+	visited := func(node int) bool {
+		seen := h.visited[node] == h.searchNum
+		h.visited[node] = h.searchNum
+		return seen
+	}
+
+	toVisit := &candidateHeap{}             // nearest first
+	found := &candidateHeap{farthest: true} // farthest first, so that the worst found is at hand
+	for _, e := range entries {
+		visited(e.node)
+		heap.Push(toVisit, e)
+		heap.Push(found, e)
+	}
+	for found.Len() > ef {
+		heap.Pop(found)
+	}
+
+	for toVisit.Len() > 0 {
+		c := heap.Pop(toVisit).(candidate)
+		if found.Len() >= ef && c.distance > found.items[0].distance {
+			break // everything left to visit is farther than the worst found
+		}
+		for _, linked := range h.nodes[c.node].links[level] {
+			if visited(linked) {
+				continue
+			}
+
+			d := h.distance(vector, h.nodes[linked].vector)
+			if found.Len() < ef || d < found.items[0].distance {
+				heap.Push(toVisit, candidate{linked, d})
+				heap.Push(found, candidate{linked, d})
+				if found.Len() > ef {
+					heap.Pop(found)
+				}
+			}
+		}
+	}
+
+	result := found.items
+	sort.Slice(result, func(i, j int) bool { return result[i].distance < result[j].distance })
+	return result
+}
+
+// link connects node to other node on given level, keeping only the nearest links if there are too many
+func (h *hnswIndex) link(node, other, level int) {
+	maxLinks := h.m
+	if level == 0 {
+		maxLinks = 2 * h.m // the bottom level is denser, as in the paper
+	}
+
+	links := append(h.nodes[node].links[level], other)
+	if len(links) > maxLinks {
+		vector := h.nodes[node].vector
+		distances := map[int]float64{}
+		for _, n := range links {
+			distances[n] = h.distance(vector, h.nodes[n].vector)
+		}
+		sort.Slice(links, func(i, j int) bool { return distances[links[i]] < distances[links[j]] })
+		links = links[:maxLinks]
+	}
+	h.nodes[node].links[level] = links
+}
+
+// candidateHeap is heap of candidates, nearest or farthest first
+type candidateHeap struct {
+	items    []candidate
+	farthest bool
+}
+
+func (h *candidateHeap) Len() int           { return len(h.items) }
+func (h *candidateHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *candidateHeap) Push(x interface{}) { h.items = append(h.items, x.(candidate)) }
+
+func (h *candidateHeap) Less(i, j int) bool {
+	if h.farthest {
+		return h.items[i].distance > h.items[j].distance
+	}
+	return h.items[i].distance < h.items[j].distance
+}
+
+func (h *candidateHeap) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
@@ -0,0 +1,125 @@
+package vecdb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// rrfK dampens the contribution of the top ranks in reciprocal rank fusion, 60 is the value from the original paper
+const rrfK = 60
+
+// hybridOverFetch is how many more candidates than requested are taken from each ranking before fusing them
+const hybridOverFetch = 4
+
+// keywordSearch finds the points best matching the query terms in the local keyword index
+func keywordSearch(ctx context.Context, collection string, q Query) ([]SearchResult, error) {
+	collection, err := resolveAlias(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := keywordIndex(collection)
+	if err != nil {
+		return nil, err
+	}
+	return idx.Search(q.Text, q.Limit, q.Filter), nil
+}
+
+// hybridSearch runs both vector and keyword search and merges the results with reciprocal rank fusion
+func hybridSearch(ctx context.Context, collection string, q Query) ([]SearchResult, error) {
+	vectorWeight, keywordWeight := weightOrDefault(q.VectorWeight), weightOrDefault(q.KeywordWeight)
+	if vectorWeight < 0 || keywordWeight < 0 || vectorWeight+keywordWeight == 0 {
+		return nil, fmt.Errorf("invalid hybrid weights: vector %v, keyword %v; expected non-negative and at least one positive", vectorWeight, keywordWeight)
+	}
+	candidates := q
+	candidates.Limit = q.Limit * hybridOverFetch
+
+	// the search with zero weight is skipped, so that its results don't show up at the end of the fused ranking
+	var rankings [][]SearchResult
+	var weights []float64
+	if vectorWeight > 0 {
+		vectorResults, err := vectorSearch(ctx, collection, candidates)
+		if err != nil {
+			return nil, err
+		}
+		rankings = append(rankings, vectorResults)
+		weights = append(weights, vectorWeight)
+	}
+	if keywordWeight > 0 {
+		keywordResults, err := keywordSearch(ctx, collection, candidates)
+		if err != nil {
+			return nil, err
+		}
+		rankings = append(rankings, keywordResults)
+		weights = append(weights, keywordWeight)
+	}
+	return FuseRankings(rankings, weights, q.Limit), nil
+}
+
+// FuseRankings merges the rankings with weighted reciprocal rank fusion: score = sum(weight / (60 + rank)).
+// The score is normalized to 0-1, where 1 means the first place in all the rankings; the ids are compared in canonical UUID form
+func FuseRankings(rankings [][]SearchResult, weights []float64, limit int) []SearchResult {
+	fused := map[string]*SearchResult{}
+	var maxScore float64
+	for i, ranking := range rankings {
+		weight := 1.0
+		if i < len(weights) {
+			weight = weights[i]
+		}
+		maxScore += weight / (rrfK + 1)
+
+		for rank, r := range ranking {
+			id := canonicalPointID(r.ID) // qdrant returns dashed UUIDs, the keyword index keeps the ids as stored
+			if _, ok := fused[id]; !ok {
+				result := r
+				result.ID = id
+				result.Score = 0
+				fused[id] = &result
+			}
+			fused[id].Score += weight / float64(rrfK+rank+1)
+		}
+	}
+
+	results := make([]SearchResult, 0, len(fused))
+	for _, r := range fused {
+		if maxScore > 0 {
+			r.Score /= maxScore
+		}
+		results = append(results, *r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// resolveAlias returns the collection the alias points to, or the name itself if it is not an alias
+func resolveAlias(ctx context.Context, name string) (string, error) {
+	aliases, err := ListAliases(ctx)
+	if err != nil {
+		return "", err
+	}
+	if collection, ok := aliases[name]; ok {
+		return collection, nil
+	}
+	return name, nil
+}
+
+// Weight returns pointer to the weight, for setting Query.VectorWeight and KeywordWeight
+func Weight(w float64) *float64 {
+	return &w
+}
+
+// weightOrDefault treats unset weight as 1
+func weightOrDefault(weight *float64) float64 {
+	if weight == nil {
+		return 1
+	}
+	return *weight
+}
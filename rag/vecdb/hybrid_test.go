@@ -0,0 +1,118 @@
+package vecdb_test
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+func TestFuseRankings(t *testing.T) {
+	ranking := func(ids ...string) (results []vecdb.SearchResult) {
+		for _, id := range ids {
+			results = append(results, vecdb.SearchResult{ID: id, Text: "text of " + id})
+		}
+		return results
+	}
+	const uuid = "0f343b09-3fb0-4c07-a3b6-2c9b5ef39d10"
+	const md5 = "0f343b093fb04c07a3b62c9b5ef39d10"
+
+	tests := []struct {
+		name     string
+		rankings [][]vecdb.SearchResult
+		weights  []float64
+		limit    int
+		ids      []string
+		scores   []float64
+	}{
+		{
+			name:     "first in both",
+			rankings: [][]vecdb.SearchResult{ranking("a", "b"), ranking("a", "c")},
+			limit:    3,
+			ids:      []string{"a", "b", "c"},
+			scores:   []float64{1, 61.0 / 62 / 2, 61.0 / 62 / 2},
+		},
+		{
+			name:     "agreement beats single first place",
+			rankings: [][]vecdb.SearchResult{ranking("a", "b"), ranking("c", "b")},
+			limit:    1,
+			ids:      []string{"b"},
+		},
+		{
+			name:     "weighted",
+			rankings: [][]vecdb.SearchResult{ranking("a", "b"), ranking("b", "a")},
+			weights:  []float64{1, 2},
+			limit:    2,
+			ids:      []string{"b", "a"},
+		},
+		{
+			name:     "ids compared in canonical form",
+			rankings: [][]vecdb.SearchResult{ranking(uuid), ranking(md5)},
+			limit:    3,
+			ids:      []string{uuid},
+			scores:   []float64{1},
+		},
+		{
+			name:     "empty",
+			rankings: [][]vecdb.SearchResult{nil, nil},
+			limit:    3,
+			ids:      []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fused := vecdb.FuseRankings(tt.rankings, tt.weights, tt.limit)
+			ids := []string{}
+			for i, r := range fused {
+				ids = append(ids, r.ID)
+				if tt.scores != nil && math.Abs(r.Score-tt.scores[i]) > 1e-9 {
+					t.Errorf("%s scored %v, expected %v", r.ID, r.Score, tt.scores[i])
+				}
+				if r.Text != "text of "+r.ID && r.ID != uuid {
+					t.Errorf("%s lost its text: %q", r.ID, r.Text)
+				}
+			}
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Fatalf("fused %v, expected %v", ids, tt.ids)
+			}
+		})
+	}
+}
+
+func TestHybridWeights(t *testing.T) {
+	offline(t)
+	ctx := context.Background()
+	if _, err := vecdb.FeedDBContext(ctx, knowledge, vecdb.DefaultFeedOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		vector  *float64
+		keyword *float64
+		results int // the keyword search finds only the texts sharing the words with the query
+		valid   bool
+	}{
+		{name: "defaults", results: 3, valid: true},
+		{name: "keyword only", vector: vecdb.Weight(0), results: 1, valid: true},
+		{name: "vector only", keyword: vecdb.Weight(0), results: 3, valid: true},
+		{name: "both off", vector: vecdb.Weight(0), keyword: vecdb.Weight(0)},
+		{name: "negative", keyword: vecdb.Weight(-1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := vecdb.AskDBQuery(ctx, vecdb.Query{Text: "goroutines", Limit: 3, Mode: vecdb.SearchHybrid, VectorWeight: tt.vector, KeywordWeight: tt.keyword})
+			if (err == nil) != tt.valid {
+				t.Fatalf("error %v, expected valid %v", err, tt.valid)
+			}
+			if len(results) != tt.results {
+				t.Fatalf("%d results, expected %d", len(results), tt.results)
+			}
+			if tt.valid && results[0].Text != knowledge[0] {
+				t.Fatalf("expected %q first, got %+v", knowledge[0], results)
+			}
+		})
+	}
+}
@@ -1,37 +1,130 @@
 package vecdb
 
 import (
-	// "bytes"
 	"bytes"
-	"crypto/md5"
-	"encoding/hex"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log/slog"
 	"net/http"
-	"os"
-	"os/exec"
-	"sync"
-	"time"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 // CollectionConfig represents the complete collection (Database) configuration.
 type CollectionConfig struct {
-	Vectors VectorConfig `json:"vectors"`
+	Vectors      VectorConfig            // the single, unnamed vector of every point; not used if NamedVectors are set
+	NamedVectors map[string]VectorConfig // optional, several vectors per point, eg. "text" and "title"
+	Metadata     map[string]interface{}  // eg. the embedding model
+}
+
+// collectionConfigJSON is CollectionConfig as sent to qdrant: "vectors" is either a single config or name -> config map
+type collectionConfigJSON struct {
+	Vectors  json.RawMessage        `json:"vectors"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// MarshalJSON writes the vectors as single config, or as name -> config map if the config has named vectors
+func (c CollectionConfig) MarshalJSON() ([]byte, error) {
+	var vectors interface{} = c.Vectors
+	if len(c.NamedVectors) > 0 {
+		vectors = c.NamedVectors
+	}
+	data, err := json.Marshal(vectors)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(collectionConfigJSON{Vectors: data, Metadata: c.Metadata})
+}
+
+// UnmarshalJSON reads both the single and the named vectors config
+func (c *CollectionConfig) UnmarshalJSON(data []byte) error {
+	var raw collectionConfigJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*c = CollectionConfig{Metadata: raw.Metadata}
+	if len(raw.Vectors) == 0 {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw.Vectors, &fields); err != nil {
+		return err
+	}
+	if _, single := fields["size"]; single || len(fields) == 0 {
+		return json.Unmarshal(raw.Vectors, &c.Vectors)
+	}
+	return json.Unmarshal(raw.Vectors, &c.NamedVectors)
+}
+
+// vectorConfigs returns the vectors of every point by name; the single vector is named ""
+func (c CollectionConfig) vectorConfigs() map[string]VectorConfig {
+	if len(c.NamedVectors) > 0 {
+		return c.NamedVectors
+	}
+	return map[string]VectorConfig{"": c.Vectors}
 }
 
 // VectorConfig represents the configuration for vectors.
 type VectorConfig struct {
 	Size     int    `json:"size"`     // how many dimensions
-	Distance string `json:"distance"` // distance func ["Cosine", "Dot", "Euclidean", "Manhattan"]
+	Distance string `json:"distance"` // distance func ["Cosine", "Dot", "Euclid", "Manhattan"]
+	VectorStorage
 }
 
 // Point is a single entry in collection
 type Point struct {
-	ID      string                 `json:"id"`
-	Vector  []float64              `json:"vector"`
-	Payload map[string]interface{} `json:"payload"` // optional
+	ID      string
+	Vector  []float32              // the single vector, in collection without named vectors
+	Vectors map[string][]float32   // the named vectors, in collection with named vectors; any of them can be missing
+	Payload map[string]interface{} // optional
+}
+
+// pointJSON is Point as sent to qdrant: "vector" is either a single vector or name -> vector map
+type pointJSON struct {
+	ID      PointID                `json:"id"`
+	Vector  json.RawMessage        `json:"vector"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// MarshalJSON writes the vector as array, or the named vectors as name -> array map
+func (p Point) MarshalJSON() ([]byte, error) {
+	var vector interface{} = p.Vector
+	if p.Vectors != nil {
+		vector = p.Vectors
+	}
+	data, err := json.Marshal(vector)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(pointJSON{ID: PointID(p.ID), Vector: data, Payload: p.Payload})
+}
+
+// UnmarshalJSON reads both the single and the named vectors
+func (p *Point) UnmarshalJSON(data []byte) error {
+	var raw pointJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = Point{ID: string(raw.ID), Payload: raw.Payload}
+	if len(raw.Vector) == 0 {
+		return nil
+	}
+	if raw.Vector[0] == '{' {
+		return json.Unmarshal(raw.Vector, &p.Vectors)
+	}
+	return json.Unmarshal(raw.Vector, &p.Vector)
+}
+
+// vector returns the vector of given name, "" meaning the single vector; nil if the point doesn't have it
+func (p Point) vector(name string) []float32 {
+	if name == "" {
+		return p.Vector
+	}
+	return p.Vectors[name]
 }
 
 // Points represents multiple entries in collection
@@ -41,10 +134,30 @@ type Points struct {
 
 // SearchQuery represents the search query structure.
 type SearchQuery struct {
-	Vector      []float64 `json:"vector"`       // search input
-	Limit       int       `json:"limit"`        // how many entries to return?
-	WithPayload bool      `json:"with_payload"` // should return payload text?
-	WithVectors bool      `json:"with_vectors"`
+	Vector      []float32     `json:"vector"`           // search input
+	Using       string        `json:"-"`                // name of the vector to search, in collection with named vectors
+	Filter      *Filter       `json:"filter,omitempty"` // optional payload conditions
+	Params      *SearchParams `json:"params,omitempty"` // optional, eg. HNSW ef or quantization rescoring
+	Limit       int           `json:"limit"`            // how many entries to return?
+	WithPayload bool          `json:"with_payload"`     // should return payload text?
+	WithVectors bool          `json:"with_vectors"`
+}
+
+// MarshalJSON writes the vector as {"name": ..., "vector": [...]} if the query searches named vector
+func (q SearchQuery) MarshalJSON() ([]byte, error) {
+	type plain SearchQuery // without the MarshalJSON method
+	if q.Using == "" {
+		return json.Marshal(plain(q))
+	}
+
+	type namedVector struct {
+		Name   string    `json:"name"`
+		Vector []float32 `json:"vector"`
+	}
+	return json.Marshal(struct {
+		plain
+		Vector namedVector `json:"vector"` // shadows plain.Vector
+	}{plain(q), namedVector{Name: q.Using, Vector: q.Vector}})
 }
 
 // SearchResponse represents search response structure.
@@ -52,187 +165,325 @@ type SearchQuery struct {
 // {"result":[{"id":"9b31733d-aa7a-07e9-71a1-dd8110a83374","version":2,"score":0.7733528,"payload":{"text":"C++ is programming language that produces fast programs"}}],"status":"ok","time":0.001875241}
 type SearchResponse struct {
 	Result []struct {
-		ID      string  `json:"id"`
-		Version int     `json:"version"`
-		Score   float64 `json:"score"`
-		Payload struct {
-			Text string `json:"text"`
-		} `json:"payload"`
+		ID      PointID                `json:"id"`
+		Version int                    `json:"version"`
+		Score   float64                `json:"score"`
+		Payload map[string]interface{} `json:"payload"`
 	} `json:"result"`
 	Status string  `json:"status"`
 	Time   float64 `json:"time"`
 }
 
-type SearchResult struct {
-	Score float64 // range 0-1
-	Text  string  // payload of the found vector db entry
+// PointID is point identifier; qdrant uses either UUID strings or unsigned integers
+type PointID string
+
+// UnmarshalJSON accepts both string and integer ids
+func (id *PointID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = PointID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*id = PointID(n.String())
+	return nil
+}
+
+// MarshalJSON sends the id the way restPointID does, so numeric ids stay numbers
+func (id PointID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(restPointID(string(id)))
 }
 
-const dbBaseURL = "http://localhost:6333/collections/"
-const collectionName = "knowledge"
+// restPointID converts point id for the REST API; unsigned integers are sent as numbers, anything else as UUID string
+func restPointID(id string) interface{} {
+	if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+		return n
+	}
+	return id
+}
 
-// FeedDB creates a new collection in the vector database and stores the provided knowledge in form of embeddings
-func FeedDB(knowledge []string) {
-	slog.Debug("determining embeding dimensions")
-	dimensions := len(embed("Check embeding dimensions"))
+// DefaultQdrantURL is where qdrant listens by default, see the Makefile
+const DefaultQdrantURL = "http://localhost:6333"
 
-	// create the collection in vector database
-	panicOnError(addCollection(dimensions))
+// httpClient is shared by all requests so that connections get reused; timeouts are controlled with context
+var httpClient = &http.Client{}
 
-	// store embeddings in collection, do it in parallel - reduces time by 3x
-	start := time.Now()
-	wg := sync.WaitGroup{}
-	for _, k := range knowledge {
-		wg.Add(1)
-		go func(k string) {
-			defer wg.Done()
-			embedding := embed(k)
-			panicOnError(addPoint(embedding, k))
-		}(k)
+// QdrantStore is VectorStore backed by qdrant server, talking its REST API
+type QdrantStore struct {
+	URL string
+}
 
+// NewQdrantStore creates QdrantStore; empty url means DefaultQdrantURL
+func NewQdrantStore(url string) *QdrantStore {
+	if url == "" {
+		url = DefaultQdrantURL
 	}
-	wg.Wait()
+	return &QdrantStore{URL: strings.TrimSuffix(url, "/")}
+}
 
-	elapsed := time.Since(start)
-	slog.Debug("Embedding and adding points", "duration", elapsed)
+// collectionURL returns the URL of the collection endpoint
+func (s *QdrantStore) collectionURL(name string) string {
+	return s.URL + "/collections/" + name
 }
 
-// AskDB retrieves information from the vector database based on the provided question, it returns a maximum of maxAnswers
-func AskDB(question string, maxAnswers int) (result []SearchResult) {
-	embedding := embed(question)
-	response, err := search(embedding, question, maxAnswers)
-	panicOnError(err)
+// CreateCollection implements VectorStore
+func (s *QdrantStore) CreateCollection(ctx context.Context, name string, config CollectionConfig) error {
+	_, err := qdrantRequest(ctx, s.collectionURL(name), "PUT", config)
+	return err
+}
 
-	for _, r := range response.Result {
-		result = append(result, SearchResult{Score: r.Score, Text: r.Payload.Text})
+// DescribeCollection implements VectorStore
+func (s *QdrantStore) DescribeCollection(ctx context.Context, name string) (CollectionInfo, error) {
+	rspString, err := qdrantRequest(ctx, s.collectionURL(name), "GET", nil)
+	if err != nil {
+		return CollectionInfo{}, err
 	}
 
-	return result
+	var rsp struct {
+		Result struct {
+			Status      string `json:"status"`
+			PointsCount int    `json:"points_count"`
+			Config      struct {
+				Params   CollectionConfig       `json:"params"`
+				Metadata map[string]interface{} `json:"metadata"`
+			} `json:"config"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
+		return CollectionInfo{}, err
+	}
+	config := rsp.Result.Config.Params
+	config.Metadata = rsp.Result.Config.Metadata
+	return makeCollectionInfo(name, rsp.Result.Status, rsp.Result.PointsCount, config), nil
 }
 
-// addCollection creates new collection of entries in vector database
-func addCollection(dimensions int) error {
-	slog.Debug("add collection", slog.String("name", collectionName), slog.Int("dimensions", dimensions))
+// ListCollections implements VectorStore
+func (s *QdrantStore) ListCollections(ctx context.Context) ([]string, error) {
+	rspString, err := qdrantRequest(ctx, s.URL+"/collections", "GET", nil)
+	if err != nil {
+		return nil, err
+	}
 
-	// Prepare database config
-	config := CollectionConfig{
-		Vectors: VectorConfig{
-			Size:     dimensions,
-			Distance: "Cosine",
-		},
+	var rsp struct {
+		Result struct {
+			Collections []struct {
+				Name string `json:"name"`
+			} `json:"collections"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
+		return nil, err
 	}
 
-	// Send request
-	url := dbBaseURL + collectionName
-	_, err := request(url, "PUT", config)
+	var names []string
+	for _, c := range rsp.Result.Collections {
+		names = append(names, c.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
 
-	// Return result
+// DeleteCollection implements VectorStore
+func (s *QdrantStore) DeleteCollection(ctx context.Context, name string) error {
+	_, err := qdrantRequest(ctx, s.collectionURL(name), "DELETE", nil)
 	return err
 }
 
-// addPoint adds new entry to collection
-func addPoint(vector []float64, text string) error {
-	slog.Debug("add point", slog.String("text", text))
+// ListAliases implements VectorStore
+func (s *QdrantStore) ListAliases(ctx context.Context) (map[string]string, error) {
+	rspString, err := qdrantRequest(ctx, s.URL+"/aliases", "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var rsp struct {
+		Result struct {
+			Aliases []struct {
+				AliasName      string `json:"alias_name"`
+				CollectionName string `json:"collection_name"`
+			} `json:"aliases"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
+		return nil, err
+	}
+
+	aliases := map[string]string{}
+	for _, a := range rsp.Result.Aliases {
+		aliases[a.AliasName] = a.CollectionName
+	}
+	return aliases, nil
+}
 
-	// Prepare payload
-	payload := map[string]interface{}{
-		"text": text,
+// SwitchAlias implements VectorStore; the alias is deleted and created again in a single, atomic request
+func (s *QdrantStore) SwitchAlias(ctx context.Context, alias, collection string) error {
+	type deleteAlias struct {
+		AliasName string `json:"alias_name"`
 	}
-	point := Point{
-		ID:      generateMD5HashString(text),
-		Vector:  vector,
-		Payload: payload,
+	type createAlias struct {
+		CollectionName string `json:"collection_name"`
+		AliasName      string `json:"alias_name"`
 	}
-	points := Points{
-		Points: []Point{point},
+	type action struct {
+		DeleteAlias *deleteAlias `json:"delete_alias,omitempty"`
+		CreateAlias *createAlias `json:"create_alias,omitempty"`
 	}
 
-	// Send request
-	url := dbBaseURL + collectionName + "/points"
-	_, err := request(url, "PUT", points)
+	aliases, err := s.ListAliases(ctx)
+	if err != nil {
+		return err
+	}
+	var actions []action
+	if _, exists := aliases[alias]; exists {
+		actions = append(actions, action{DeleteAlias: &deleteAlias{AliasName: alias}})
+	}
+	actions = append(actions, action{CreateAlias: &createAlias{CollectionName: collection, AliasName: alias}})
 
-	// Return result
+	_, err = qdrantRequest(ctx, s.collectionURL("aliases"), "POST", map[string]interface{}{"actions": actions})
 	return err
 }
 
-// search looks up database entries similar to provided vector
-func search(vector []float64, text string, maxAnswers int) (*SearchResponse, error) {
-	slog.Debug("search", slog.String("text", text))
-
-	// Prepare search query
-	query := SearchQuery{Vector: vector, Limit: maxAnswers, WithPayload: true}
+// Upsert implements VectorStore
+func (s *QdrantStore) Upsert(ctx context.Context, collection string, points []Point) error {
+	_, err := qdrantRequest(ctx, s.collectionURL(collection)+"/points", "PUT", Points{Points: points})
+	return err
+}
 
-	// Send request
-	url := dbBaseURL + collectionName + "/points/search"
-	rspString, err := request(url, "POST", query)
+// Delete implements VectorStore
+func (s *QdrantStore) Delete(ctx context.Context, collection string, ids []string) error {
+	points := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		points = append(points, restPointID(id))
+	}
+	selector := struct {
+		Points []interface{} `json:"points"`
+	}{Points: points}
+	_, err := qdrantRequest(ctx, s.collectionURL(collection)+"/points/delete?wait=true", "POST", selector)
+	return err
+}
 
+// Search implements VectorStore
+func (s *QdrantStore) Search(ctx context.Context, collection string, query SearchQuery) ([]SearchResult, error) {
+	rspString, err := qdrantRequest(ctx, s.collectionURL(collection)+"/points/search", "POST", query)
 	if err != nil {
 		return nil, err
 	}
 
-	// Deserialize response
-	var rsp *SearchResponse
-	err = json.Unmarshal([]byte(rspString), &rsp)
-	if err != nil {
+	var rsp SearchResponse
+	if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
 		return nil, err
 	}
 
-	// Return result
-	return rsp, nil
+	var result []SearchResult
+	for _, r := range rsp.Result {
+		text, _ := r.Payload["text"].(string)
+		result = append(result, SearchResult{ID: string(r.ID), Score: r.Score, Text: text, Payload: r.Payload})
+	}
+	return result, nil
 }
 
-// request is a helper func that sends http request to url with provided data, and returns the response as text
-func request(url, method string, data interface{}) (string, error) {
-	jsonData, err := json.Marshal(data)
+// Scroll implements VectorStore
+func (s *QdrantStore) Scroll(ctx context.Context, collection, offset string, limit int) ([]Point, string, error) {
+	query := map[string]interface{}{"limit": limit, "with_payload": true, "with_vector": true}
+	if offset != "" {
+		query["offset"] = restPointID(offset)
+	}
+	rspString, err := qdrantRequest(ctx, s.collectionURL(collection)+"/points/scroll", "POST", query)
 	if err != nil {
+		return nil, "", err
+	}
+
+	var rsp struct {
+		Result struct {
+			Points         []Point  `json:"points"`
+			NextPageOffset *PointID `json:"next_page_offset"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
+		return nil, "", err
+	}
+	next := ""
+	if rsp.Result.NextPageOffset != nil {
+		next = string(*rsp.Result.NextPageOffset)
+	}
+	return rsp.Result.Points, next, nil
+}
+
+// CreatePayloadIndex implements VectorStore
+func (s *QdrantStore) CreatePayloadIndex(ctx context.Context, collection, field, schema string) error {
+	config := struct {
+		FieldName   string `json:"field_name"`
+		FieldSchema string `json:"field_schema"`
+	}{FieldName: field, FieldSchema: schema}
+	_, err := qdrantRequest(ctx, s.collectionURL(collection)+"/index", "PUT", config)
+	return err
+}
+
+// Ping implements VectorStore
+func (s *QdrantStore) Ping(ctx context.Context) error {
+	_, err := qdrantRequest(ctx, s.URL+"/healthz", "GET", nil)
+	return err
+}
+
+// qdrantRequest is request that translates the failures into vecdb errors
+func qdrantRequest(ctx context.Context, url, method string, data interface{}) (string, error) {
+	rspString, err := request(ctx, url, method, data)
+	if err == nil {
+		return rspString, nil
+	}
+
+	var httpErr *HTTPError
+	switch {
+	case errors.As(err, &httpErr) && (httpErr.StatusCode == http.StatusConflict || strings.Contains(httpErr.Body, "already exists")):
+		return "", fmt.Errorf("%w: %w", ErrCollectionExists, err)
+	case errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("%w: %w", ErrCollectionNotFound, err)
+	case errors.As(err, &httpErr) && strings.Contains(httpErr.Body, "dimension error"):
+		return "", fmt.Errorf("%w: %w", ErrDimensionMismatch, err)
+	case errors.As(err, &httpErr) || ctx.Err() != nil:
 		return "", err
+	default:
+		return "", fmt.Errorf("%w: %w", ErrQdrantUnreachable, err)
 	}
+}
 
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(jsonData))
+// request is a helper func that sends http request to url with provided data, and returns the response as text.
+// Nil data means request without body
+func request(ctx context.Context, url, method string, data interface{}) (string, error) {
+	var body io.Reader
+	if data != nil {
+		jsonData, err := json.Marshal(data)
+		if err != nil {
+			return "", err
+		}
+		body = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return "", err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
-	bodyBytes, _ := io.ReadAll(resp.Body)
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
 	bodyString := string(bodyBytes)
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to perform request to %q: %s, Response: %s", url, resp.Status, bodyString)
+		return "", &HTTPError{URL: url, Method: method, StatusCode: resp.StatusCode, Status: resp.Status, Body: bodyString}
 	}
 
 	return bodyString, nil
 }
-
-// generateMD5HashString generates an MD5 hash string from the provided text.
-func generateMD5HashString(text string) string {
-	h := md5.New()
-	h.Write([]byte(text))
-	return hex.EncodeToString(h.Sum(nil))
-}
-
-// panicOnError checks if the provided error is not nil and panics with that error.
-func panicOnError(err error) {
-	if err != nil {
-		slog.Error(err.Error())
-		os.Exit(1)
-	}
-}
-
-// embed executes a Python script to generate an embedding for the given input string and returns it as a slice of float64 values.
-func embed(input string) []float64 {
-	cmd := exec.Command("python", "./vecdb/embedding-localhost/main.py", input)
-	output, err := cmd.Output()
-	panicOnError(err)
-	var embedding []float64
-	panicOnError(json.Unmarshal(output, &embedding))
-	return embedding
-}
@@ -0,0 +1,802 @@
+package vecdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DefaultQdrantGRPCAddr is where qdrant listens for gRPC by default, see the Makefile
+const DefaultQdrantGRPCAddr = "localhost:6334"
+
+// QdrantGRPCStore is VectorStore backed by qdrant server, talking its gRPC API: vectors go as binary float32 instead of JSON text.
+// The connections are opened on first use and reused by all the requests; big upserts are split into batches sent concurrently
+type QdrantGRPCStore struct {
+	Addr              string // host:port
+	Connections       int    // size of the connection pool; 0 means the client default
+	UpsertBatch       int    // max points sent in one upsert request
+	UpsertConcurrency int    // max upsert requests in flight
+
+	mu     sync.Mutex
+	client *qdrant.Client
+}
+
+// NewQdrantGRPCStore creates QdrantGRPCStore; empty addr means DefaultQdrantGRPCAddr. Scheme in addr, eg. http://, is ignored
+func NewQdrantGRPCStore(addr string) *QdrantGRPCStore {
+	if addr == "" {
+		addr = DefaultQdrantGRPCAddr
+	}
+	if _, rest, found := strings.Cut(addr, "://"); found {
+		addr = rest
+	}
+	return &QdrantGRPCStore{Addr: strings.TrimSuffix(addr, "/"), UpsertBatch: 256, UpsertConcurrency: 4}
+}
+
+// CreateCollection implements VectorStore
+func (s *QdrantGRPCStore) CreateCollection(ctx context.Context, name string, config CollectionConfig) error {
+	client, err := s.connect()
+	if err != nil {
+		return err
+	}
+
+	var vectorsConfig *qdrant.VectorsConfig
+	if len(config.NamedVectors) > 0 {
+		paramsMap := map[string]*qdrant.VectorParams{}
+		for vectorName, vectors := range config.NamedVectors {
+			if paramsMap[vectorName], err = grpcVectorParams(vectors); err != nil {
+				return err
+			}
+		}
+		vectorsConfig = qdrant.NewVectorsConfigMap(paramsMap)
+	} else {
+		params, err := grpcVectorParams(config.Vectors)
+		if err != nil {
+			return err
+		}
+		vectorsConfig = qdrant.NewVectorsConfig(params)
+	}
+	metadata, err := grpcPayload(config.Metadata)
+	if err != nil {
+		return err
+	}
+
+	err = client.CreateCollection(ctx, &qdrant.CreateCollection{
+		CollectionName: name,
+		VectorsConfig:  vectorsConfig,
+		Metadata:       metadata,
+	})
+	return grpcError(ctx, err)
+}
+
+// DescribeCollection implements VectorStore
+func (s *QdrantGRPCStore) DescribeCollection(ctx context.Context, name string) (CollectionInfo, error) {
+	client, err := s.connect()
+	if err != nil {
+		return CollectionInfo{}, err
+	}
+
+	info, err := client.GetCollectionInfo(ctx, name)
+	if err != nil {
+		return CollectionInfo{}, grpcError(ctx, err)
+	}
+
+	vectorsConfig := info.GetConfig().GetParams().GetVectorsConfig()
+	config := CollectionConfig{Metadata: map[string]interface{}{}}
+	if paramsMap := vectorsConfig.GetParamsMap().GetMap(); len(paramsMap) > 0 {
+		config.NamedVectors = map[string]VectorConfig{}
+		for vectorName, params := range paramsMap {
+			config.NamedVectors[vectorName] = vectorConfig(params)
+		}
+	} else {
+		config.Vectors = vectorConfig(vectorsConfig.GetParams())
+	}
+	for k, v := range info.GetConfig().GetMetadata() {
+		config.Metadata[k] = payloadValue(v)
+	}
+	state := strings.ToLower(info.GetStatus().String())
+	return makeCollectionInfo(name, state, int(info.GetPointsCount()), config), nil
+}
+
+// ListCollections implements VectorStore
+func (s *QdrantGRPCStore) ListCollections(ctx context.Context) ([]string, error) {
+	client, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := client.ListCollections(ctx)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DeleteCollection implements VectorStore
+func (s *QdrantGRPCStore) DeleteCollection(ctx context.Context, name string) error {
+	client, err := s.connect()
+	if err != nil {
+		return err
+	}
+	return grpcError(ctx, client.DeleteCollection(ctx, name))
+}
+
+// ListAliases implements VectorStore
+func (s *QdrantGRPCStore) ListAliases(ctx context.Context) (map[string]string, error) {
+	client, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	descriptions, err := client.ListAliases(ctx)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	aliases := map[string]string{}
+	for _, a := range descriptions {
+		aliases[a.GetAliasName()] = a.GetCollectionName()
+	}
+	return aliases, nil
+}
+
+// SwitchAlias implements VectorStore; the alias is deleted and created again in a single, atomic request
+func (s *QdrantGRPCStore) SwitchAlias(ctx context.Context, alias, collection string) error {
+	client, err := s.connect()
+	if err != nil {
+		return err
+	}
+
+	aliases, err := s.ListAliases(ctx)
+	if err != nil {
+		return err
+	}
+	var actions []*qdrant.AliasOperations
+	if _, exists := aliases[alias]; exists {
+		actions = append(actions, qdrant.NewAliasDelete(alias))
+	}
+	actions = append(actions, qdrant.NewAliasCreate(alias, collection))
+	return grpcError(ctx, client.UpdateAliases(ctx, actions))
+}
+
+// Upsert implements VectorStore; the points are sent in batches of UpsertBatch, up to UpsertConcurrency batches at a time.
+// On failure some of the batches may be stored already
+func (s *QdrantGRPCStore) Upsert(ctx context.Context, collection string, points []Point) error {
+	client, err := s.connect()
+	if err != nil {
+		return err
+	}
+
+	structs := make([]*qdrant.PointStruct, 0, len(points))
+	for _, p := range points {
+		payload, err := grpcPayload(p.Payload)
+		if err != nil {
+			return fmt.Errorf("point %q: %w", p.ID, err)
+		}
+		vectors := qdrant.NewVectorsDense(p.Vector)
+		if p.Vectors != nil {
+			named := make(map[string]*qdrant.Vector, len(p.Vectors))
+			for name, vector := range p.Vectors {
+				named[name] = qdrant.NewVectorDense(vector)
+			}
+			vectors = qdrant.NewVectorsMap(named)
+		}
+		structs = append(structs, &qdrant.PointStruct{Id: grpcPointID(p.ID), Vectors: vectors, Payload: payload})
+	}
+
+	batchSize := max(s.UpsertBatch, 1)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		inFlight = make(chan struct{}, max(s.UpsertConcurrency, 1))
+	)
+	for offset := 0; offset < len(structs) && ctx.Err() == nil; offset += batchSize {
+		batch := structs[offset:min(offset+batchSize, len(structs))]
+		inFlight <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-inFlight; wg.Done() }()
+			_, err := client.Upsert(ctx, &qdrant.UpsertPoints{CollectionName: collection, Wait: qdrant.PtrOf(true), Points: batch})
+			if err != nil {
+				errOnce.Do(func() { firstErr = grpcError(ctx, err); cancel() })
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// Delete implements VectorStore
+func (s *QdrantGRPCStore) Delete(ctx context.Context, collection string, ids []string) error {
+	client, err := s.connect()
+	if err != nil {
+		return err
+	}
+
+	pointIDs := make([]*qdrant.PointId, 0, len(ids))
+	for _, id := range ids {
+		pointIDs = append(pointIDs, grpcPointID(id))
+	}
+	_, err = client.Delete(ctx, &qdrant.DeletePoints{CollectionName: collection, Wait: qdrant.PtrOf(true), Points: qdrant.NewPointsSelectorIDs(pointIDs)})
+	return grpcError(ctx, err)
+}
+
+// Search implements VectorStore
+func (s *QdrantGRPCStore) Search(ctx context.Context, collection string, query SearchQuery) ([]SearchResult, error) {
+	client, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := grpcFilter(query.Filter)
+	if err != nil {
+		return nil, err
+	}
+	request := &qdrant.QueryPoints{
+		CollectionName: collection,
+		Query:          qdrant.NewQueryDense(query.Vector),
+		Filter:         filter,
+		WithPayload:    qdrant.NewWithPayload(query.WithPayload),
+		Params:         grpcSearchParams(query.Params),
+	}
+	if query.Using != "" {
+		request.Using = qdrant.PtrOf(query.Using)
+	}
+	if query.Limit > 0 {
+		request.Limit = qdrant.PtrOf(uint64(query.Limit))
+	}
+
+	points, err := client.Query(ctx, request)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+
+	var result []SearchResult
+	for _, p := range points {
+		payload := map[string]interface{}{}
+		for k, v := range p.GetPayload() {
+			payload[k] = payloadValue(v)
+		}
+		text, _ := payload["text"].(string)
+		result = append(result, SearchResult{ID: pointIDString(p.GetId()), Score: float64(p.GetScore()), Text: text, Payload: payload})
+	}
+	return result, nil
+}
+
+// Scroll implements VectorStore
+func (s *QdrantGRPCStore) Scroll(ctx context.Context, collection, offset string, limit int) ([]Point, string, error) {
+	client, err := s.connect()
+	if err != nil {
+		return nil, "", err
+	}
+
+	request := &qdrant.ScrollPoints{
+		CollectionName: collection,
+		Limit:          qdrant.PtrOf(uint32(limit)),
+		WithPayload:    qdrant.NewWithPayload(true),
+		WithVectors:    qdrant.NewWithVectors(true),
+	}
+	if offset != "" {
+		request.Offset = grpcPointID(offset)
+	}
+	retrieved, nextOffset, err := client.ScrollAndOffset(ctx, request)
+	if err != nil {
+		return nil, "", grpcError(ctx, err)
+	}
+
+	points := make([]Point, 0, len(retrieved))
+	for _, r := range retrieved {
+		p := Point{ID: pointIDString(r.GetId()), Payload: map[string]interface{}{}}
+		for k, v := range r.GetPayload() {
+			p.Payload[k] = payloadValue(v)
+		}
+		if named := r.GetVectors().GetVectors().GetVectors(); named != nil {
+			p.Vectors = make(map[string][]float32, len(named))
+			for name, vector := range named {
+				p.Vectors[name] = denseVector(vector)
+			}
+		} else {
+			p.Vector = denseVector(r.GetVectors().GetVector())
+		}
+		points = append(points, p)
+	}
+	next := ""
+	if nextOffset != nil {
+		next = pointIDString(nextOffset)
+	}
+	return points, next, nil
+}
+
+// CreatePayloadIndex implements VectorStore
+func (s *QdrantGRPCStore) CreatePayloadIndex(ctx context.Context, collection, field, schema string) error {
+	client, err := s.connect()
+	if err != nil {
+		return err
+	}
+
+	fieldTypes := map[string]qdrant.FieldType{
+		"keyword":  qdrant.FieldType_FieldTypeKeyword,
+		"integer":  qdrant.FieldType_FieldTypeInteger,
+		"float":    qdrant.FieldType_FieldTypeFloat,
+		"geo":      qdrant.FieldType_FieldTypeGeo,
+		"text":     qdrant.FieldType_FieldTypeText,
+		"bool":     qdrant.FieldType_FieldTypeBool,
+		"datetime": qdrant.FieldType_FieldTypeDatetime,
+		"uuid":     qdrant.FieldType_FieldTypeUuid,
+	}
+	fieldType, ok := fieldTypes[schema]
+	if !ok {
+		return fmt.Errorf("unknown payload index schema %q", schema)
+	}
+	_, err = client.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{CollectionName: collection, Wait: qdrant.PtrOf(true), FieldName: field, FieldType: &fieldType})
+	return grpcError(ctx, err)
+}
+
+// Ping implements VectorStore
+func (s *QdrantGRPCStore) Ping(ctx context.Context) error {
+	client, err := s.connect()
+	if err != nil {
+		return err
+	}
+	_, err = client.HealthCheck(ctx)
+	return grpcError(ctx, err)
+}
+
+// Close closes the connections; the store connects again on next request
+func (s *QdrantGRPCStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == nil {
+		return nil
+	}
+	err := s.client.Close()
+	s.client = nil
+	return err
+}
+
+// connect returns the client, creating it on first use; the connections are established lazily by grpc
+func (s *QdrantGRPCStore) connect() (*qdrant.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil {
+		return s.client, nil
+	}
+
+	host, portString, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid qdrant gRPC address %q: %w", s.Addr, err)
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return nil, fmt.Errorf("invalid qdrant gRPC address %q: %w", s.Addr, err)
+	}
+
+	// skip version check as it would query the server right away
+	s.client, err = qdrant.NewClient(&qdrant.Config{Host: host, Port: port, PoolSize: uint(max(s.Connections, 0)), SkipCompatibilityCheck: true})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrQdrantUnreachable, err)
+	}
+	return s.client, nil
+}
+
+// grpcError translates the failures into vecdb errors, the same way as qdrantRequest
+func grpcError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	message := strings.ToLower(err.Error())
+	switch code := status.Code(err); {
+	case ctx.Err() != nil:
+		return err
+	case code == codes.AlreadyExists || strings.Contains(message, "already exists"):
+		return fmt.Errorf("%w: %w", ErrCollectionExists, err)
+	case code == codes.NotFound:
+		return fmt.Errorf("%w: %w", ErrCollectionNotFound, err)
+	case strings.Contains(message, "dimension error"):
+		return fmt.Errorf("%w: %w", ErrDimensionMismatch, err)
+	case code == codes.Unavailable || code == codes.DeadlineExceeded:
+		return fmt.Errorf("%w: %w", ErrQdrantUnreachable, err)
+	default:
+		return err
+	}
+}
+
+// grpcPointID converts point id; unsigned integers become numeric ids, anything else is taken for UUID
+func grpcPointID(id string) *qdrant.PointId {
+	if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+		return qdrant.NewIDNum(n)
+	}
+	return qdrant.NewIDUUID(id)
+}
+
+// pointIDString converts point id back to string, as returned by the REST API
+func pointIDString(id *qdrant.PointId) string {
+	if uuid := id.GetUuid(); uuid != "" {
+		return uuid
+	}
+	return strconv.FormatUint(id.GetNum(), 10)
+}
+
+// denseVector returns the data of dense vector; older qdrant servers fill the deprecated data field instead of the dense one
+func denseVector(v *qdrant.VectorOutput) []float32 {
+	if dense := v.GetDense(); dense != nil {
+		return dense.GetData()
+	}
+	return v.GetData()
+}
+
+// grpcVectorParams converts the vector config into qdrant vector params
+func grpcVectorParams(vectors VectorConfig) (*qdrant.VectorParams, error) {
+	distance, ok := qdrant.Distance_value[vectors.Distance]
+	if !ok || vectors.Distance == qdrant.Distance_UnknownDistance.String() {
+		return nil, fmt.Errorf("unknown distance %q", vectors.Distance)
+	}
+	params := &qdrant.VectorParams{Size: uint64(vectors.Size), Distance: qdrant.Distance(distance)}
+	if err := setGRPCStorage(params, vectors.VectorStorage); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// vectorConfig converts qdrant vector params back into vector config
+func vectorConfig(params *qdrant.VectorParams) VectorConfig {
+	return VectorConfig{Size: int(params.GetSize()), Distance: params.GetDistance().String(), VectorStorage: vectorStorage(params)}
+}
+
+// grpcDatatypes maps the vector datatypes to qdrant ones
+var grpcDatatypes = map[string]qdrant.Datatype{
+	DatatypeFloat32: qdrant.Datatype_Float32,
+	DatatypeFloat16: qdrant.Datatype_Float16,
+	DatatypeUint8:   qdrant.Datatype_Uint8,
+}
+
+// setGRPCStorage sets the storage settings of the vectors in the qdrant vector params
+func setGRPCStorage(params *qdrant.VectorParams, storage VectorStorage) error {
+	if storage.Datatype != "" {
+		datatype, ok := grpcDatatypes[storage.Datatype]
+		if !ok {
+			return checkDatatype(storage.Datatype)
+		}
+		params.Datatype = &datatype
+	}
+	if storage.OnDisk {
+		params.OnDisk = qdrant.PtrOf(true)
+	}
+	if h := storage.HNSW; h != nil {
+		params.HnswConfig = &qdrant.HnswConfigDiff{}
+		if h.M > 0 {
+			params.HnswConfig.M = qdrant.PtrOf(uint64(h.M))
+		}
+		if h.EfConstruct > 0 {
+			params.HnswConfig.EfConstruct = qdrant.PtrOf(uint64(h.EfConstruct))
+		}
+		if h.OnDisk {
+			params.HnswConfig.OnDisk = qdrant.PtrOf(true)
+		}
+	}
+
+	q := storage.Quantization
+	switch {
+	case q == nil:
+	case q.Scalar != nil:
+		scalar := &qdrant.ScalarQuantization{Type: qdrant.QuantizationType_Int8, AlwaysRam: qdrant.PtrOf(q.Scalar.AlwaysRAM)}
+		if q.Scalar.Quantile != nil {
+			scalar.Quantile = qdrant.PtrOf(float32(*q.Scalar.Quantile))
+		}
+		params.QuantizationConfig = qdrant.NewQuantizationScalar(scalar)
+	case q.Product != nil:
+		compression, ok := qdrant.CompressionRatio_value[q.Product.Compression]
+		if !ok {
+			return fmt.Errorf("unknown compression %q, expected one of: %s", q.Product.Compression, strings.Join(compressionRatios, ", "))
+		}
+		params.QuantizationConfig = qdrant.NewQuantizationProduct(&qdrant.ProductQuantization{
+			Compression: qdrant.CompressionRatio(compression),
+			AlwaysRam:   qdrant.PtrOf(q.Product.AlwaysRAM),
+		})
+	case q.Binary != nil:
+		params.QuantizationConfig = qdrant.NewQuantizationBinary(&qdrant.BinaryQuantization{AlwaysRam: qdrant.PtrOf(q.Binary.AlwaysRAM)})
+	}
+	return nil
+}
+
+// vectorStorage reads the storage settings from the qdrant vector params
+func vectorStorage(params *qdrant.VectorParams) VectorStorage {
+	storage := VectorStorage{OnDisk: params.GetOnDisk()}
+	if datatype := params.GetDatatype(); datatype != qdrant.Datatype_Default {
+		storage.Datatype = strings.ToLower(datatype.String()) // eg. Float16 -> float16
+	}
+	if h := params.GetHnswConfig(); h != nil {
+		storage.HNSW = &HNSWConfig{M: int(h.GetM()), EfConstruct: int(h.GetEfConstruct()), OnDisk: h.GetOnDisk()}
+	}
+
+	q := params.GetQuantizationConfig()
+	switch {
+	case q.GetScalar() != nil:
+		scalar := &ScalarQuantization{Type: "int8", AlwaysRAM: q.GetScalar().GetAlwaysRam()}
+		if q.GetScalar().Quantile != nil {
+			scalar.Quantile = qdrant.PtrOf(float64(q.GetScalar().GetQuantile()))
+		}
+		storage.Quantization = &QuantizationConfig{Scalar: scalar}
+	case q.GetProduct() != nil:
+		storage.Quantization = &QuantizationConfig{Product: &ProductQuantization{
+			Compression: q.GetProduct().GetCompression().String(),
+			AlwaysRAM:   q.GetProduct().GetAlwaysRam(),
+		}}
+	case q.GetBinary() != nil:
+		storage.Quantization = &QuantizationConfig{Binary: &BinaryQuantization{AlwaysRAM: q.GetBinary().GetAlwaysRam()}}
+	}
+	return storage
+}
+
+// grpcSearchParams converts the search params into qdrant ones; nil for nil params
+func grpcSearchParams(params *SearchParams) *qdrant.SearchParams {
+	if params == nil {
+		return nil
+	}
+
+	result := &qdrant.SearchParams{}
+	if params.HNSWEf > 0 {
+		result.HnswEf = qdrant.PtrOf(uint64(params.HNSWEf))
+	}
+	if params.Exact {
+		result.Exact = qdrant.PtrOf(true)
+	}
+	if q := params.Quantization; q != nil {
+		result.Quantization = &qdrant.QuantizationSearchParams{Rescore: q.Rescore}
+		if q.Ignore {
+			result.Quantization.Ignore = qdrant.PtrOf(true)
+		}
+		if q.Oversampling > 0 {
+			result.Quantization.Oversampling = qdrant.PtrOf(q.Oversampling)
+		}
+	}
+	return result
+}
+
+// grpcPayload converts the payload into qdrant values
+func grpcPayload(payload map[string]interface{}) (map[string]*qdrant.Value, error) {
+	values := make(map[string]*qdrant.Value, len(payload))
+	for k, v := range payload {
+		value, err := grpcValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("payload %q: %w", k, err)
+		}
+		values[k] = value
+	}
+	return values, nil
+}
+
+// grpcValue converts payload value into qdrant value; whole numbers become integers, as they do when sent as JSON,
+// and the types unknown to qdrant client, eg. []string or time.Time, go through JSON
+func grpcValue(v interface{}) (*qdrant.Value, error) {
+	switch v := v.(type) {
+	case nil, bool, string, int, int32, int64, uint, uint32, uint64:
+		return qdrant.NewValue(v)
+	case float32:
+		return grpcValue(float64(v))
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return qdrant.NewValueInt(int64(v)), nil
+		}
+		return qdrant.NewValueDouble(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return qdrant.NewValueInt(i), nil
+		}
+		f, err := v.Float64()
+		return qdrant.NewValueDouble(f), err
+	case map[string]interface{}:
+		fields, err := grpcPayload(v)
+		return qdrant.NewValueFromFields(fields), err
+	case []interface{}:
+		values := make([]*qdrant.Value, 0, len(v))
+		for _, item := range v {
+			value, err := grpcValue(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return qdrant.NewValueFromList(values...), nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var decoded interface{}
+		decoder := json.NewDecoder(strings.NewReader(string(data)))
+		decoder.UseNumber()
+		if err := decoder.Decode(&decoded); err != nil {
+			return nil, err
+		}
+		return grpcValue(decoded)
+	}
+}
+
+// payloadValue converts qdrant value back into payload value, as decoded from JSON by the REST API
+func payloadValue(v *qdrant.Value) interface{} {
+	switch kind := v.GetKind().(type) {
+	case *qdrant.Value_BoolValue:
+		return kind.BoolValue
+	case *qdrant.Value_IntegerValue:
+		return float64(kind.IntegerValue)
+	case *qdrant.Value_DoubleValue:
+		return kind.DoubleValue
+	case *qdrant.Value_StringValue:
+		return kind.StringValue
+	case *qdrant.Value_StructValue:
+		fields := map[string]interface{}{}
+		for k, field := range kind.StructValue.GetFields() {
+			fields[k] = payloadValue(field)
+		}
+		return fields
+	case *qdrant.Value_ListValue:
+		values := []interface{}{}
+		for _, item := range kind.ListValue.GetValues() {
+			values = append(values, payloadValue(item))
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// grpcFilter converts the filter into qdrant filter
+func grpcFilter(f *Filter) (*qdrant.Filter, error) {
+	if f == nil {
+		return nil, nil
+	}
+
+	var err error
+	filter := &qdrant.Filter{}
+	if filter.Must, err = grpcConditions(f.Must); err != nil {
+		return nil, err
+	}
+	if filter.Should, err = grpcConditions(f.Should); err != nil {
+		return nil, err
+	}
+	if filter.MustNot, err = grpcConditions(f.MustNot); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func grpcConditions(conditions []Condition) ([]*qdrant.Condition, error) {
+	var result []*qdrant.Condition
+	for _, c := range conditions {
+		condition, err := grpcCondition(c)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, condition)
+	}
+	return result, nil
+}
+
+func grpcCondition(c Condition) (*qdrant.Condition, error) {
+	if c.Filter != nil {
+		nested, err := grpcFilter(c.Filter)
+		return qdrant.NewFilterAsCondition(nested), err
+	}
+
+	field := &qdrant.FieldCondition{Key: c.Key}
+	if c.Match != nil {
+		match, err := grpcMatch(c.Match)
+		if err != nil {
+			return nil, fmt.Errorf("condition on %q: %w", c.Key, err)
+		}
+		field.Match = match
+	}
+	if c.Range != nil {
+		if err := setGRPCRange(field, c.Range); err != nil {
+			return nil, fmt.Errorf("condition on %q: %w", c.Key, err)
+		}
+	}
+	return &qdrant.Condition{ConditionOneOf: &qdrant.Condition_Field{Field: field}}, nil
+}
+
+func grpcMatch(m *Match) (*qdrant.Match, error) {
+	switch {
+	case m.Value != nil:
+		if s, ok := m.Value.(string); ok {
+			return &qdrant.Match{MatchValue: &qdrant.Match_Keyword{Keyword: s}}, nil
+		}
+		if b, ok := m.Value.(bool); ok {
+			return &qdrant.Match{MatchValue: &qdrant.Match_Boolean{Boolean: b}}, nil
+		}
+		if i, ok := toInteger(m.Value); ok {
+			return &qdrant.Match{MatchValue: &qdrant.Match_Integer{Integer: i}}, nil
+		}
+		return nil, fmt.Errorf("can't match value %v of type %T", m.Value, m.Value)
+	case m.Any != nil:
+		keywords, integers, err := splitMatchValues(m.Any)
+		if keywords != nil {
+			return &qdrant.Match{MatchValue: &qdrant.Match_Keywords{Keywords: &qdrant.RepeatedStrings{Strings: keywords}}}, err
+		}
+		return &qdrant.Match{MatchValue: &qdrant.Match_Integers{Integers: &qdrant.RepeatedIntegers{Integers: integers}}}, err
+	case m.Except != nil:
+		keywords, integers, err := splitMatchValues(m.Except)
+		if keywords != nil {
+			return &qdrant.Match{MatchValue: &qdrant.Match_ExceptKeywords{ExceptKeywords: &qdrant.RepeatedStrings{Strings: keywords}}}, err
+		}
+		return &qdrant.Match{MatchValue: &qdrant.Match_ExceptIntegers{ExceptIntegers: &qdrant.RepeatedIntegers{Integers: integers}}}, err
+	default:
+		return &qdrant.Match{MatchValue: &qdrant.Match_Text{Text: m.Text}}, nil
+	}
+}
+
+// splitMatchValues returns the values as either keywords or integers, qdrant can't match a mix of them
+func splitMatchValues(values []interface{}) (keywords []string, integers []int64, err error) {
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			keywords = append(keywords, s)
+		} else if i, ok := toInteger(v); ok {
+			integers = append(integers, i)
+		} else {
+			return nil, nil, fmt.Errorf("can't match value %v of type %T", v, v)
+		}
+	}
+	if keywords != nil && integers != nil {
+		return nil, nil, fmt.Errorf("can't match both keywords and integers: %v", values)
+	}
+	return keywords, integers, nil
+}
+
+// setGRPCRange sets numeric range, or datetime range if the bounds are RFC 3339 strings; mixing both is an error
+func setGRPCRange(field *qdrant.FieldCondition, r *Range) error {
+	bounds := []interface{}{r.Lt, r.Gt, r.Gte, r.Lte}
+	numbers := make([]*float64, len(bounds))
+	times := make([]*timestamppb.Timestamp, len(bounds))
+	hasNumbers, hasTimes := false, false
+	for i, b := range bounds {
+		if b == nil {
+			continue
+		}
+		if f, ok := toFloat(b); ok {
+			numbers[i] = &f
+			hasNumbers = true
+		} else if t, ok := toTime(b); ok {
+			times[i] = timestamppb.New(t)
+			hasTimes = true
+		} else {
+			return fmt.Errorf("invalid range bound %v of type %T", b, b)
+		}
+	}
+	if hasNumbers && hasTimes {
+		return fmt.Errorf("range mixes numeric and datetime bounds: %v", bounds)
+	}
+
+	if hasTimes {
+		field.DatetimeRange = &qdrant.DatetimeRange{Lt: times[0], Gt: times[1], Gte: times[2], Lte: times[3]}
+	} else {
+		field.Range = &qdrant.Range{Lt: numbers[0], Gt: numbers[1], Gte: numbers[2], Lte: numbers[3]}
+	}
+	return nil
+}
+
+// toInteger converts whole number to int64
+func toInteger(v interface{}) (int64, bool) {
+	if n, ok := v.(json.Number); ok {
+		i, err := n.Int64()
+		return i, err == nil
+	}
+	f, ok := toFloat(v)
+	if !ok || f != math.Trunc(f) {
+		return 0, false
+	}
+	return int64(f), true
+}
@@ -0,0 +1,144 @@
+package vecdb
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestGRPCMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		match    Match
+		expected *qdrant.Match
+		err      string
+	}{
+		{name: "keyword", match: Match{Value: "go"}, expected: &qdrant.Match{MatchValue: &qdrant.Match_Keyword{Keyword: "go"}}},
+		{name: "boolean", match: Match{Value: true}, expected: &qdrant.Match{MatchValue: &qdrant.Match_Boolean{Boolean: true}}},
+		{name: "integer from JSON", match: Match{Value: float64(3)}, expected: &qdrant.Match{MatchValue: &qdrant.Match_Integer{Integer: 3}}},
+		{name: "JSON number", match: Match{Value: json.Number("7")}, expected: &qdrant.Match{MatchValue: &qdrant.Match_Integer{Integer: 7}}},
+		{name: "fraction", match: Match{Value: 1.5}, err: "can't match value 1.5"},
+		{
+			name:     "any keywords",
+			match:    Match{Any: []interface{}{"go", "rust"}},
+			expected: &qdrant.Match{MatchValue: &qdrant.Match_Keywords{Keywords: &qdrant.RepeatedStrings{Strings: []string{"go", "rust"}}}},
+		},
+		{
+			name:     "any integers",
+			match:    Match{Any: []interface{}{1, float64(2)}},
+			expected: &qdrant.Match{MatchValue: &qdrant.Match_Integers{Integers: &qdrant.RepeatedIntegers{Integers: []int64{1, 2}}}},
+		},
+		{
+			name:     "except keywords",
+			match:    Match{Except: []interface{}{"go"}},
+			expected: &qdrant.Match{MatchValue: &qdrant.Match_ExceptKeywords{ExceptKeywords: &qdrant.RepeatedStrings{Strings: []string{"go"}}}},
+		},
+		{
+			name:     "except integers",
+			match:    Match{Except: []interface{}{4}},
+			expected: &qdrant.Match{MatchValue: &qdrant.Match_ExceptIntegers{ExceptIntegers: &qdrant.RepeatedIntegers{Integers: []int64{4}}}},
+		},
+		{name: "any mixed", match: Match{Any: []interface{}{"go", 1}}, err: "can't match both keywords and integers"},
+		{name: "text", match: Match{Text: "goroutines"}, expected: &qdrant.Match{MatchValue: &qdrant.Match_Text{Text: "goroutines"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := grpcMatch(&tt.match)
+			if !errorContains(err, tt.err) {
+				t.Fatalf("error %v, expected %q", err, tt.err)
+			}
+			if tt.err == "" && !proto.Equal(match, tt.expected) {
+				t.Fatalf("match %v, expected %v", match, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGRPCRange(t *testing.T) {
+	number := func(f float64) *float64 { return &f }
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2024, 12, 31, 12, 0, 0, 0, time.FixedZone("", 2*60*60))
+
+	tests := []struct {
+		name     string
+		rng      Range
+		numeric  *qdrant.Range
+		datetime *qdrant.DatetimeRange
+		err      string
+	}{
+		{
+			name:    "numeric",
+			rng:     Range{Gte: 1, Lt: json.Number("2.5")},
+			numeric: &qdrant.Range{Gte: number(1), Lt: number(2.5)},
+		},
+		{
+			name:     "datetime",
+			rng:      Range{Gt: "2024-01-01T00:00:00Z", Lte: until},
+			datetime: &qdrant.DatetimeRange{Gt: timestamppb.New(since), Lte: timestamppb.New(until)},
+		},
+		{name: "mixed bounds", rng: Range{Gte: 1, Lt: "2024-01-01T00:00:00Z"}, err: "mixes numeric and datetime bounds"},
+		{name: "not a date", rng: Range{Gt: "yesterday"}, err: "invalid range bound yesterday"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			field := &qdrant.FieldCondition{Key: "year"}
+			err := setGRPCRange(field, &tt.rng)
+			if !errorContains(err, tt.err) {
+				t.Fatalf("error %v, expected %q", err, tt.err)
+			}
+			if !proto.Equal(field.Range, tt.numeric) || !proto.Equal(field.DatetimeRange, tt.datetime) {
+				t.Fatalf("range %v, datetime range %v; expected %v, %v", field.Range, field.DatetimeRange, tt.numeric, tt.datetime)
+			}
+		})
+	}
+}
+
+func TestGRPCFilter(t *testing.T) {
+	filter := &Filter{
+		Must:    []Condition{MatchValue("lang", "go")},
+		MustNot: []Condition{{Filter: &Filter{Should: []Condition{{Key: "year", Range: &Range{Lt: 2020}}}}}},
+	}
+	lt := float64(2020)
+	expected := &qdrant.Filter{
+		Must: []*qdrant.Condition{{ConditionOneOf: &qdrant.Condition_Field{Field: &qdrant.FieldCondition{
+			Key:   "lang",
+			Match: &qdrant.Match{MatchValue: &qdrant.Match_Keyword{Keyword: "go"}},
+		}}}},
+		MustNot: []*qdrant.Condition{qdrant.NewFilterAsCondition(&qdrant.Filter{
+			Should: []*qdrant.Condition{{ConditionOneOf: &qdrant.Condition_Field{Field: &qdrant.FieldCondition{
+				Key:   "year",
+				Range: &qdrant.Range{Lt: &lt},
+			}}}},
+		})},
+	}
+
+	converted, err := grpcFilter(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(converted, expected) {
+		t.Fatalf("filter %v, expected %v", converted, expected)
+	}
+
+	if converted, err := grpcFilter(nil); converted != nil || err != nil {
+		t.Fatalf("nil filter converted to %v, %v", converted, err)
+	}
+
+	mixed := &Filter{Should: []Condition{{Filter: &Filter{Must: []Condition{{Key: "year", Range: &Range{Gt: 1, Lt: "2024-01-01T00:00:00Z"}}}}}}}
+	if _, err := grpcFilter(mixed); !errorContains(err, `condition on "year": range mixes`) {
+		t.Fatalf("error %v, expected the nested mixed range rejected", err)
+	}
+}
+
+// errorContains tells if the error message contains the text; empty text means no error expected
+func errorContains(err error, text string) bool {
+	if text == "" {
+		return err == nil
+	}
+	return err != nil && strings.Contains(err.Error(), text)
+}
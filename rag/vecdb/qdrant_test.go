@@ -0,0 +1,54 @@
+package vecdb_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+func TestQdrantErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		expected error
+	}{
+		{"conflict", http.StatusConflict, `{"status": {"error": "Wrong input"}}`, vecdb.ErrCollectionExists},
+		{"already exists", http.StatusBadRequest, `{"status": {"error": "Collection knowledge already exists!"}}`, vecdb.ErrCollectionExists},
+		{"not found", http.StatusNotFound, `{"status": {"error": "Not found: Collection knowledge doesn't exist!"}}`, vecdb.ErrCollectionNotFound},
+		{"dimension", http.StatusBadRequest, `{"status": {"error": "Wrong input: Vector dimension error: expected dim: 384, got 3"}}`, vecdb.ErrDimensionMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			err := vecdb.NewQdrantStore(server.URL).CreateCollection(context.Background(), "knowledge", vecdb.CollectionConfig{})
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("error %v, expected %v", err, tt.expected)
+			}
+			var httpErr *vecdb.HTTPError
+			if !errors.As(err, &httpErr) || httpErr.StatusCode != tt.status {
+				t.Fatalf("error %v doesn't carry the HTTP status %d", err, tt.status)
+			}
+		})
+	}
+}
+
+func TestQdrantUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	_, err := vecdb.NewQdrantStore(server.URL).ListCollections(context.Background())
+	if !errors.Is(err, vecdb.ErrQdrantUnreachable) {
+		t.Fatalf("error %v, expected %v", err, vecdb.ErrQdrantUnreachable)
+	}
+}
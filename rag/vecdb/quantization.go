@@ -0,0 +1,162 @@
+package vecdb
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Vector datatypes of the collections, named as in qdrant
+const (
+	DatatypeFloat32 = "float32" // default, 4 bytes per dimension
+	DatatypeFloat16 = "float16" // half the memory of float32, at slight precision loss
+	DatatypeUint8   = "uint8"   // 1 byte per dimension, for embeddings already quantized to integers 0-255
+)
+
+// Quantization kinds, see: https://qdrant.tech/documentation/guides/quantization/
+const (
+	QuantizationNone    = "none"
+	QuantizationScalar  = "scalar"  // every dimension as int8, 4x less memory, small accuracy loss
+	QuantizationProduct = "product" // chunks of dimensions as codebook entries, up to 64x less memory, bigger accuracy loss
+	QuantizationBinary  = "binary"  // every dimension as a single bit, 32x less memory, for high-dimensional embeddings only
+)
+
+// product quantization compression ratios
+var compressionRatios = []string{"x4", "x8", "x16", "x32", "x64"}
+
+// VectorStorage tunes how collection stores and indexes the vectors, trading search accuracy for memory.
+// The zero value means the store defaults: float32 vectors kept in RAM, default HNSW params and no quantization
+type VectorStorage struct {
+	Datatype     string              `json:"datatype,omitempty"`            // ["float32", "float16", "uint8"]; empty means float32
+	OnDisk       bool                `json:"on_disk,omitempty"`             // keep the original vectors in memory-mapped files instead of RAM
+	HNSW         *HNSWConfig         `json:"hnsw_config,omitempty"`         // optional, HNSW index params
+	Quantization *QuantizationConfig `json:"quantization_config,omitempty"` // optional, compressed copy of the vectors searched first
+}
+
+// HNSWConfig controls HNSW index of collection
+type HNSWConfig struct {
+	M           int  `json:"m,omitempty"`            // links per node; 0 means DefaultHNSWM
+	EfConstruct int  `json:"ef_construct,omitempty"` // candidates considered when linking new node; 0 means DefaultHNSWEfConstruct
+	OnDisk      bool `json:"on_disk,omitempty"`      // keep the graph in memory-mapped files instead of RAM
+}
+
+// QuantizationConfig selects the quantization of collection, exactly one of the fields is set
+type QuantizationConfig struct {
+	Scalar  *ScalarQuantization  `json:"scalar,omitempty"`
+	Product *ProductQuantization `json:"product,omitempty"`
+	Binary  *BinaryQuantization  `json:"binary,omitempty"`
+}
+
+// ScalarQuantization converts every dimension to int8
+type ScalarQuantization struct {
+	Type      string   `json:"type"`                 // ["int8"]
+	Quantile  *float64 `json:"quantile,omitempty"`   // the extreme values beyond the quantile are clipped, eg. 0.99; nil means all values are kept
+	AlwaysRAM bool     `json:"always_ram,omitempty"` // keep the quantized vectors in RAM even if the original ones are on disk
+}
+
+// ProductQuantization replaces chunks of dimensions with codebook entries
+type ProductQuantization struct {
+	Compression string `json:"compression"`          // ["x4", "x8", "x16", "x32", "x64"]
+	AlwaysRAM   bool   `json:"always_ram,omitempty"` // keep the quantized vectors in RAM even if the original ones are on disk
+}
+
+// BinaryQuantization converts every dimension to a single bit
+type BinaryQuantization struct {
+	AlwaysRAM bool `json:"always_ram,omitempty"` // keep the quantized vectors in RAM even if the original ones are on disk
+}
+
+// NewQuantization returns the quantization config of given kind; nil for QuantizationNone or empty kind.
+// Compression is used by product quantization only, empty means "x16"
+func NewQuantization(kind, compression string, alwaysRAM bool) (*QuantizationConfig, error) {
+	switch kind {
+	case "", QuantizationNone:
+		return nil, nil
+	case QuantizationScalar:
+		return &QuantizationConfig{Scalar: &ScalarQuantization{Type: "int8", AlwaysRAM: alwaysRAM}}, nil
+	case QuantizationProduct:
+		if compression == "" {
+			compression = "x16"
+		}
+		if !slices.Contains(compressionRatios, compression) {
+			return nil, fmt.Errorf("unknown compression %q, expected one of: %s", compression, strings.Join(compressionRatios, ", "))
+		}
+		return &QuantizationConfig{Product: &ProductQuantization{Compression: compression, AlwaysRAM: alwaysRAM}}, nil
+	case QuantizationBinary:
+		return &QuantizationConfig{Binary: &BinaryQuantization{AlwaysRAM: alwaysRAM}}, nil
+	default:
+		return nil, fmt.Errorf("unknown quantization %q, expected one of: %s, %s, %s, %s", kind, QuantizationNone, QuantizationScalar, QuantizationProduct, QuantizationBinary)
+	}
+}
+
+// Kind returns the quantization kind, QuantizationNone for nil config
+func (q *QuantizationConfig) Kind() string {
+	switch {
+	case q == nil:
+		return QuantizationNone
+	case q.Scalar != nil:
+		return QuantizationScalar
+	case q.Product != nil:
+		return QuantizationProduct + " " + q.Product.Compression
+	case q.Binary != nil:
+		return QuantizationBinary
+	default:
+		return QuantizationNone
+	}
+}
+
+// checkDatatype returns error if the datatype is not known to qdrant
+func checkDatatype(datatype string) error {
+	switch datatype {
+	case "", DatatypeFloat32, DatatypeFloat16, DatatypeUint8:
+		return nil
+	default:
+		return fmt.Errorf("unknown vector datatype %q, expected one of: %s, %s, %s", datatype, DatatypeFloat32, DatatypeFloat16, DatatypeUint8)
+	}
+}
+
+// checkHNSW returns error if the HNSW params can't build a graph: every node needs at least 2 links, as the levels are drawn
+// with 1/ln(m), and at least 1 candidate; zero params mean the defaults
+func checkHNSW(h *HNSWConfig) error {
+	if h == nil {
+		return nil
+	}
+	if h.M == 1 || h.M < 0 {
+		return fmt.Errorf("invalid HNSW m %d, expected at least 2", h.M)
+	}
+	if h.EfConstruct < 0 {
+		return fmt.Errorf("invalid HNSW ef_construct %d, expected at least 1", h.EfConstruct)
+	}
+	return nil
+}
+
+// Describe returns short description of the storage settings that differ from the defaults, eg. "float16, scalar quantization, on disk"
+func (v VectorStorage) Describe() string {
+	var settings []string
+	if v.Datatype != "" && v.Datatype != DatatypeFloat32 {
+		settings = append(settings, v.Datatype)
+	}
+	if v.Quantization.Kind() != QuantizationNone {
+		settings = append(settings, v.Quantization.Kind()+" quantization")
+	}
+	if v.OnDisk {
+		settings = append(settings, "on disk")
+	}
+	if v.HNSW != nil && (v.HNSW.M > 0 || v.HNSW.EfConstruct > 0) {
+		settings = append(settings, fmt.Sprintf("hnsw m=%d ef_construct=%d", v.HNSW.M, v.HNSW.EfConstruct))
+	}
+	return strings.Join(settings, ", ")
+}
+
+// SearchParams tunes the vector search, trading speed for accuracy
+type SearchParams struct {
+	HNSWEf       int                       `json:"hnsw_ef,omitempty"`      // HNSW candidates considered, raised to the limit; 0 means the store default
+	Exact        bool                      `json:"exact,omitempty"`        // compare the query with every point instead of using the HNSW index
+	Quantization *QuantizationSearchParams `json:"quantization,omitempty"` // optional, used by quantized collections only
+}
+
+// QuantizationSearchParams controls the search in quantized collection
+type QuantizationSearchParams struct {
+	Ignore       bool    `json:"ignore,omitempty"`       // search the original vectors only
+	Rescore      *bool   `json:"rescore,omitempty"`      // score the candidates found in quantized vectors again with the original ones; nil means qdrant decides
+	Oversampling float64 `json:"oversampling,omitempty"` // fetch limit*oversampling candidates for rescoring, eg. 2.0; 0 means no oversampling
+}
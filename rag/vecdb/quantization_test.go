@@ -0,0 +1,143 @@
+package vecdb
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNewQuantization(t *testing.T) {
+	tests := []struct {
+		kind        string
+		compression string
+		alwaysRAM   bool
+		expected    *QuantizationConfig
+		described   string
+		valid       bool
+	}{
+		{kind: "", described: QuantizationNone, valid: true},
+		{kind: QuantizationNone, described: QuantizationNone, valid: true},
+		{kind: QuantizationScalar, alwaysRAM: true, expected: &QuantizationConfig{Scalar: &ScalarQuantization{Type: "int8", AlwaysRAM: true}}, described: "scalar", valid: true},
+		{kind: QuantizationProduct, expected: &QuantizationConfig{Product: &ProductQuantization{Compression: "x16"}}, described: "product x16", valid: true},
+		{kind: QuantizationProduct, compression: "x64", expected: &QuantizationConfig{Product: &ProductQuantization{Compression: "x64"}}, described: "product x64", valid: true},
+		{kind: QuantizationProduct, compression: "x3"},
+		{kind: QuantizationBinary, expected: &QuantizationConfig{Binary: &BinaryQuantization{}}, described: "binary", valid: true},
+		{kind: "int4"},
+	}
+	for _, tt := range tests {
+		q, err := NewQuantization(tt.kind, tt.compression, tt.alwaysRAM)
+		if (err == nil) != tt.valid {
+			t.Errorf("NewQuantization(%q, %q): error %v, expected valid %v", tt.kind, tt.compression, err, tt.valid)
+			continue
+		}
+		if !reflect.DeepEqual(q, tt.expected) {
+			t.Errorf("NewQuantization(%q, %q) = %+v, expected %+v", tt.kind, tt.compression, q, tt.expected)
+		}
+		if tt.valid && q.Kind() != tt.described {
+			t.Errorf("NewQuantization(%q, %q) is of kind %q, expected %q", tt.kind, tt.compression, q.Kind(), tt.described)
+		}
+	}
+}
+
+func TestVectorStorageJSON(t *testing.T) {
+	quantile := 0.99
+	tests := []struct {
+		name    string
+		storage VectorStorage
+		json    string
+	}{
+		{
+			name: "defaults",
+			json: `{"size":384,"distance":"Cosine"}`,
+		},
+		{
+			name:    "scalar",
+			storage: VectorStorage{Datatype: DatatypeFloat16, OnDisk: true, Quantization: &QuantizationConfig{Scalar: &ScalarQuantization{Type: "int8", Quantile: &quantile, AlwaysRAM: true}}},
+			json:    `{"size":384,"distance":"Cosine","datatype":"float16","on_disk":true,"quantization_config":{"scalar":{"type":"int8","quantile":0.99,"always_ram":true}}}`,
+		},
+		{
+			name:    "product",
+			storage: VectorStorage{HNSW: &HNSWConfig{M: 32, EfConstruct: 200}, Quantization: &QuantizationConfig{Product: &ProductQuantization{Compression: "x32"}}},
+			json:    `{"size":384,"distance":"Cosine","hnsw_config":{"m":32,"ef_construct":200},"quantization_config":{"product":{"compression":"x32"}}}`,
+		},
+		{
+			name:    "binary",
+			storage: VectorStorage{Quantization: &QuantizationConfig{Binary: &BinaryQuantization{AlwaysRAM: true}}},
+			json:    `{"size":384,"distance":"Cosine","quantization_config":{"binary":{"always_ram":true}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := VectorConfig{Size: 384, Distance: DistanceCosine, VectorStorage: tt.storage}
+			data, err := json.Marshal(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.json {
+				t.Fatalf("marshalled\n%s\nexpected\n%s", data, tt.json)
+			}
+
+			var decoded VectorConfig
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, config) {
+				t.Fatalf("unmarshalled %+v, expected %+v", decoded, config)
+			}
+		})
+	}
+}
+
+func TestVectorStorageGRPC(t *testing.T) {
+	quantile := 0.5 // exact in float32
+	tests := []struct {
+		name    string
+		storage VectorStorage
+	}{
+		{name: "defaults"},
+		{name: "on disk float16", storage: VectorStorage{Datatype: DatatypeFloat16, OnDisk: true}},
+		{name: "hnsw", storage: VectorStorage{HNSW: &HNSWConfig{M: 32, EfConstruct: 200, OnDisk: true}}},
+		{name: "scalar", storage: VectorStorage{Quantization: &QuantizationConfig{Scalar: &ScalarQuantization{Type: "int8", Quantile: &quantile, AlwaysRAM: true}}}},
+		{name: "product", storage: VectorStorage{Quantization: &QuantizationConfig{Product: &ProductQuantization{Compression: "x8"}}}},
+		{name: "binary", storage: VectorStorage{Quantization: &QuantizationConfig{Binary: &BinaryQuantization{AlwaysRAM: true}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := VectorConfig{Size: 384, Distance: DistanceDot, VectorStorage: tt.storage}
+			params, err := grpcVectorParams(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if converted := vectorConfig(params); !reflect.DeepEqual(converted, config) {
+				t.Fatalf("converted back to %+v, expected %+v", converted, config)
+			}
+		})
+	}
+
+	invalid := []VectorStorage{
+		{Datatype: "int4"},
+		{Quantization: &QuantizationConfig{Product: &ProductQuantization{Compression: "x3"}}},
+	}
+	for _, storage := range invalid {
+		if _, err := grpcVectorParams(VectorConfig{Size: 384, Distance: DistanceDot, VectorStorage: storage}); err == nil {
+			t.Errorf("expected error for storage %+v", storage)
+		}
+	}
+}
+
+func TestVectorStorageDescribe(t *testing.T) {
+	tests := []struct {
+		storage   VectorStorage
+		described string
+	}{
+		{VectorStorage{}, ""},
+		{VectorStorage{Datatype: DatatypeFloat32, HNSW: &HNSWConfig{OnDisk: true}}, ""},
+		{VectorStorage{Datatype: DatatypeFloat16, Quantization: &QuantizationConfig{Scalar: &ScalarQuantization{Type: "int8"}}, OnDisk: true}, "float16, scalar quantization, on disk"},
+		{VectorStorage{HNSW: &HNSWConfig{M: 8}, Quantization: &QuantizationConfig{Product: &ProductQuantization{Compression: "x4"}}}, "product x4 quantization, hnsw m=8 ef_construct=0"},
+	}
+	for _, tt := range tests {
+		if described := tt.storage.Describe(); described != tt.described {
+			t.Errorf("%+v described as %q, expected %q", tt.storage, described, tt.described)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package vecdb
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// SnapshotFormat identifies the snapshot files written by ExportCollection
+const SnapshotFormat = "rag-snapshot/v1"
+
+// snapshotPageSize is how many points are read from the store at once when exporting
+const snapshotPageSize = 256
+
+// Snapshot describes collection saved with ExportCollection. The snapshot file is gzipped JSON lines: Snapshot first,
+// then every point with its vectors and payload, so that the collection can be restored into any VectorStore without embedding
+type Snapshot struct {
+	Format      string                     `json:"format"`          // SnapshotFormat
+	Collection  string                     `json:"collection"`      // the exported collection (or alias)
+	Model       string                     `json:"model,omitempty"` // embedding model of the vectors, empty if unknown
+	Config      CollectionConfig           `json:"config"`          // the vectors of the collection
+	Metadata    map[string]string          `json:"metadata,omitempty"`
+	PointsCount int                        `json:"points_count"`
+	CreatedAt   time.Time                  `json:"created_at"`
+	Extra       map[string]json.RawMessage `json:"extra,omitempty"` // attached by the caller, eg. the ingestion manifest
+}
+
+// ImportOptions controls ImportCollection
+type ImportOptions struct {
+	Collection string // collection to restore into; empty means the snapshot's
+	Replace    bool   // delete the collection first if it exists, otherwise ErrCollectionExists is returned
+	BatchSize  int    // how many points are upserted in one request; 0 means FeedOptions default
+}
+
+// ExportCollection writes snapshot of the collection (or alias) to w: its vector config, embedding model and all the points;
+// empty name means the collection selected with UseCollection. Extra is stored in the snapshot as it is
+func ExportCollection(ctx context.Context, w io.Writer, name string, extra map[string]json.RawMessage) (Snapshot, error) {
+	name = collectionOr(name)
+	info, err := DescribeCollection(ctx, name)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	snapshot := Snapshot{
+		Format:      SnapshotFormat,
+		Collection:  name,
+		Model:       info.Model,
+		Config:      CollectionConfig{Vectors: info.Vectors, NamedVectors: info.NamedVectors},
+		Metadata:    info.Metadata,
+		PointsCount: info.PointsCount,
+		CreatedAt:   time.Now().UTC(),
+		Extra:       extra,
+	}
+
+	zw := gzip.NewWriter(w)
+	encoder := json.NewEncoder(zw)
+	if err := encoder.Encode(snapshot); err != nil {
+		return snapshot, err
+	}
+
+	count := 0
+	offset := ""
+	for {
+		points, next, err := store.Scroll(ctx, name, offset, snapshotPageSize)
+		if err != nil {
+			return snapshot, err
+		}
+		for _, p := range points {
+			if err := encoder.Encode(p); err != nil {
+				return snapshot, err
+			}
+		}
+		count += len(points)
+		if next == "" || len(points) == 0 {
+			break
+		}
+		offset = next
+	}
+	if err := zw.Close(); err != nil {
+		return snapshot, err
+	}
+
+	if count != snapshot.PointsCount {
+		slog.Warn("collection changed while exporting", "collection", name, "points_count", snapshot.PointsCount, "exported", count)
+		snapshot.PointsCount = count
+	}
+	slog.Debug("export collection", slog.String("name", name), slog.Int("points", count))
+	return snapshot, nil
+}
+
+// ImportCollection restores collection from snapshot written by ExportCollection, along with its keyword index.
+// The vectors are stored as they are, so the collection is searchable only with the embedding model of the snapshot.
+// Returns the snapshot with Collection set to the restored collection and PointsCount to the restored points
+func ImportCollection(ctx context.Context, r io.Reader, opts ImportOptions) (Snapshot, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultFeedOptions().BatchSize
+	}
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("invalid snapshot: %w", err)
+	}
+	defer zr.Close()
+
+	decoder := json.NewDecoder(zr)
+	var snapshot Snapshot
+	if err := decoder.Decode(&snapshot); err != nil {
+		return Snapshot{}, fmt.Errorf("invalid snapshot: %w", err)
+	}
+	if snapshot.Format != SnapshotFormat {
+		return Snapshot{}, fmt.Errorf("unknown snapshot format %q, expected %q", snapshot.Format, SnapshotFormat)
+	}
+	if opts.Collection != "" {
+		snapshot.Collection = opts.Collection
+	}
+	if snapshot.Model != "" && snapshot.Model != embedder.Model() {
+		slog.Warn("snapshot holds embeddings of another model, the collection can't be searched with the embedder in use", "snapshot_model", snapshot.Model, "embedder_model", embedder.Model())
+	}
+
+	// create the collection from scratch, with fresh keyword index
+	name := snapshot.Collection
+	if opts.Replace {
+		if err := DeleteCollection(ctx, name); err != nil && !errors.Is(err, ErrCollectionNotFound) {
+			return snapshot, err
+		}
+	}
+	config := snapshot.Config
+	config.Metadata = map[string]interface{}{}
+	for k, v := range snapshot.Metadata {
+		config.Metadata[k] = v
+	}
+	if err := addCollection(ctx, name, config, snapshot.Model); err != nil {
+		return snapshot, err
+	}
+	if err := dropKeywordIndex(name); err != nil {
+		return snapshot, err
+	}
+	idx, err := keywordIndex(name)
+	if err != nil {
+		return snapshot, err
+	}
+
+	// store the points in batches
+	count := 0
+	batch := make([]Point, 0, opts.BatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := addPoints(ctx, name, batch); err != nil {
+			return fmt.Errorf("failed to restore points %d-%d: %w", count+1, count+len(batch), err)
+		}
+		for _, p := range batch {
+			idx.Add(p)
+		}
+		count += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	for {
+		var p Point
+		err := decoder.Decode(&p)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return snapshot, fmt.Errorf("invalid snapshot point %d: %w", count+len(batch)+1, err)
+		}
+		batch = append(batch, p)
+		if len(batch) == opts.BatchSize {
+			if err := flush(); err != nil {
+				return snapshot, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return snapshot, err
+	}
+	if err := saveKeywordIndex(name); err != nil {
+		return snapshot, err
+	}
+
+	if count != snapshot.PointsCount {
+		slog.Warn("snapshot has different number of points than recorded", "collection", name, "points_count", snapshot.PointsCount, "restored", count)
+		snapshot.PointsCount = count
+	}
+	slog.Debug("import collection", slog.String("name", name), slog.Int("points", count))
+	return snapshot, nil
+}
@@ -0,0 +1,123 @@
+package vecdb_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	offline(t)
+	ctx := context.Background()
+	docs := make([]vecdb.Document, len(knowledge))
+	for i, text := range knowledge {
+		docs[i] = vecdb.Document{Text: text, Payload: map[string]interface{}{"source": "knowledge.md", "chunk_index": i}}
+	}
+	if _, err := vecdb.FeedDocumentsContext(ctx, docs, vecdb.FeedOptions{Collection: "knowledge"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var file bytes.Buffer
+	extra := map[string]json.RawMessage{"manifest": json.RawMessage(`{"files":{"knowledge.md":"abc"}}`)}
+	exported, err := vecdb.ExportCollection(ctx, &file, "knowledge", extra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported.Format != vecdb.SnapshotFormat || exported.PointsCount != len(knowledge) || exported.Model != vecdb.EmbedderModel() {
+		t.Fatalf("exported snapshot %+v", exported)
+	}
+
+	imported, err := vecdb.ImportCollection(ctx, bytes.NewReader(file.Bytes()), vecdb.ImportOptions{Collection: "restored", BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Collection != "restored" || imported.PointsCount != len(knowledge) || imported.Model != exported.Model {
+		t.Fatalf("imported snapshot %+v", imported)
+	}
+	if !reflect.DeepEqual(imported.Extra, extra) || !reflect.DeepEqual(imported.Metadata, exported.Metadata) || !imported.CreatedAt.Equal(exported.CreatedAt) {
+		t.Fatalf("snapshot metadata changed: exported %+v, imported %+v", exported, imported)
+	}
+
+	original, err := vecdb.DescribeCollection(ctx, "knowledge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := vecdb.DescribeCollection(ctx, "restored")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.PointsCount != original.PointsCount || restored.Model != original.Model || !reflect.DeepEqual(restored.Metadata, original.Metadata) || restored.Vectors != original.Vectors {
+		t.Fatalf("restored collection %+v, expected as %+v", restored, original)
+	}
+	originalPoints, _, err := vecdb.Store().Scroll(ctx, "knowledge", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restoredPoints, _, err := vecdb.Store().Scroll(ctx, "restored", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// compared as JSON, since the payload numbers come back as float64
+	originalJSON, _ := json.Marshal(originalPoints)
+	restoredJSON, _ := json.Marshal(restoredPoints)
+	if len(restoredPoints) != len(knowledge) || !bytes.Equal(restoredJSON, originalJSON) {
+		t.Fatalf("restored points\n%s\nexpected\n%s", restoredJSON, originalJSON)
+	}
+
+	for _, mode := range []string{vecdb.SearchVector, vecdb.SearchKeyword} {
+		results, err := vecdb.AskDBQuery(ctx, vecdb.Query{Text: "goroutines and channels", Collection: "restored", Limit: 1, Mode: mode})
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		if len(results) != 1 || results[0].Text != knowledge[0] || results[0].PayloadString("source") != "knowledge.md" {
+			t.Fatalf("%s: found %+v in restored collection, expected %q", mode, results, knowledge[0])
+		}
+	}
+
+	if _, err := vecdb.ImportCollection(ctx, bytes.NewReader(file.Bytes()), vecdb.ImportOptions{Collection: "restored"}); !errors.Is(err, vecdb.ErrCollectionExists) {
+		t.Fatalf("error %v, expected %v", err, vecdb.ErrCollectionExists)
+	}
+	if _, err := vecdb.ImportCollection(ctx, bytes.NewReader(file.Bytes()), vecdb.ImportOptions{Collection: "restored", Replace: true}); err != nil {
+		t.Fatalf("replacing the collection: %v", err)
+	}
+}
+
+func TestImportInvalidSnapshot(t *testing.T) {
+	gzipped := func(text string) []byte {
+		var data bytes.Buffer
+		zw := gzip.NewWriter(&data)
+		zw.Write([]byte(text))
+		zw.Close()
+		return data.Bytes()
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{name: "not gzipped", data: []byte(`{"format": "rag-snapshot/v1"}`), err: "invalid snapshot"},
+		{name: "unknown format", data: gzipped(`{"format": "rag-snapshot/v0", "collection": "old"}`), err: "unknown snapshot format"},
+		{
+			name: "broken point",
+			data: gzipped(`{"format": "rag-snapshot/v1", "collection": "broken", "config": {"vectors": {"size": 2, "distance": "Cosine"}}}` + "\n{\"id\": ["),
+			err:  "invalid snapshot point 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offline(t)
+			_, err := vecdb.ImportCollection(context.Background(), bytes.NewReader(tt.data), vecdb.ImportOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error %v, expected %q", err, tt.err)
+			}
+		})
+	}
+}
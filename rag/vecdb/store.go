@@ -0,0 +1,86 @@
+package vecdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// VectorStore keeps the points in collections and finds the points nearest to a vector
+type VectorStore interface {
+	CreateCollection(ctx context.Context, name string, config CollectionConfig) error // ErrCollectionExists if already there
+	DescribeCollection(ctx context.Context, name string) (CollectionInfo, error)      // name can also be an alias
+	ListCollections(ctx context.Context) ([]string, error)                            // sorted
+	DeleteCollection(ctx context.Context, name string) error
+	ListAliases(ctx context.Context) (map[string]string, error) // alias -> collection
+	SwitchAlias(ctx context.Context, alias, collection string) error
+	Upsert(ctx context.Context, collection string, points []Point) error // points with existing ids get replaced
+	Delete(ctx context.Context, collection string, ids []string) error
+	Search(ctx context.Context, collection string, query SearchQuery) ([]SearchResult, error)  // best first
+	Scroll(ctx context.Context, collection, offset string, limit int) ([]Point, string, error) // page of points with vectors and payload from offset (empty means first); next offset is empty after the last page
+	CreatePayloadIndex(ctx context.Context, collection, field, schema string) error
+	Ping(ctx context.Context) error
+}
+
+// StoreConfig selects and configures the VectorStore implementation
+type StoreConfig struct {
+	Provider  string // ["qdrant", "embedded"]
+	Transport string // qdrant only, ["rest", "grpc"]; empty means rest
+	URL       string // qdrant only; empty means http://localhost:6333 for rest, localhost:6334 for grpc
+	Dir       string // embedded only, where the collections are persisted; empty means ".vecdb"
+	Index     string // embedded only, ["hnsw", "flat"]; empty means hnsw
+}
+
+const defaultEmbeddedDir = ".vecdb"
+
+// StoreConfigFromEnv reads the vector store configuration from environment variables:
+// RAG_STORE, RAG_STORE_TRANSPORT, RAG_STORE_URL, RAG_STORE_DIR and RAG_STORE_INDEX
+func StoreConfigFromEnv() StoreConfig {
+	return StoreConfig{
+		Provider:  os.Getenv("RAG_STORE"),
+		Transport: os.Getenv("RAG_STORE_TRANSPORT"),
+		URL:       os.Getenv("RAG_STORE_URL"),
+		Dir:       os.Getenv("RAG_STORE_DIR"),
+		Index:     os.Getenv("RAG_STORE_INDEX"),
+	}
+}
+
+// NewStore creates VectorStore according to provided config; empty provider means "qdrant"
+func NewStore(cfg StoreConfig) (VectorStore, error) {
+	switch cfg.Provider {
+	case "", "qdrant":
+		switch cfg.Transport {
+		case "", "rest":
+			return NewQdrantStore(cfg.URL), nil
+		case "grpc":
+			return NewQdrantGRPCStore(cfg.URL), nil
+		default:
+			return nil, fmt.Errorf("unknown qdrant transport %q, expected rest or grpc", cfg.Transport)
+		}
+	case "embedded":
+		if cfg.Index != "" && cfg.Index != IndexHNSW && cfg.Index != IndexFlat {
+			return nil, fmt.Errorf("unknown embedded store index %q, expected %s or %s", cfg.Index, IndexHNSW, IndexFlat)
+		}
+		s := NewEmbeddedStore(cfg.Dir)
+		if cfg.Index != "" {
+			s.Index = cfg.Index
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown vector store %q", cfg.Provider)
+	}
+}
+
+// store keeps the collections used by FeedDB and AskDB
+var store VectorStore = NewQdrantStore("")
+
+// SetStore selects the VectorStore used by FeedDB, AskDB and the collection management
+func SetStore(s VectorStore) {
+	store = s
+	forgetVerifiedModels()
+}
+
+// Store returns the VectorStore in use
+func Store() VectorStore {
+	return store
+}
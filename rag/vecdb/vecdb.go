@@ -0,0 +1,303 @@
+package vecdb
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type SearchResult struct {
+	ID      string                 // point identifier
+	Score   float64                // range 0-1
+	Text    string                 // text of the found vector db entry
+	Payload map[string]interface{} // complete payload of the found vector db entry, including "text"
+}
+
+// PayloadString returns payload field as string, or "" if missing
+func (r SearchResult) PayloadString(key string) string {
+	if v, ok := r.Payload[key]; ok && v != nil {
+		if s, ok := v.(string); ok {
+			return s
+		}
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// Query describes what to search for in vector database
+type Query struct {
+	Text          string               // question to search information for
+	Collection    string               // collection or alias to search; empty means the one selected with UseCollection
+	Limit         int                  // max number of results
+	Filter        *Filter              // optional, narrows down the search to the points with matching payload
+	Mode          string               // SearchVector (default), SearchKeyword or SearchHybrid
+	VectorWeight  *float64             // hybrid mode only, weight of the vector ranking in the fusion, see Weight; nil means 1, 0 turns the vector search off
+	KeywordWeight *float64             // hybrid mode only, weight of the keyword ranking in the fusion, see Weight; nil means 1, 0 turns the keyword search off
+	Params        *SearchParams        // optional, tunes the vector search, eg. HNSW ef or quantization rescoring; not used in keyword mode
+	Using         []string             // named vectors to search, eg. VectorTitle; several are fused with reciprocal rank fusion. Empty means VectorText in collection with named vectors
+	Vectors       map[string][]float64 // optional query vectors of the named vectors, eg. VectorImage embedding of a picture; the other vectors are searched with the embedding of Text
+}
+
+// Search modes
+const (
+	SearchVector  = "vector"  // dense vector similarity, SearchResult.Score is the vector store score for Cosine and Dot, and 1/(1+distance) for Euclid and Manhattan
+	SearchKeyword = "keyword" // BM25 over the local keyword index, SearchResult.Score is the BM25 score
+	SearchHybrid  = "hybrid"  // both of the above merged with reciprocal rank fusion, SearchResult.Score is the fused score, range 0-1
+)
+
+// embedder generates embeddings for the stored knowledge and the questions
+var embedder Embedder = NewSidecarEmbedder("")
+
+// SetEmbedder selects the Embedder used by FeedDB and AskDB
+func SetEmbedder(e Embedder) {
+	embedder = e
+}
+
+// EmbedderModel returns the model of the Embedder in use, empty if not known
+func EmbedderModel() string {
+	return embedder.Model()
+}
+
+// embedderModel returns the model of the Embedder in use; unknown model is an error, so that it is never recorded in collection metadata
+func embedderModel() (string, error) {
+	model := embedder.Model()
+	if model == "" {
+		return "", fmt.Errorf("%w: the embedding model is unknown", ErrEmbedderFailed)
+	}
+	return model, nil
+}
+
+// AskDB retrieves information from the vector database based on the provided question, it returns a maximum of maxAnswers
+func AskDB(question string, maxAnswers int) []SearchResult {
+	result, err := AskDBContext(context.Background(), question, maxAnswers)
+	panicOnError(err)
+	return result
+}
+
+// AskDBContext is AskDB that returns an error instead of exiting the process
+func AskDBContext(ctx context.Context, question string, maxAnswers int) ([]SearchResult, error) {
+	return AskDBQuery(ctx, Query{Text: question, Limit: maxAnswers})
+}
+
+// AskDBQuery retrieves information from the vector database according to the query, eg. limited to points matching the filter
+func AskDBQuery(ctx context.Context, q Query) ([]SearchResult, error) {
+	collection := collectionOr(q.Collection)
+	var results []SearchResult
+	var err error
+	switch q.Mode {
+	case "", SearchVector:
+		results, err = vectorSearch(ctx, collection, q)
+	case SearchKeyword:
+		results, err = keywordSearch(ctx, collection, q)
+	case SearchHybrid:
+		results, err = hybridSearch(ctx, collection, q)
+	default:
+		return nil, fmt.Errorf("unknown search mode %q, expected one of: %s, %s, %s", q.Mode, SearchVector, SearchKeyword, SearchHybrid)
+	}
+
+	// the same point comes with the same id whatever the mode and the store, so that the results can be merged
+	for i := range results {
+		results[i].ID = canonicalPointID(results[i].ID)
+	}
+	return results, err
+}
+
+// vectorSearch finds the points with embeddings most similar to the query text embedding. In collection with named vectors
+// it searches the vectors selected by the query; the rankings of several vectors are fused, so the score is the fused one then, range 0-1
+func vectorSearch(ctx context.Context, collection string, q Query) ([]SearchResult, error) {
+	verified, err := checkModel(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	using, err := searchedVectors(collection, q, verified.namedVectors)
+	if err != nil {
+		return nil, err
+	}
+
+	var embedding []float64 // of the query text, embedded once and only if needed
+	search := func(name string, limit int) ([]SearchResult, error) {
+		vector, ok := q.Vectors[name]
+		if !ok {
+			if embedding == nil {
+				if embedding, err = embed(ctx, q.Text); err != nil {
+					return nil, err
+				}
+			}
+			vector = embedding
+		}
+		slog.Debug("search", slog.String("collection", collection), slog.String("vector", name), slog.String("text", q.Text))
+		results, err := store.Search(ctx, collection, SearchQuery{Vector: float32s(vector), Using: name, Filter: q.Filter, Params: q.Params, Limit: limit, WithPayload: true})
+		if err != nil {
+			return nil, err
+		}
+		distance := verified.distances[name]
+		if distance == DistanceEuclid || distance == DistanceManhattan {
+			for i := range results {
+				results[i].Score = distanceSimilarity(results[i].Score)
+			}
+		}
+		return results, nil
+	}
+
+	if len(using) <= 1 {
+		name := ""
+		if len(using) == 1 {
+			name = using[0]
+		}
+		return search(name, q.Limit)
+	}
+
+	var rankings [][]SearchResult
+	for _, name := range using {
+		results, err := search(name, q.Limit*hybridOverFetch)
+		if err != nil {
+			return nil, err
+		}
+		rankings = append(rankings, results)
+	}
+	return FuseRankings(rankings, nil, q.Limit), nil
+}
+
+// distanceSimilarity turns the distance the store returns as score for Euclid and Manhattan into similarity in range 0-1,
+// higher meaning closer like for the other distance funcs
+func distanceSimilarity(distance float64) float64 {
+	return 1 / (1 + distance)
+}
+
+// CreatePayloadIndex indexes payload field to speed up filtering by it; schema is one of ["keyword", "integer", "float", "bool", "datetime", "text"]
+func CreatePayloadIndex(ctx context.Context, field, schema string) error {
+	slog.Debug("create payload index", slog.String("field", field), slog.String("schema", schema))
+	return store.CreatePayloadIndex(ctx, collectionName, field, schema)
+}
+
+// makePoint prepares collection entry for the document, without the vectors
+func makePoint(doc Document) Point {
+	payload := map[string]interface{}{}
+	for k, v := range doc.Payload {
+		payload[k] = v
+	}
+	payload["text"] = doc.Text
+
+	id := doc.ID
+	if id == "" {
+		id = generateMD5HashString(doc.Text)
+	}
+	return Point{
+		ID:      id,
+		Payload: payload,
+	}
+}
+
+// addPoints adds new entries to collection in a single request
+func addPoints(ctx context.Context, collection string, points []Point) error {
+	slog.Debug("add points", slog.String("collection", collection), slog.Int("count", len(points)))
+	return store.Upsert(ctx, collection, points)
+}
+
+// DeletePoints removes the points with given ids from the collection used by FeedDB and AskDB
+func DeletePoints(ctx context.Context, ids []string) error {
+	return deletePoints(ctx, collectionName, ids)
+}
+
+// DeletePointsFrom removes the points with given ids from the collection; empty collection means the one used by FeedDB and AskDB
+func DeletePointsFrom(ctx context.Context, collection string, ids []string) error {
+	return deletePoints(ctx, collectionOr(collection), ids)
+}
+
+// Ping checks that the vector store is up and running
+func Ping(ctx context.Context) error {
+	return store.Ping(ctx)
+}
+
+// deletePoints removes entries from collection in a single request
+func deletePoints(ctx context.Context, collection string, ids []string) error {
+	slog.Debug("delete points", slog.String("collection", collection), slog.Int("count", len(ids)))
+	if len(ids) == 0 {
+		return nil
+	}
+	collection, err := resolveAlias(ctx, collection)
+	if err != nil {
+		return err
+	}
+
+	if err := store.Delete(ctx, collection, ids); err != nil {
+		return err
+	}
+
+	// Keep keyword index in sync
+	idx, err := keywordIndex(collection)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		idx.Remove(id)
+	}
+	return saveKeywordIndex(collection)
+}
+
+// generateMD5HashString generates an MD5 hash string from the provided text.
+func generateMD5HashString(text string) string {
+	h := md5.New()
+	h.Write([]byte(text))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// canonicalPointID returns the id as qdrant returns it: 32 hex digits, eg. MD5 hash, become dashed lowercase UUID.
+// Other ids are returned as they are
+func canonicalPointID(id string) string {
+	if len(id) != 32 {
+		return id
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return id
+	}
+	id = strings.ToLower(id)
+	return id[0:8] + "-" + id[8:12] + "-" + id[12:16] + "-" + id[16:20] + "-" + id[20:32]
+}
+
+// panicOnError checks if the provided error is not nil and exits the process with that error.
+func panicOnError(err error) {
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+// embed generates an embedding for the given input string using the selected Embedder and returns it as a slice of float64 values.
+func embed(ctx context.Context, input string) ([]float64, error) {
+	embedding, err := embedder.Embed(ctx, input)
+	if err != nil {
+		return nil, embedderError(ctx, err)
+	}
+	return embedding, nil
+}
+
+// embedBatch generates embeddings for the given input strings using the selected Embedder
+func embedBatch(ctx context.Context, inputs []string) ([][]float64, error) {
+	embeddings, err := embedder.EmbedBatch(ctx, inputs)
+	if err != nil {
+		return nil, embedderError(ctx, err)
+	}
+	return embeddings, nil
+}
+
+// float32s converts the embedding to float32, as stored in the collections; it halves the memory and the size of the requests
+func float32s(v []float64) []float32 {
+	result := make([]float32, len(v))
+	for i, x := range v {
+		result[i] = float32(x)
+	}
+	return result
+}
+
+// embedderError wraps embedder failure into ErrEmbedderFailed, unless it was caused by ctx being done
+func embedderError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrEmbedderFailed, err)
+}
@@ -0,0 +1,165 @@
+package vecdb_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+var knowledge = []string{
+	"Go is a statically typed language with goroutines and channels.",
+	"Rust guarantees memory safety through ownership and borrowing.",
+	"Qdrant is a vector database written in Rust.",
+}
+
+// offline selects the fake embedder and the embedded store in a temp dir, so that the tests need no services
+func offline(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	vecdb.SetEmbedder(vecdb.NewFakeEmbedder(0))
+	vecdb.SetStore(vecdb.NewEmbeddedStore(dir))
+	vecdb.SetKeywordIndexDir(dir)
+	vecdb.UseCollection("knowledge")
+}
+
+func TestFeedAndAsk(t *testing.T) {
+	offline(t)
+	ctx := context.Background()
+
+	report, err := vecdb.FeedDBContext(ctx, knowledge, vecdb.DefaultFeedOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Stored != len(knowledge) || len(report.Failed) != 0 {
+		t.Fatalf("stored %d, failed %v; expected %d stored", report.Stored, report.Failed, len(knowledge))
+	}
+
+	for _, mode := range []string{vecdb.SearchVector, vecdb.SearchKeyword, vecdb.SearchHybrid} {
+		results, err := vecdb.AskDBQuery(ctx, vecdb.Query{Text: "goroutines and channels", Limit: 3, Mode: mode})
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		if len(results) == 0 || results[0].Text != knowledge[0] {
+			t.Fatalf("%s: expected %q first, got %+v", mode, knowledge[0], results)
+		}
+		seen := map[string]bool{}
+		for _, r := range results {
+			if seen[r.ID] {
+				t.Fatalf("%s: point %s returned twice", mode, r.ID)
+			}
+			seen[r.ID] = true
+		}
+	}
+}
+
+func TestAskWithAnotherModel(t *testing.T) {
+	offline(t)
+	ctx := context.Background()
+
+	if _, err := vecdb.FeedDBContext(ctx, knowledge, vecdb.DefaultFeedOptions()); err != nil {
+		t.Fatal(err)
+	}
+	info, err := vecdb.DescribeCollection(ctx, "knowledge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Model != vecdb.EmbedderModel() {
+		t.Fatalf("collection model %q, expected %q", info.Model, vecdb.EmbedderModel())
+	}
+
+	vecdb.SetEmbedder(vecdb.NewFakeEmbedder(64))
+	_, err = vecdb.AskDBContext(ctx, "goroutines", 3)
+	if !errors.Is(err, vecdb.ErrModelMismatch) {
+		t.Fatalf("expected ErrModelMismatch, got %v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name     string
+		embedder vecdb.Embedder
+		call     func(ctx context.Context) error
+		ctx      context.Context
+		expected error
+	}{
+		{
+			name: "missing collection",
+			call: func(ctx context.Context) error {
+				_, err := vecdb.AskDBQuery(ctx, vecdb.Query{Text: "go", Collection: "missing", Limit: 1})
+				return err
+			},
+			expected: vecdb.ErrCollectionNotFound,
+		},
+		{
+			name: "collection exists",
+			call: func(ctx context.Context) error {
+				_, err := vecdb.FeedDBContext(ctx, knowledge, vecdb.DefaultFeedOptions())
+				return err
+			},
+			expected: vecdb.ErrCollectionExists,
+		},
+		{
+			name:     "embedder failing",
+			embedder: failingEmbedder{vecdb.NewFakeEmbedder(0)},
+			call: func(ctx context.Context) error {
+				_, err := vecdb.AskDBContext(ctx, "go", 1)
+				return err
+			},
+			expected: vecdb.ErrEmbedderFailed,
+		},
+		{
+			name: "unknown vector",
+			call: func(ctx context.Context) error {
+				_, err := vecdb.AskDBQuery(ctx, vecdb.Query{Text: "go", Limit: 1, Using: []string{vecdb.VectorTitle}})
+				return err
+			},
+			expected: vecdb.ErrUnknownVector,
+		},
+		{
+			name: "canceled",
+			call: func(ctx context.Context) error {
+				_, err := vecdb.FeedDBContext(ctx, knowledge, vecdb.FeedOptions{Append: true})
+				return err
+			},
+			ctx:      canceled,
+			expected: context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offline(t)
+			if _, err := vecdb.FeedDBContext(context.Background(), knowledge, vecdb.DefaultFeedOptions()); err != nil {
+				t.Fatal(err)
+			}
+			if tt.embedder != nil {
+				vecdb.SetEmbedder(tt.embedder)
+			}
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			if err := tt.call(ctx); !errors.Is(err, tt.expected) {
+				t.Fatalf("error %v, expected %v", err, tt.expected)
+			}
+		})
+	}
+}
+
+// failingEmbedder fails every embedding, like unreachable embedding server
+type failingEmbedder struct {
+	*vecdb.FakeEmbedder
+}
+
+func (failingEmbedder) Embed(ctx context.Context, text string) ([]float64, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (failingEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	return nil, errors.New("connection refused")
+}
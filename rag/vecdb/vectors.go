@@ -0,0 +1,141 @@
+package vecdb
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Named vectors of the collections fed with FeedOptions.Vectors, see CreateNamedCollection
+const (
+	VectorText  = "text"  // embedding of Document.Text
+	VectorTitle = "title" // embedding of Document.Title
+	VectorImage = "image" // embedding of the picture the document describes, precomputed by vision model, see Document.Vectors
+)
+
+// feedConfig returns the config of collection for the documents: single vector of the text embeddings, or the named vectors
+// of opts.Vectors together with the precomputed vectors the documents come with
+func feedConfig(dimensions int, docs []Document, opts FeedOptions) (CollectionConfig, error) {
+	embedded := VectorConfig{Size: dimensions, Distance: opts.Distance, VectorStorage: opts.Storage}
+
+	precomputed := map[string]int{} // name -> dimensions
+	for _, doc := range docs {
+		for name, vector := range doc.Vectors {
+			if _, ok := precomputed[name]; !ok {
+				precomputed[name] = len(vector)
+			}
+		}
+	}
+	if len(opts.Vectors) == 0 && len(precomputed) == 0 {
+		return CollectionConfig{Vectors: embedded}, nil
+	}
+
+	names := opts.Vectors
+	if len(names) == 0 {
+		names = []string{VectorText}
+	}
+	named := map[string]VectorConfig{}
+	for _, name := range names {
+		if name != VectorText && name != VectorTitle {
+			return CollectionConfig{}, fmt.Errorf("unknown embedded vector %q, expected %s or %s; other vectors come precomputed with the documents", name, VectorText, VectorTitle)
+		}
+		named[name] = embedded
+	}
+	for name, size := range precomputed {
+		if _, ok := named[name]; ok {
+			return CollectionConfig{}, fmt.Errorf("vector %q is both embedded and precomputed", name)
+		}
+		named[name] = VectorConfig{Size: size, Distance: opts.Distance, VectorStorage: opts.Storage}
+	}
+	return CollectionConfig{NamedVectors: named}, nil
+}
+
+// setNamedVectors embeds the texts and the titles of the documents for the named vectors of the collection,
+// and adds the precomputed vectors; empty titles are not embedded, so the points lack the title vector then
+func setNamedVectors(ctx context.Context, points []Point, docs []Document, vectors map[string]VectorConfig) error {
+	for i, doc := range docs {
+		points[i].Vectors = map[string][]float32{}
+		for name, vector := range doc.Vectors {
+			points[i].Vectors[name] = float32s(vector)
+		}
+	}
+
+	for _, name := range []string{VectorText, VectorTitle} {
+		if _, ok := vectors[name]; !ok {
+			continue
+		}
+
+		var texts []string
+		var indices []int
+		for i, doc := range docs {
+			text := doc.Text
+			if name == VectorTitle {
+				text = doc.Title
+			}
+			if text != "" {
+				texts = append(texts, text)
+				indices = append(indices, i)
+			}
+		}
+		if len(texts) == 0 {
+			continue
+		}
+
+		embeddings, err := embedBatch(ctx, texts)
+		if err != nil {
+			return err
+		}
+		for j, i := range indices {
+			points[i].Vectors[name] = float32s(embeddings[j])
+		}
+	}
+	return nil
+}
+
+// searchedVectors returns the vectors the query searches: q.Using, or VectorText (the first vector if missing) in collection
+// with named vectors; nil means the single vector
+func searchedVectors(collection string, q Query, named []string) ([]string, error) {
+	if len(named) == 0 {
+		if len(q.Using) > 0 {
+			return nil, fmt.Errorf("%w: collection %q has single vector, can't search named vectors %v", ErrUnknownVector, collection, q.Using)
+		}
+		return nil, nil
+	}
+	for _, name := range q.Using {
+		if !slices.Contains(named, name) {
+			return nil, fmt.Errorf("%w: collection %q has no vector named %q, expected one of: %s", ErrUnknownVector, collection, name, strings.Join(named, ", "))
+		}
+	}
+	if len(q.Using) > 0 {
+		return q.Using, nil
+	}
+	for _, name := range named {
+		if name == VectorText {
+			return []string{VectorText}, nil
+		}
+	}
+	return named[:1], nil
+}
+
+// vectorLabel describes the vector in messages, eg. ` of vector "title"`; empty for the single vector
+func vectorLabel(name string) string {
+	if name == "" {
+		return ""
+	}
+	return fmt.Sprintf(" of vector %q", name)
+}
+
+// sortedKeys returns the names of the vectors in alphabetical order, nil if there are none
+func sortedKeys(vectors map[string]VectorConfig) []string {
+	if len(vectors) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(vectors))
+	for name := range vectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
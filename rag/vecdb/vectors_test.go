@@ -0,0 +1,127 @@
+package vecdb_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/mateuszmidor/AiStudy/rag/vecdb"
+)
+
+func TestAskNamedVectors(t *testing.T) {
+	offline(t)
+	ctx := context.Background()
+	docs := []vecdb.Document{
+		{Title: "Concurrency", Text: "Go has goroutines and channels.", Vectors: map[string][]float64{vecdb.VectorImage: {1, 0, 0}}},
+		{Title: "Goroutines compared", Text: "Rust guarantees memory safety.", Vectors: map[string][]float64{vecdb.VectorImage: {0, 1, 0}}},
+		{Text: "Qdrant stores vectors.", Vectors: map[string][]float64{vecdb.VectorImage: {0, 0, 1}}}, // no title vector
+	}
+	opts := vecdb.FeedOptions{Collection: "knowledge", Vectors: []string{vecdb.VectorText, vecdb.VectorTitle}}
+	if _, err := vecdb.FeedDocumentsContext(ctx, docs, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		query   vecdb.Query
+		first   string
+		texts   []string // all found, in any order; nil means not checked
+		invalid error
+	}{
+		{
+			name:  "text by default",
+			query: vecdb.Query{Text: "goroutines"},
+			first: docs[0].Text,
+		},
+		{
+			name:  "title",
+			query: vecdb.Query{Text: "goroutines", Using: []string{vecdb.VectorTitle}},
+			first: docs[1].Text,
+			texts: []string{docs[0].Text, docs[1].Text},
+		},
+		{
+			name:  "precomputed image",
+			query: vecdb.Query{Using: []string{vecdb.VectorImage}, Vectors: map[string][]float64{vecdb.VectorImage: {0, 0.1, 1}}},
+			first: docs[2].Text,
+		},
+		{
+			name:  "text and title fused",
+			query: vecdb.Query{Text: "goroutines", Using: []string{vecdb.VectorText, vecdb.VectorTitle}, Limit: 2},
+			texts: []string{docs[0].Text, docs[1].Text},
+		},
+		{
+			name:    "unknown vector",
+			query:   vecdb.Query{Text: "goroutines", Using: []string{"audio"}},
+			invalid: vecdb.ErrUnknownVector,
+		},
+		{
+			name:    "image query of wrong size",
+			query:   vecdb.Query{Using: []string{vecdb.VectorImage}, Vectors: map[string][]float64{vecdb.VectorImage: {1, 0}}},
+			invalid: vecdb.ErrDimensionMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query
+			q.Collection = "knowledge"
+			if q.Limit == 0 {
+				q.Limit = 3
+			}
+			results, err := vecdb.AskDBQuery(ctx, q)
+			if !errors.Is(err, tt.invalid) {
+				t.Fatalf("error %v, expected %v", err, tt.invalid)
+			}
+			if tt.invalid != nil {
+				return
+			}
+
+			if len(results) == 0 {
+				t.Fatal("nothing found")
+			}
+			if tt.first != "" && results[0].Text != tt.first {
+				t.Fatalf("found %q first, expected %q", results[0].Text, tt.first)
+			}
+			if tt.texts != nil {
+				var texts []string
+				for _, r := range results {
+					texts = append(texts, r.Text)
+				}
+				sort.Strings(texts)
+				sort.Strings(tt.texts)
+				if !reflect.DeepEqual(texts, tt.texts) {
+					t.Fatalf("found %q, expected %q", texts, tt.texts)
+				}
+			}
+			for _, r := range results {
+				if r.Score < 0 || r.Score > 1 {
+					t.Fatalf("score %v of %q out of range 0-1", r.Score, r.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestFeedNamedVectorsInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		vectors []string
+		doc     vecdb.Document
+	}{
+		{name: "embedded image", vectors: []string{vecdb.VectorImage}, doc: vecdb.Document{Text: "Go"}},
+		{name: "both embedded and precomputed", vectors: []string{vecdb.VectorText}, doc: vecdb.Document{Text: "Go", Vectors: map[string][]float64{vecdb.VectorText: {1}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offline(t)
+			opts := vecdb.FeedOptions{Collection: "knowledge", Vectors: tt.vectors}
+			if _, err := vecdb.FeedDocumentsContext(context.Background(), []vecdb.Document{tt.doc}, opts); err == nil {
+				t.Fatal("expected error")
+			}
+			if _, err := vecdb.AskDBQuery(context.Background(), vecdb.Query{Text: "Go", Collection: "knowledge", Limit: 1}); !errors.Is(err, vecdb.ErrCollectionNotFound) {
+				t.Fatalf("error %v, expected no collection created", err)
+			}
+		})
+	}
+}
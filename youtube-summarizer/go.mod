@@ -2,4 +2,9 @@ module github.com/mateuszmidor/AiStudy/youtube-summarizer
 
 go 1.22.5
 
-require golang.org/x/net v0.27.0
+require (
+	github.com/mateuszmidor/AiStudy/llm v0.0.0
+	golang.org/x/net v0.27.0
+)
+
+replace github.com/mateuszmidor/AiStudy/llm => ../llm
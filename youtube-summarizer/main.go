@@ -1,11 +1,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strings"
+
+	"github.com/mateuszmidor/AiStudy/llm"
 )
 
 func main() {
+	// select the model with LLM_PROVIDER, LLM_MODEL, LLM_URL environment variables; ollama llama3 by default.
+	// LLM_CACHE=<dir> caches the summaries, see llm.ConfigFromEnv
+	model, err := llm.New(llm.ConfigFromEnv("LLM"))
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
 	videoURL := "https://www.youtube.com/watch?v=Fjna3U56a7E" // must be a video with captions
 	captions, err := getCaptions(videoURL)
 	if err != nil {
@@ -15,6 +26,20 @@ func main() {
 
 	joinedCaptions := strings.Join(captions, "\n")
 	prompt := fmt.Sprintf("Summarize the following text in bullet point format, you MUST respond in Polish language.\nText:\n%s", joinedCaptions)
-	completion := ollamaGenerateCompletion(prompt)
-	fmt.Println(completion)
+	fmt.Printf("Prompt:\n%s\n\n", prompt)
+	fmt.Println("num characters:", len(prompt))
+
+	fmt.Printf("Sending prompt to %s...\n", model.Model())
+	completion, err := model.Generate(context.Background(), prompt)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if cache := llm.CacheOf(model); cache != nil {
+		fmt.Printf("Cache: %+v\n", cache.Stats())
+	}
+	fmt.Println("Received response:")
+	fmt.Println("- input tokens:", completion.Usage.PromptTokens)
+	fmt.Println("- output tokens:", completion.Usage.CompletionTokens)
+	fmt.Println(completion.Text)
 }